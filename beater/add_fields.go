@@ -0,0 +1,60 @@
+package beater
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/processors"
+)
+
+// addFields is a processor adding static fields to every event, e.g. to tag
+// all documents with the environment apm-server runs in. It can be used in
+// the `processors` section along with the processors provided by libbeat.
+type addFields struct {
+	fields common.MapStr
+}
+
+func init() {
+	processors.RegisterPlugin("add_fields", newAddFields)
+}
+
+func newAddFields(c *common.Config) (processors.Processor, error) {
+	config := struct {
+		Target *string       `config:"target"`
+		Fields common.MapStr `config:"fields" validate:"required"`
+	}{}
+	if err := c.Unpack(&config); err != nil {
+		return nil, fmt.Errorf("fail to unpack the add_fields configuration: %s", err)
+	}
+
+	// fields are added under `fields` by default, like the general `fields`
+	// setting does, an empty target adds them at the root of the event
+	target := "fields"
+	if config.Target != nil {
+		target = *config.Target
+	}
+	fields := config.Fields
+	if target != "" {
+		fields = common.MapStr{}
+		fields.Put(target, config.Fields)
+	}
+	return &addFields{fields: fields}, nil
+}
+
+func (f *addFields) Run(event *beat.Event) (*beat.Event, error) {
+	if event.Fields == nil {
+		event.Fields = common.MapStr{}
+	}
+	event.Fields.DeepUpdate(f.fields.Clone())
+	return event, nil
+}
+
+func (f *addFields) String() string {
+	keys := make([]string, 0, len(f.fields))
+	for k := range f.fields.Flatten() {
+		keys = append(keys, k)
+	}
+	return "add_fields=" + strings.Join(keys, ", ")
+}
@@ -0,0 +1,71 @@
+package beater
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/processors"
+)
+
+func TestAddFields(t *testing.T) {
+	tests := []struct {
+		config   map[string]interface{}
+		expected common.MapStr
+	}{
+		{
+			config: map[string]interface{}{"fields": map[string]interface{}{"env": "staging"}},
+			expected: common.MapStr{
+				"processor": common.MapStr{"name": "error"},
+				"fields":    common.MapStr{"env": "staging"},
+			},
+		},
+		{
+			config: map[string]interface{}{"target": "", "fields": map[string]interface{}{"env": "staging"}},
+			expected: common.MapStr{
+				"processor": common.MapStr{"name": "error"},
+				"env":       "staging",
+			},
+		},
+		{
+			config: map[string]interface{}{"target": "processor", "fields": map[string]interface{}{"env": "staging"}},
+			expected: common.MapStr{
+				"processor": common.MapStr{"name": "error", "env": "staging"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		cfg, err := common.NewConfigFrom(test.config)
+		assert.NoError(t, err)
+		p, err := newAddFields(cfg)
+		assert.NoError(t, err)
+
+		event := &beat.Event{Fields: common.MapStr{"processor": common.MapStr{"name": "error"}}}
+		event, err = p.Run(event)
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, event.Fields)
+	}
+}
+
+func TestAddFieldsRegistered(t *testing.T) {
+	cfg, err := common.NewConfigFrom(map[string]interface{}{
+		"fields": map[string]interface{}{"env": "staging"},
+		"when":   map[string]interface{}{"equals": map[string]interface{}{"processor.event": "error"}},
+	})
+	assert.NoError(t, err)
+	procs, err := processors.New(processors.PluginConfig{{"add_fields": cfg}})
+	assert.NoError(t, err)
+
+	event := procs.Run(&beat.Event{Fields: common.MapStr{"processor": common.MapStr{"event": "transaction"}}})
+	assert.NotContains(t, event.Fields, "fields")
+	event = procs.Run(&beat.Event{Fields: common.MapStr{"processor": common.MapStr{"event": "error"}}})
+	assert.Equal(t, common.MapStr{"env": "staging"}, event.Fields["fields"])
+}
+
+func TestAddFieldsRequiresFields(t *testing.T) {
+	_, err := newAddFields(common.NewConfig())
+	assert.Error(t, err)
+}
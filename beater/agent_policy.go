@@ -0,0 +1,68 @@
+package beater
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+var agentDenied = monitoring.NewInt(serverMetrics, "requests.agent_denied")
+
+// agentDeniedError is returned for payloads sent by agents excluded by the
+// configured agent policy.
+type agentDeniedError struct {
+	name, version string
+}
+
+func (e *agentDeniedError) Error() string {
+	return fmt.Sprintf("data sent by agent %s %s is not accepted by this server, please upgrade the agent", e.name, e.version)
+}
+
+// agentPolicyReporter returns a reporter rejecting events from agents that
+// match a deny pattern, or that do not match any allow pattern if any are
+// configured. Patterns are an agent name, optionally followed by a slash and
+// a version, and can contain wildcards, e.g. `python/1.0.*`. This allows to
+// stop ingesting data from agent releases known to send corrupt data.
+func agentPolicyReporter(config AgentPolicyConfig, report reporter) reporter {
+	if len(config.Allow) == 0 && len(config.Deny) == 0 {
+		return report
+	}
+	return func(events []beat.Event) error {
+		for _, event := range events {
+			name, _ := event.Fields.GetValue("context.app.agent.name")
+			version, _ := event.Fields.GetValue("context.app.agent.version")
+			n, _ := name.(string)
+			v, _ := version.(string)
+			if !config.accepts(n, v) {
+				agentDenied.Inc()
+				return &agentDeniedError{name: n, version: v}
+			}
+		}
+		return report(events)
+	}
+}
+
+func (c *AgentPolicyConfig) accepts(name, version string) bool {
+	if matchesAgent(c.Deny, name, version) {
+		return false
+	}
+	return len(c.Allow) == 0 || matchesAgent(c.Allow, name, version)
+}
+
+func matchesAgent(patterns []string, name, version string) bool {
+	for _, p := range patterns {
+		namePattern, versionPattern := p, "*"
+		if idx := strings.Index(p, "/"); idx >= 0 {
+			namePattern, versionPattern = p[:idx], p[idx+1:]
+		}
+		nameMatch, _ := path.Match(namePattern, name)
+		versionMatch, _ := path.Match(versionPattern, version)
+		if nameMatch && versionMatch {
+			return true
+		}
+	}
+	return false
+}
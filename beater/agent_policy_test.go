@@ -0,0 +1,73 @@
+package beater
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/apm-server/tests"
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestAgentPolicyAccepts(t *testing.T) {
+	for idx, test := range []struct {
+		config   AgentPolicyConfig
+		name     string
+		version  string
+		accepted bool
+	}{
+		{config: AgentPolicyConfig{}, name: "python", version: "1.0.1", accepted: true},
+		{config: AgentPolicyConfig{Deny: []string{"python/1.0.*"}}, name: "python", version: "1.0.1", accepted: false},
+		{config: AgentPolicyConfig{Deny: []string{"python/1.0.*"}}, name: "python", version: "1.1.0", accepted: true},
+		{config: AgentPolicyConfig{Deny: []string{"python"}}, name: "python", version: "2.0", accepted: false},
+		{config: AgentPolicyConfig{Allow: []string{"nodejs", "python/2.*"}}, name: "python", version: "1.0", accepted: false},
+		{config: AgentPolicyConfig{Allow: []string{"nodejs", "python/2.*"}}, name: "python", version: "2.3", accepted: true},
+		{config: AgentPolicyConfig{Allow: []string{"nodejs", "python/2.*"}}, name: "nodejs", version: "0.1", accepted: true},
+		{config: AgentPolicyConfig{Allow: []string{"*"}, Deny: []string{"ruby/*"}}, name: "ruby", version: "1.0", accepted: false},
+	} {
+		assert.Equal(t, test.accepted, test.config.accepts(test.name, test.version), "Failed at idx %v", idx)
+	}
+}
+
+func TestAgentPolicyReporter(t *testing.T) {
+	var reported []beat.Event
+	report := func(events []beat.Event) error {
+		reported = events
+		return nil
+	}
+	config := AgentPolicyConfig{Deny: []string{"python/1.0.*"}}
+
+	err := agentPolicyReporter(config, report)([]beat.Event{agentEvent("python", "1.0.1")})
+	assert.Equal(t, &agentDeniedError{name: "python", version: "1.0.1"}, err)
+	assert.Nil(t, reported)
+
+	events := []beat.Event{agentEvent("python", "1.1.0"), {Fields: common.MapStr{}}}
+	assert.NoError(t, agentPolicyReporter(config, report)(events))
+	assert.Equal(t, events, reported)
+}
+
+func TestAgentPolicyDeniedResponse(t *testing.T) {
+	payload, err := tests.LoadValidData("error")
+	assert.NoError(t, err)
+
+	config := defaultConfig
+	config.Agents = AgentPolicyConfig{Deny: []string{"elastic-node"}}
+	mux := newMuxer(config, transformReporter(config, nil, nil, func(_ []beat.Event) error { return nil }))
+
+	req, err := http.NewRequest("POST", BackendErrorsURL, bytes.NewReader(payload))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "elastic-node")
+}
+
+func TestAgentPolicyConfigValidate(t *testing.T) {
+	assert.NoError(t, (&AgentPolicyConfig{Allow: []string{"python/1.*"}, Deny: []string{"nodejs"}}).Validate())
+	assert.Error(t, (&AgentPolicyConfig{Deny: []string{"python/[1"}}).Validate())
+}
@@ -0,0 +1,143 @@
+package beater
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/hashicorp/golang-lru"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+const agentStatsCacheSize = 200
+
+var (
+	deprecatedRequests = monitoring.NewInt(serverMetrics, "requests.deprecated")
+
+	// routeAgentStats counts the requests per route and agent. It is reported
+	// as apm-server.server.agents.<route>.<agent name>.<agent version>,
+	// allowing to find the agents still sending data to a route before it is
+	// turned off.
+	routeAgentStats = newAgentStats(agentStatsCacheSize)
+
+	// unknownFieldStats counts the payloads containing a field not allowed by
+	// the schema per field and agent. It is reported as
+	// apm-server.server.unknown_fields.<field>.<agent name>.<agent version>,
+	// showing which fields sent by new agents are rejected by the server. Dots
+	// and slashes in the keys are replaced by underscores, see metricsKey.
+	unknownFieldStats = newAgentStats(agentStatsCacheSize)
+
+	metricsKeyReplacer = strings.NewReplacer(".", "_", "/", "_")
+)
+
+func init() {
+	monitoring.NewFunc(serverMetrics, "agents", routeAgentStats.visit)
+	monitoring.NewFunc(serverMetrics, "unknown_fields", unknownFieldStats.visit)
+}
+
+// agentStats holds the counters of the most recently active combinations of
+// scope, e.g. the route, agent name and agent version.
+type agentStats struct {
+	mu    sync.Mutex
+	cache *lru.Cache
+}
+
+type agentKey struct {
+	scope, name, version string
+}
+
+func newAgentStats(size int) *agentStats {
+	cache, _ := lru.New(size)
+	return &agentStats{cache: cache}
+}
+
+// request counts a request to the given route, the agent is read from the
+// first of the transformed events.
+func (s *agentStats) request(route string, events []beat.Event) {
+	if len(events) == 0 {
+		return
+	}
+	key := agentKey{scope: route, name: "unknown", version: "unknown"}
+	if name, _ := events[0].Fields.GetValue("context.app.agent.name"); name != nil {
+		if n, ok := name.(string); ok && n != "" {
+			key.name = n
+		}
+	}
+	if version, _ := events[0].Fields.GetValue("context.app.agent.version"); version != nil {
+		if v, ok := version.(string); ok && v != "" {
+			key.version = v
+		}
+	}
+	s.count(key)
+}
+
+// count increments the counter of the given combination by one.
+func (s *agentStats) count(key agentKey) {
+	key = agentKey{scope: metricsKey(key.scope), name: metricsKey(key.name), version: metricsKey(key.version)}
+	s.mu.Lock()
+	v, ok := s.cache.Get(key)
+	if !ok {
+		v = new(int64)
+		s.cache.Add(key, v)
+	}
+	s.mu.Unlock()
+	atomic.AddInt64(v.(*int64), 1)
+}
+
+func (s *agentStats) visit(m monitoring.Mode, vs monitoring.Visitor) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := map[string]map[string]map[string]int64{}
+	for _, k := range s.cache.Keys() {
+		v, ok := s.cache.Peek(k)
+		if !ok {
+			continue
+		}
+		key := k.(agentKey)
+		if counts[key.scope] == nil {
+			counts[key.scope] = map[string]map[string]int64{}
+		}
+		if counts[key.scope][key.name] == nil {
+			counts[key.scope][key.name] = map[string]int64{}
+		}
+		counts[key.scope][key.name][key.version] = atomic.LoadInt64(v.(*int64))
+	}
+
+	vs.OnRegistryStart()
+	defer vs.OnRegistryFinished()
+	for scope, agents := range counts {
+		monitoring.ReportNamespace(vs, scope, func() {
+			for name, versions := range agents {
+				monitoring.ReportNamespace(vs, name, func() {
+					for version, n := range versions {
+						monitoring.ReportInt(vs, version, n)
+					}
+				})
+			}
+		})
+	}
+}
+
+// metricsKey turns a value taken from a request, like a route, agent version
+// or service name, into a single monitoring namespace. Dots and slashes would
+// otherwise nest the value into further namespaces, e.g. version 1.0 into
+// 1 and 0, and clash with the registered metrics.
+func metricsKey(s string) string {
+	return metricsKeyReplacer.Replace(strings.Trim(s, "/"))
+}
+
+// deprecationHandler adds a Warning header to the responses of routes that
+// are marked as deprecated in the config, so agents can surface that they
+// need to be upgraded before the route is turned off.
+func deprecationHandler(path string, h http.Handler) http.Handler {
+	warning := `299 apm-server "` + strings.Replace(path, `"`, "", -1) + ` is deprecated and will be turned off, please upgrade the agent"`
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deprecatedRequests.Inc()
+		w.Header().Set("Warning", warning)
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,70 @@
+package beater
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+func agentEvent(name, version string) beat.Event {
+	return beat.Event{Fields: common.MapStr{"context": common.MapStr{"app": common.MapStr{
+		"agent": common.MapStr{"name": name, "version": version},
+	}}}}
+}
+
+func TestAgentStats(t *testing.T) {
+	stats := newAgentStats(10)
+	stats.request("/v1/errors", []beat.Event{agentEvent("python", "1.0"), agentEvent("python", "1.0")})
+	stats.request("/v1/errors", []beat.Event{agentEvent("python", "1.0")})
+	stats.request("/v1/errors", []beat.Event{agentEvent("nodejs", "2.1")})
+	stats.request("/v1/transactions", []beat.Event{agentEvent("python", "1.0")})
+	stats.request("/v1/transactions", []beat.Event{{Fields: common.MapStr{}}})
+	stats.request("/v1/transactions", nil)
+
+	r := monitoring.NewRegistry()
+	monitoring.NewFunc(r, "agents", stats.visit)
+	snapshot := monitoring.CollectStructSnapshot(r, monitoring.Full, false)
+
+	assert.Equal(t, map[string]interface{}{
+		"agents": map[string]interface{}{
+			"v1_errors": map[string]interface{}{
+				"python": map[string]interface{}{"1_0": int64(2)},
+				"nodejs": map[string]interface{}{"2_1": int64(1)},
+			},
+			"v1_transactions": map[string]interface{}{
+				"python":  map[string]interface{}{"1_0": int64(1)},
+				"unknown": map[string]interface{}{"unknown": int64(1)},
+			},
+		},
+	}, snapshot)
+}
+
+func TestDeprecatedRoutes(t *testing.T) {
+	config := defaultConfig
+	config.DeprecatedRoutes = []string{"/v1/client-side/"}
+	mux := newMuxer(config, nil)
+
+	before := deprecatedRequests.Get()
+	for path, deprecated := range map[string]bool{
+		FrontendErrorsURL: true,
+		BackendErrorsURL:  false,
+	} {
+		req, err := http.NewRequest("GET", path, bytes.NewReader(nil))
+		assert.NoError(t, err)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if deprecated {
+			assert.Contains(t, w.Header().Get("Warning"), FrontendErrorsURL+" is deprecated")
+		} else {
+			assert.Empty(t, w.Header().Get("Warning"))
+		}
+	}
+	assert.Equal(t, before+1, deprecatedRequests.Get())
+}
@@ -0,0 +1,46 @@
+package beater
+
+import (
+	"fmt"
+
+	"github.com/elastic/apm-server/processor"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// EnricherConfig lists the enrichers to call for every event, each given as
+// its name mapped to its config.
+type EnricherConfig []map[string]*common.Config
+
+// appOwner is an enricher tagging all events of an app with the team owning
+// the app, configured as a map of app names to owners. It serves as an example
+// for enrichers adding organization specific fields.
+type appOwner struct {
+	tag    string
+	owners map[string]string
+}
+
+func init() {
+	processor.RegisterEnricher("app_owner", newAppOwner)
+}
+
+func newAppOwner(c *common.Config) (processor.Enricher, error) {
+	config := struct {
+		Tag    string            `config:"tag"`
+		Owners map[string]string `config:"owners" validate:"required"`
+	}{Tag: "owner"}
+	if err := c.Unpack(&config); err != nil {
+		return nil, fmt.Errorf("fail to unpack the app_owner configuration: %s", err)
+	}
+	return &appOwner{tag: config.Tag, owners: config.Owners}, nil
+}
+
+func (o *appOwner) Enrich(_ interface{}, meta processor.Metadata) common.MapStr {
+	if meta.App == nil {
+		return nil
+	}
+	owner, ok := o.owners[meta.App.Name]
+	if !ok {
+		return nil
+	}
+	return common.MapStr{"context.tags." + o.tag: owner}
+}
@@ -0,0 +1,49 @@
+package beater
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/apm-server/processor"
+	"github.com/elastic/apm-server/processor/model"
+	"github.com/elastic/apm-server/processor/transaction"
+	"github.com/elastic/apm-server/tests"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestAppOwnerEnricher(t *testing.T) {
+	cfg, err := common.NewConfigFrom(map[string]interface{}{
+		"owners": map[string]interface{}{"1234_app-12a3": "team-a"},
+	})
+	assert.NoError(t, err)
+	config := defaultConfig
+	config.Enrichers = EnricherConfig{{"app_owner": cfg}}
+	assert.NoError(t, config.setupEnrichers())
+
+	payload, err := tests.LoadValidData("transaction")
+	assert.NoError(t, err)
+	events, err := transaction.NewProcessor(config.processorConfig()).Transform(payload)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, events)
+	for _, event := range events {
+		owner, err := event.Fields.GetValue("context.tags.owner")
+		assert.NoError(t, err)
+		assert.Equal(t, "team-a", owner)
+	}
+}
+
+func TestAppOwnerEnricherConfig(t *testing.T) {
+	_, err := newAppOwner(common.NewConfig())
+	assert.Error(t, err)
+
+	cfg, err := common.NewConfigFrom(map[string]interface{}{
+		"tag":    "team",
+		"owners": map[string]interface{}{"app": "team-a"},
+	})
+	assert.NoError(t, err)
+	e, err := newAppOwner(cfg)
+	assert.NoError(t, err)
+	assert.Nil(t, e.Enrich(nil, processor.Metadata{App: nil}))
+	assert.Equal(t, common.MapStr{"context.tags.team": "team-a"}, e.Enrich(nil, processor.Metadata{App: &model.App{Name: "app"}}))
+}
@@ -7,22 +7,41 @@ import (
 	"github.com/elastic/beats/libbeat/beat"
 	"github.com/elastic/beats/libbeat/common"
 	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/monitoring"
+	"github.com/elastic/beats/libbeat/paths"
 )
 
 type beater struct {
-	config Config
-	server *http.Server
+	config    Config
+	dedup     *deduplicator
+	quotas    *quotaTracker
+	listeners []listener
 }
 
 // Creates beater
-func New(_ *beat.Beat, ucfg *common.Config) (beat.Beater, error) {
+func New(b *beat.Beat, ucfg *common.Config) (beat.Beater, error) {
 	beaterConfig := defaultConfig
 	if err := ucfg.Unpack(&beaterConfig); err != nil {
 		return nil, fmt.Errorf("Error reading config file: %v", err)
 	}
+	if err := checkConfig(beaterConfig); err != nil {
+		return nil, err
+	}
+	if b != nil && b.Config != nil {
+		if err := checkOutput(b.Config, beaterConfig); err != nil {
+			return nil, err
+		}
+		registerTemplateCheck(b.Info, b.Config.Output, beaterConfig.TemplateCheck)
+		watchOutputFailed(monitoring.Default, b.Config.Output.Name())
+	}
+	if err := beaterConfig.setupEnrichers(); err != nil {
+		return nil, err
+	}
 
 	bt := &beater{
 		config: beaterConfig,
+		dedup:  newDeduplicator(beaterConfig.Dedup),
+		quotas: newQuotaTracker(beaterConfig.Quotas),
 	}
 	return bt, nil
 }
@@ -30,26 +49,65 @@ func New(_ *beat.Beat, ucfg *common.Config) (beat.Beater, error) {
 func (bt *beater) Run(b *beat.Beat) error {
 	var err error
 
-	pub, err := newPublisher(b.Publisher, bt.config.ConcurrentRequests)
+	pub, err := newSink(bt.config, b.Publisher)
 	if err != nil {
 		return err
 	}
 	defer pub.Stop()
+	defer bt.dedup.close()
 
-	go notifyListening(bt.config, pub.Send)
+	go notifyListening(b.Info, bt.config, paths.Resolve(paths.Data, onboardingFile), pub.Send)
 
-	bt.server = newServer(bt.config, pub.Send)
+	bt.listeners = newListeners(bt.config, bt.quotas, decorateReporter(b.Info, bt.config, bt.dedup, bt.quotas, pub.Send))
 
-	err = run(bt.server, bt.config)
+	logp.Info("Starting apm-server! Hit CTRL-C to stop it.")
+	errs := make(chan error, len(bt.listeners))
+	for _, l := range bt.listeners {
+		go func(l listener) {
+			errs <- run(l.server, l.config)
+		}(l)
+	}
+	err = <-errs
 	if err == http.ErrServerClosed {
 		logp.Info("Listener stopped: %s", err.Error())
 		return nil
 	}
+	// don't keep serving part of the routes if a listener failed
+	bt.Stop()
 	return err
 }
 
+// decorateReporter wraps report with the reporters modifying events before
+// they are published.
+func decorateReporter(info beat.Info, config Config, dedup *deduplicator, quotas *quotaTracker, report reporter) reporter {
+	return observerReporter(info, config, transformReporter(config, dedup, quotas, report))
+}
+
+// transformReporter wraps report with the reporters changing or rejecting the
+// transformed documents according to the config. Duplicates are only dropped
+// if dedup is set and quotas only counted if quotas is set, the debug routes
+// don't publish events and must neither remember their ids nor count them.
+// Quotas are checked last, after events were sampled and deduplicated.
+func transformReporter(config Config, dedup *deduplicator, quotas *quotaTracker, report reporter) reporter {
+	report = quotas.reporter(report)
+	report = contextLimitReporter(config.ContextLimits, report)
+	report = contextMappingReporter(config.ContextMapping, report)
+	report = globalLabelsReporter(config.GlobalLabels, report)
+	report = samplingReporter(config.Sampling, report)
+	report = traceStacktraceReporter(config.Traces.StacktraceMinDuration, report)
+	report = dedup.reporter(report)
+	return agentPolicyReporter(config.Agents, report)
+}
+
 // Graceful shutdown
 func (bt *beater) Stop() {
 	logp.Info("stopping apm-server...")
-	stop(bt.server, bt.config.ShutdownTimeout)
+	for _, l := range bt.listeners {
+		stop(l.server, bt.config.ShutdownTimeout)
+	}
+	// WebSocket connections and exec filter processes outlive the servers,
+	// closing the muxer shared by the listeners more than once is harmless
+	for _, l := range bt.listeners {
+		l.mux.close()
+	}
 }
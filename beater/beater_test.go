@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/elastic/apm-server/tests"
 	"github.com/elastic/beats/libbeat/beat"
@@ -54,7 +55,7 @@ func (d *DummyOutputClient) Close() error {
 	return nil
 }
 
-func SetupServer(b *testing.B) *http.ServeMux {
+func SetupServer(b *testing.B) http.Handler {
 	out := outputs.Group{
 		Clients:   []outputs.Client{&DummyOutputClient{}},
 		BatchSize: 5,
@@ -80,7 +81,7 @@ func SetupServer(b *testing.B) *http.ServeMux {
 		b.Fatalf("error initializing publisher: %v", err)
 	}
 
-	pub, err := newPublisher(pip, 1)
+	pub, err := newPublisher(pip, 1, time.Second)
 
 	if err != nil {
 		b.Fatal(err)
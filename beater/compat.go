@@ -0,0 +1,153 @@
+package beater
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/elastic/apm-server/processor"
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+var compatFixedFields = monitoring.NewInt(serverMetrics, "compatibility.fixed_fields")
+
+// CompatRuleConfig fixes a field in the payloads of the agents matching the
+// agent pattern, an agent name optionally followed by a slash and a version,
+// which can contain wildcards like for agents.allow. Fields are given by
+// their dotted path in the payload, lists are traversed, e.g.
+// `transactions.spans.duration`. A field is either scaled, e.g. to fix
+// durations sent in the wrong unit, or set to a default if missing, e.g. for
+// fields not sent by old agent releases.
+type CompatRuleConfig struct {
+	Agent   string      `config:"agent" validate:"required"`
+	Field   string      `config:"field" validate:"required"`
+	Scale   float64     `config:"scale"`
+	Default interface{} `config:"default"`
+}
+
+func (c *CompatRuleConfig) Validate() error {
+	if (c.Scale == 0) == (c.Default == nil) {
+		return fmt.Errorf("compatibility rule for field '%s' must set either scale or default", c.Field)
+	}
+	policy := AgentPolicyConfig{Allow: []string{c.Agent}}
+	return policy.Validate()
+}
+
+// compatFactory returns a factory for processors applying the compatibility
+// rules to payloads before they are validated.
+func compatFactory(rules []CompatRuleConfig, pf ProcessorFactory) ProcessorFactory {
+	if len(rules) == 0 {
+		return pf
+	}
+	return func(config processor.Config) processor.Processor {
+		return &compatProcessor{Processor: pf(config), rules: rules}
+	}
+}
+
+// compatProcessor keeps the fixed payload for Transform, which is called
+// with the same payload as Validate.
+type compatProcessor struct {
+	processor.Processor
+	rules []CompatRuleConfig
+	fixed []byte
+}
+
+func (p *compatProcessor) Validate(buf []byte) error {
+	fixed, err := applyCompatRules(p.rules, buf)
+	if err != nil {
+		return err
+	}
+	p.fixed = fixed
+	return p.Processor.Validate(fixed)
+}
+
+func (p *compatProcessor) Transform(buf []byte) ([]beat.Event, error) {
+	if p.fixed != nil {
+		buf = p.fixed
+	}
+	return p.Processor.Transform(buf)
+}
+
+// applyCompatRules returns the payload with the rules matching the agent that
+// sent it applied. Payloads that no rule applies to are returned unchanged,
+// invalid JSON is left for validation to report.
+func applyCompatRules(rules []CompatRuleConfig, buf []byte) ([]byte, error) {
+	var agent struct {
+		App struct {
+			Agent struct {
+				Name    string `json:"name"`
+				Version string `json:"version"`
+			} `json:"agent"`
+		} `json:"app"`
+	}
+	if err := json.Unmarshal(buf, &agent); err != nil {
+		return buf, nil
+	}
+	var matching []CompatRuleConfig
+	for _, rule := range rules {
+		if matchesAgent([]string{rule.Agent}, agent.App.Agent.Name, agent.App.Agent.Version) {
+			matching = append(matching, rule)
+		}
+	}
+	if len(matching) == 0 {
+		return buf, nil
+	}
+
+	var payload map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(buf))
+	decoder.UseNumber()
+	if err := decoder.Decode(&payload); err != nil {
+		return buf, nil
+	}
+	fixed := 0
+	for _, rule := range matching {
+		fixed += applyCompatRule(rule, payload, strings.Split(rule.Field, "."))
+	}
+	if fixed == 0 {
+		return buf, nil
+	}
+	compatFixedFields.Add(int64(fixed))
+	return json.Marshal(payload)
+}
+
+// applyCompatRule applies the rule to the field at path within obj, returning
+// the number of fields changed.
+func applyCompatRule(rule CompatRuleConfig, obj map[string]interface{}, path []string) int {
+	key := path[0]
+	value, ok := obj[key]
+	if len(path) > 1 {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			return applyCompatRule(rule, v, path[1:])
+		case []interface{}:
+			fixed := 0
+			for _, item := range v {
+				if m, ok := item.(map[string]interface{}); ok {
+					fixed += applyCompatRule(rule, m, path[1:])
+				}
+			}
+			return fixed
+		}
+		return 0
+	}
+
+	if rule.Default != nil {
+		if ok && value != nil {
+			return 0
+		}
+		obj[key] = rule.Default
+		return 1
+	}
+	number, ok := value.(json.Number)
+	if !ok {
+		return 0
+	}
+	f, err := number.Float64()
+	if err != nil {
+		return 0
+	}
+	obj[key] = f * rule.Scale
+	return 1
+}
@@ -0,0 +1,114 @@
+package beater
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/apm-server/processor"
+	"github.com/elastic/apm-server/processor/transaction"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func compatPayload(agent, version string) []byte {
+	return []byte(`{"app": {"name": "a", "agent": {"name": "` + agent + `", "version": "` + version + `"}},
+		"transactions": [{"duration": 1.5, "spans": [{"duration": 0.5}, {"duration": 2, "type": "db"}]}, {"type": "job"}]}`)
+}
+
+func TestApplyCompatRules(t *testing.T) {
+	rules := []CompatRuleConfig{
+		{Agent: "nodejs/1.0.*", Field: "transactions.duration", Scale: 1000},
+		{Agent: "nodejs/1.0.*", Field: "transactions.spans.duration", Scale: 1000},
+		{Agent: "nodejs", Field: "transactions.type", Default: "request"},
+		{Agent: "nodejs", Field: "transactions.spans.type", Default: "custom"},
+	}
+
+	fixed, err := applyCompatRules(rules, compatPayload("nodejs", "1.0.2"))
+	assert.NoError(t, err)
+	var payload common.MapStr
+	assert.NoError(t, json.Unmarshal(fixed, &payload))
+	txs := payload["transactions"].([]interface{})
+	tx := txs[0].(map[string]interface{})
+	assert.Equal(t, 1500.0, tx["duration"])
+	assert.Equal(t, "request", tx["type"])
+	spans := tx["spans"].([]interface{})
+	assert.Equal(t, map[string]interface{}{"duration": 500.0, "type": "custom"}, spans[0])
+	assert.Equal(t, map[string]interface{}{"duration": 2000.0, "type": "db"}, spans[1])
+	assert.Equal(t, "job", txs[1].(map[string]interface{})["type"])
+
+	// the payloads of other agents are left unchanged
+	scaling := rules[:2]
+	for _, agent := range [][]string{{"nodejs", "1.1.0"}, {"python", "1.0.0"}} {
+		payload := compatPayload(agent[0], agent[1])
+		fixed, err := applyCompatRules(scaling, payload)
+		assert.NoError(t, err)
+		assert.Equal(t, payload, fixed)
+	}
+
+	invalid := []byte(`{"app":`)
+	fixed, err = applyCompatRules(rules, invalid)
+	assert.NoError(t, err)
+	assert.Equal(t, invalid, fixed)
+}
+
+func TestCompatProcessor(t *testing.T) {
+	rules := []CompatRuleConfig{{Agent: "*", Field: "transactions.result", Default: "unknown"}}
+	p := compatFactory(rules, transaction.NewProcessor)(processor.DefaultConfig())
+	payload := []byte(`{"app": {"name": "a", "agent": {"name": "go", "version": "1.0"}},
+		"transactions": [{"id": "945254c5-67a5-417e-8a4e-aa29efcbfb79", "name": "GET /", "type": "request",
+		"duration": 1, "timestamp": "2017-05-30T18:53:27.154Z"}]}`)
+	assert.NoError(t, p.Validate(payload))
+	events, err := p.Transform(payload)
+	assert.NoError(t, err)
+	result, _ := events[0].Fields.GetValue("transaction.result")
+	assert.Equal(t, "unknown", result)
+
+	assert.Equal(t, transaction.NewProcessor(processor.DefaultConfig()), compatFactory(nil, transaction.NewProcessor)(processor.DefaultConfig()))
+}
+
+func TestCompatRulesDebugEndpoint(t *testing.T) {
+	enabled := true
+	config := defaultConfig
+	config.DebugEndpoint = &DebugEndpointConfig{Enabled: &enabled}
+	config.Compatibility = []CompatRuleConfig{{Agent: "go", Field: "transactions.result", Default: "unknown"}}
+	mux := newMuxer(config, nil)
+
+	payload := []byte(`{"app": {"name": "a", "agent": {"name": "go", "version": "1.0"}},
+		"transactions": [{"id": "945254c5-67a5-417e-8a4e-aa29efcbfb79", "name": "GET /", "type": "request",
+		"duration": 1, "timestamp": "2017-05-30T18:53:27.154Z"}]}`)
+	req, err := http.NewRequest("POST", DebugTransformURL+BackendTransactionsURL, bytes.NewReader(payload))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var body struct {
+		Events []common.MapStr `json:"events"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	if assert.Len(t, body.Events, 1) {
+		result, _ := body.Events[0].GetValue("transaction.result")
+		assert.Equal(t, "unknown", result)
+	}
+}
+
+func TestCompatRuleConfigValidate(t *testing.T) {
+	for _, c := range []CompatRuleConfig{
+		{Agent: "nodejs/1.*", Field: "transactions.duration", Scale: 1000},
+		{Agent: "nodejs", Field: "transactions.type", Default: "request"},
+	} {
+		assert.NoError(t, c.Validate())
+	}
+	for _, c := range []CompatRuleConfig{
+		{Agent: "nodejs", Field: "transactions.duration"},
+		{Agent: "nodejs", Field: "transactions.duration", Scale: 1000, Default: 1},
+		{Agent: "[", Field: "transactions.duration", Scale: 1000},
+	} {
+		assert.Error(t, c.Validate())
+	}
+}
@@ -0,0 +1,135 @@
+package beater
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+// Factor applied to the concurrency limit when the publisher is overloaded.
+const concurrencyBackoff = 0.75
+
+var (
+	concurrencyLimit    = monitoring.NewInt(serverMetrics, "concurrency.limit")
+	concurrencyInFlight = monitoring.NewInt(serverMetrics, "concurrency.in_flight")
+	concurrencyRejected = monitoring.NewInt(serverMetrics, "requests.concurrency_rejected")
+
+	errOverloaded = errors.New("too many concurrent requests")
+)
+
+type ConcurrencyConfig struct {
+	Adaptive      bool          `config:"adaptive"`
+	Min           int           `config:"min"`
+	Max           int           `config:"max"`
+	TargetLatency time.Duration `config:"target_latency"`
+}
+
+func (c *ConcurrencyConfig) Validate() error {
+	if c.Min < 1 || c.Max < c.Min {
+		return fmt.Errorf("invalid concurrency limits min=%d, max=%d, min must be at least 1 and not above max", c.Min, c.Max)
+	}
+	return nil
+}
+
+// adaptiveLimiter limits the number of requests processed concurrently. The
+// limit is adjusted based on how long it takes to hand the events of a
+// request over to the publisher: it is increased by one for every limit
+// requests published within the target latency, and reduced by a quarter
+// whenever publishing takes longer or the queue is full. This lets beefy
+// hosts process more requests in parallel, while small ones shed load before
+// the queue fills up.
+type adaptiveLimiter struct {
+	config ConcurrencyConfig
+
+	mu       sync.Mutex
+	limit    float64
+	inFlight int
+}
+
+func newAdaptiveLimiter(config ConcurrencyConfig, initial int) *adaptiveLimiter {
+	l := &adaptiveLimiter{config: config}
+	l.setLimit(float64(initial))
+	return l
+}
+
+// setLimit must be called with the lock held.
+func (l *adaptiveLimiter) setLimit(limit float64) {
+	l.limit = math.Min(math.Max(limit, float64(l.config.Min)), float64(l.config.Max))
+	concurrencyLimit.Set(int64(l.limit))
+}
+
+// acquire reserves a slot for processing a request, unless the limit is
+// reached. A nil limiter doesn't limit requests.
+func (l *adaptiveLimiter) acquire() bool {
+	if l == nil {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlight >= int(l.limit) {
+		return false
+	}
+	l.inFlight++
+	concurrencyInFlight.Set(int64(l.inFlight))
+	return true
+}
+
+func (l *adaptiveLimiter) release() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+	concurrencyInFlight.Set(int64(l.inFlight))
+}
+
+// record adjusts the limit to the outcome of publishing a batch of events.
+func (l *adaptiveLimiter) record(latency time.Duration, err error) {
+	if e, ok := err.(*retryAfterError); ok {
+		err = e.error
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err == errFull || latency > l.config.TargetLatency {
+		l.setLimit(l.limit * concurrencyBackoff)
+	} else if err == nil {
+		l.setLimit(l.limit + 1/l.limit)
+	}
+}
+
+// handler rejects requests with 503 while the limit is reached.
+func (l *adaptiveLimiter) handler(h http.Handler) http.Handler {
+	if l == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !l.acquire() {
+			concurrencyRejected.Inc()
+			sendStatus(w, r, http.StatusServiceUnavailable, &retryAfterError{errOverloaded, time.Second})
+			return
+		}
+		defer l.release()
+		h.ServeHTTP(w, r)
+	})
+}
+
+// reporter returns a reporter measuring how long report takes, to adjust the
+// limit.
+func (l *adaptiveLimiter) reporter(report reporter) reporter {
+	if l == nil {
+		return report
+	}
+	return func(events []beat.Event) error {
+		start := time.Now()
+		err := report(events)
+		l.record(time.Since(start), err)
+		return err
+	}
+}
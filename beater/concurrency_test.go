@@ -0,0 +1,83 @@
+package beater
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+)
+
+func testConcurrencyConfig() ConcurrencyConfig {
+	return ConcurrencyConfig{Adaptive: true, Min: 2, Max: 10, TargetLatency: 10 * time.Millisecond}
+}
+
+func TestAdaptiveLimiterAIMD(t *testing.T) {
+	l := newAdaptiveLimiter(testConcurrencyConfig(), 4)
+	assert.Equal(t, 4.0, l.limit)
+
+	// additive increase by one per limit successes
+	for i := 0; i < 4; i++ {
+		l.record(time.Millisecond, nil)
+	}
+	assert.InDelta(t, 5.0, l.limit, 0.1)
+
+	// multiplicative decrease on slow publishing and full queues
+	l.record(20*time.Millisecond, nil)
+	assert.InDelta(t, 3.75, l.limit, 0.1)
+	l.record(time.Millisecond, &retryAfterError{errFull, time.Second})
+	assert.InDelta(t, 2.8, l.limit, 0.1)
+
+	// other errors don't change the limit
+	l.record(time.Millisecond, errTimestampOutOfRange)
+	assert.InDelta(t, 2.8, l.limit, 0.1)
+
+	// the limit stays within bounds
+	for i := 0; i < 10; i++ {
+		l.record(time.Second, nil)
+	}
+	assert.Equal(t, 2.0, l.limit)
+	for i := 0; i < 1000; i++ {
+		l.record(0, nil)
+	}
+	assert.Equal(t, 10.0, l.limit)
+	assert.Equal(t, int64(10), concurrencyLimit.Get())
+}
+
+func TestAdaptiveLimiterHandler(t *testing.T) {
+	l := newAdaptiveLimiter(testConcurrencyConfig(), 2)
+	assert.True(t, l.acquire())
+	assert.True(t, l.acquire())
+
+	h := l.handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req, _ := http.NewRequest("POST", "_", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	l.release()
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, l.inFlight)
+}
+
+func TestAdaptiveLimiterReporter(t *testing.T) {
+	l := newAdaptiveLimiter(testConcurrencyConfig(), 4)
+	report := l.reporter(func([]beat.Event) error { return errFull })
+	assert.Equal(t, errFull, report(nil))
+	assert.Equal(t, 3.0, l.limit)
+
+	var disabled *adaptiveLimiter
+	assert.Nil(t, disabled.reporter(nil))
+}
+
+func TestConcurrencyConfigValidate(t *testing.T) {
+	assert.NoError(t, (&ConcurrencyConfig{Min: 1, Max: 1}).Validate())
+	assert.Error(t, (&ConcurrencyConfig{Min: 0, Max: 1}).Validate())
+	assert.Error(t, (&ConcurrencyConfig{Min: 5, Max: 1}).Validate())
+}
@@ -1,50 +1,348 @@
 package beater
 
 import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
 	"time"
+
+	"github.com/elastic/apm-server/processor"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+const (
+	timestampActionReject = "reject"
+	timestampActionClamp  = "clamp"
 )
 
 type Config struct {
-	Host               string          `config:"host"`
-	MaxUnzippedSize    int64           `config:"max_unzipped_size"`
-	MaxHeaderBytes     int             `config:"max_header_bytes"`
-	ReadTimeout        time.Duration   `config:"read_timeout"`
-	WriteTimeout       time.Duration   `config:"write_timeout"`
-	ShutdownTimeout    time.Duration   `config:"shutdown_timeout"`
-	SecretToken        string          `config:"secret_token"`
-	SSL                *SSLConfig      `config:"ssl"`
-	ConcurrentRequests int             `config:"concurrent_requests" validate:"min=1"`
-	Frontend           *FrontendConfig `config:"frontend"`
+	Host                 string                `config:"host"`
+	MaxUnzippedSize      int64                 `config:"max_unzipped_size"`
+	MaxCompressedSize    int64                 `config:"max_compressed_size"`
+	MaxInFlightBytes     int64                 `config:"max_in_flight_bytes"`
+	RequireContentLength bool                  `config:"require_content_length"`
+	ContentLengthAbove   int64                 `config:"require_content_length_above"`
+	MaxHeaderBytes       int                   `config:"max_header_bytes"`
+	ReadTimeout          time.Duration         `config:"read_timeout"`
+	WriteTimeout         time.Duration         `config:"write_timeout"`
+	ShutdownTimeout      time.Duration         `config:"shutdown_timeout"`
+	PublishTimeout       time.Duration         `config:"publish_timeout"`
+	AckTimeout           time.Duration         `config:"ack_timeout"`
+	SecretToken          string                `config:"secret_token"`
+	SSL                  *SSLConfig            `config:"ssl"`
+	ConcurrentRequests   int                   `config:"concurrent_requests" validate:"min=1"`
+	Frontend             *FrontendConfig       `config:"frontend"`
+	Observer             ObserverConfig        `config:"observer"`
+	ClockSkewThreshold   time.Duration         `config:"clock_skew_threshold"`
+	EventTimestamp       TimestampPolicyConfig `config:"event_timestamp"`
+	ContextLimits        ContextLimitsConfig   `config:"context_limits"`
+	ContextMapping       string                `config:"context_mapping"`
+	Logging              LoggingConfig         `config:"logging"`
+	RecordRequests       *RecordConfig         `config:"record_requests"`
+	DebugEndpoint        *DebugEndpointConfig  `config:"debug_endpoint"`
+	Traces               TracesConfig          `config:"traces"`
+	Errors               ErrorsConfig          `config:"errors"`
+	Process              ProcessConfig         `config:"process"`
+	Sampling             SamplingConfig        `config:"sampling"`
+	GlobalLabels         common.MapStr         `config:"global_labels"`
+	DisabledRoutes       []string              `config:"disabled_routes"`
+	DeprecatedRoutes     []string              `config:"deprecated_routes"`
+	ContentTypes         map[string][]string   `config:"content_types"`
+	Agents               AgentPolicyConfig     `config:"agents"`
+	Tenants              []TenantConfig        `config:"tenants"`
+	Quotas               QuotaConfig           `config:"quotas"`
+	Enrichers            EnricherConfig        `config:"enrichers"`
+	ExecFilters          []ExecFilterConfig    `config:"exec_filters"`
+	Concurrency          ConcurrencyConfig     `config:"concurrency"`
+	Dedup                DedupConfig           `config:"dedup"`
+	Idempotency          IdempotencyConfig     `config:"idempotency"`
+	Validation           ValidationConfig      `config:"validation"`
+	Sink                 SinkConfig            `config:"sink"`
+	Pipeline             PipelineConfig        `config:"pipeline"`
+	TemplateCheck        TemplateCheckConfig   `config:"template_check"`
+	Onboarding           OnboardingConfig      `config:"onboarding"`
+	Kibana               KibanaConfig          `config:"kibana"`
+	RUM                  RUMConfig             `config:"rum"`
+	Management           *ManagementConfig     `config:"management"`
+	Compatibility        []CompatRuleConfig    `config:"compatibility"`
+
+	// enrichers are created from Enrichers by setupEnrichers.
+	enrichers []processor.Enricher
 }
 
 type FrontendConfig struct {
-	Enabled      *bool    `config:"enabled"`
-	RateLimit    int      `config:"rate_limit"`
-	AllowOrigins []string `config:"allow_origins"`
+	Enabled         *bool             `config:"enabled"`
+	RateLimit       int               `config:"rate_limit"`
+	AllowOrigins    []string          `config:"allow_origins"`
+	MaxUnzippedSize int64             `config:"max_unzipped_size"`
+	WebSocket       bool              `config:"websocket"`
+	ContentTypes    []string          `config:"content_types"`
+	RateLimiter     RateLimiterConfig `config:"rate_limiter"`
+	DisabledStatus  int               `config:"disabled_status"`
+}
+
+// RUMConfig sets up a separate listener for the frontend routes, e.g. for a
+// public address, while the backend routes are only served on host. The
+// listener uses the ssl settings of host, unless configured separately.
+type RUMConfig struct {
+	Host string     `config:"host"`
+	SSL  *SSLConfig `config:"ssl"`
+}
+
+type ObserverConfig struct {
+	RequestID       bool `config:"request_id"`
+	IngestTimestamp bool `config:"ingest_timestamp"`
+}
+
+type ContextLimitsConfig struct {
+	MaxCustomSize      int `config:"max_custom_size"`
+	MaxRequestBodySize int `config:"max_request_body_size"`
+	MaxDbStatementSize int `config:"max_db_statement_size"`
+}
+
+type LoggingConfig struct {
+	SlowRequestThreshold time.Duration `config:"slow_request_threshold"`
+}
+
+type TracesConfig struct {
+	StacktraceMinDuration time.Duration `config:"stacktrace_min_duration"`
+}
+
+type ErrorsConfig struct {
+	MaxCauseDepth int `config:"max_cause_depth" validate:"min=0"`
+}
+
+type SamplingConfig struct {
+	KeepUnsampled bool               `config:"keep_unsampled"`
+	Rate          float64            `config:"rate"`
+	Services      map[string]float64 `config:"services"`
+}
+
+type AgentPolicyConfig struct {
+	Allow []string `config:"allow"`
+	Deny  []string `config:"deny"`
+}
+
+type RecordConfig struct {
+	Enabled *bool    `config:"enabled"`
+	Path    string   `config:"path"`
+	Routes  []string `config:"routes"`
+	MaxSize int64    `config:"max_size"`
+}
+
+type DebugEndpointConfig struct {
+	Enabled *bool `config:"enabled"`
+}
+
+type TimestampPolicyConfig struct {
+	MaxFuture time.Duration `config:"max_future"`
+	MaxPast   time.Duration `config:"max_past"`
+	Action    string        `config:"action"`
+}
+
+func (c *Config) Validate() error {
+	switch c.ContextMapping {
+	case "", contextMappingDynamic, contextMappingFlattened:
+	default:
+		return fmt.Errorf("invalid context_mapping '%s', must be one of %s, %s",
+			c.ContextMapping, contextMappingDynamic, contextMappingFlattened)
+	}
+	for _, prefix := range c.DisabledRoutes {
+		if !strings.HasPrefix(prefix, "/") {
+			return fmt.Errorf("invalid disabled_routes entry '%s', must start with '/'", prefix)
+		}
+	}
+	for _, prefix := range c.DeprecatedRoutes {
+		if !strings.HasPrefix(prefix, "/") {
+			return fmt.Errorf("invalid deprecated_routes entry '%s', must start with '/'", prefix)
+		}
+	}
+	if c.RUM.Host != "" && c.RUM.Host == c.Host {
+		return fmt.Errorf("rum.host must differ from host '%s'", c.Host)
+	}
+	if c.Management.isEnabled() && (c.Management.Host == c.Host || c.Management.Host == c.RUM.Host) {
+		return fmt.Errorf("management.host must differ from host and rum.host")
+	}
+	if err := validateTenants(c.Tenants); err != nil {
+		return err
+	}
+	return validateGlobalLabels(c.GlobalLabels)
+}
+
+// setupEnrichers creates the configured enrichers, which are passed to the
+// processors with the processor config.
+func (c *Config) setupEnrichers() error {
+	enrichers, err := processor.NewEnrichers(c.Enrichers)
+	if err != nil {
+		return err
+	}
+	c.enrichers = enrichers
+	return nil
+}
+
+// processorConfig returns the settings passed to the processors created for
+// each payload.
+func (c *Config) processorConfig() processor.Config {
+	return processor.Config{Enrichers: c.enrichers, MaxCauseDepth: c.Errors.MaxCauseDepth}
+}
+
+// routeDisabled returns true if the path starts with any of the configured
+// disabled_routes prefixes.
+func (c *Config) routeDisabled(path string) bool {
+	return hasAnyPrefix(path, c.DisabledRoutes)
+}
+
+// routeDeprecated returns true if the path starts with any of the configured
+// deprecated_routes prefixes.
+func (c *Config) routeDeprecated(path string) bool {
+	return hasAnyPrefix(path, c.DeprecatedRoutes)
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *TimestampPolicyConfig) Validate() error {
+	switch c.Action {
+	case "", timestampActionReject, timestampActionClamp:
+		return nil
+	}
+	return fmt.Errorf("invalid event_timestamp.action '%s', must be one of %s, %s",
+		c.Action, timestampActionReject, timestampActionClamp)
+}
+
+func (c *SamplingConfig) Validate() error {
+	if c.Rate < 0 || c.Rate > 1 {
+		return fmt.Errorf("invalid sampling.rate %v, must be between 0 and 1", c.Rate)
+	}
+	for name, rate := range c.Services {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("invalid sampling rate %v for service '%s', must be between 0 and 1", rate, name)
+		}
+	}
+	return nil
+}
+
+// rate returns the sampling rate configured for the given app.
+func (c *SamplingConfig) rate(app string) float64 {
+	if rate, ok := c.Services[app]; ok {
+		return rate
+	}
+	return c.Rate
+}
+
+func (c *AgentPolicyConfig) Validate() error {
+	for _, patterns := range [][]string{c.Allow, c.Deny} {
+		for _, p := range patterns {
+			for _, part := range strings.SplitN(p, "/", 2) {
+				if _, err := path.Match(part, ""); err != nil {
+					return fmt.Errorf("invalid agent pattern '%s': %v", p, err)
+				}
+			}
+		}
+	}
+	return nil
 }
 
 type SSLConfig struct {
-	Enabled    *bool  `config:"enabled"`
-	PrivateKey string `config:"key"`
-	Cert       string `config:"certificate"`
+	Enabled       *bool  `config:"enabled"`
+	PrivateKey    string `config:"key"`
+	Cert          string `config:"certificate"`
+	KeyPassphrase string `config:"key_passphrase"`
 }
 
 func (c *SSLConfig) isEnabled() bool {
 	return c != nil && (c.Enabled == nil || *c.Enabled)
 }
 
+func (c *FrontendConfig) Validate() error {
+	switch c.DisabledStatus {
+	case 0, http.StatusForbidden, http.StatusNotFound:
+		return nil
+	}
+	return fmt.Errorf("invalid frontend.disabled_status %d, must be one of %d, %d",
+		c.DisabledStatus, http.StatusForbidden, http.StatusNotFound)
+}
+
 func (c *FrontendConfig) isEnabled() bool {
 	return c != nil && (c.Enabled == nil || *c.Enabled)
 }
 
+func (c *FrontendConfig) webSocketEnabled() bool {
+	return c.isEnabled() && c.WebSocket
+}
+
+func (c *RecordConfig) isEnabled() bool {
+	return c != nil && (c.Enabled == nil || *c.Enabled)
+}
+
+func (c *DebugEndpointConfig) isEnabled() bool {
+	return c != nil && (c.Enabled == nil || *c.Enabled)
+}
+
 var defaultConfig = Config{
 	Host:               "localhost:8200",
 	MaxUnzippedSize:    10 * 1024 * 1024, // 10mb
+	MaxCompressedSize:  5 * 1024 * 1024,  // 5mb
 	MaxHeaderBytes:     1048576,          // 1mb
+	ContentLengthAbove: 1024 * 1024,      // 1mb
 	ConcurrentRequests: 20,
 	ReadTimeout:        2 * time.Second,
 	WriteTimeout:       2 * time.Second,
 	ShutdownTimeout:    5 * time.Second,
+	PublishTimeout:     time.Second,
+	AckTimeout:         time.Second,
 	SecretToken:        "",
-	Frontend:           &FrontendConfig{Enabled: new(bool), RateLimit: 10, AllowOrigins: []string{"*"}},
+	Frontend: &FrontendConfig{
+		Enabled:         new(bool),
+		RateLimit:       10,
+		AllowOrigins:    []string{"*"},
+		MaxUnzippedSize: 1024 * 1024, // 1mb
+		ContentTypes:    []string{"text/plain"},
+		RateLimiter: RateLimiterConfig{
+			CacheSize:       1000,
+			BurstMultiplier: 2,
+			Eviction:        rateLimitEvictionLRU,
+		},
+		DisabledStatus: http.StatusForbidden,
+	},
+	Observer:       ObserverConfig{IngestTimestamp: true},
+	EventTimestamp: TimestampPolicyConfig{Action: timestampActionReject},
+	ContextMapping: contextMappingDynamic,
+	RecordRequests: &RecordConfig{
+		Enabled: new(bool),
+		Path:    "recorded_requests",
+		MaxSize: 100 * 1024 * 1024, // 100mb
+	},
+	DebugEndpoint: &DebugEndpointConfig{Enabled: new(bool)},
+	Management: &ManagementConfig{
+		Enabled: new(bool),
+		Host:    "localhost:8202",
+	},
+	Errors:      ErrorsConfig{MaxCauseDepth: processor.DefaultConfig().MaxCauseDepth},
+	Process:     ProcessConfig{CaptureArgv: true, CaptureTitle: true},
+	Sampling:    SamplingConfig{KeepUnsampled: true, Rate: 1},
+	Concurrency: ConcurrencyConfig{Min: 2, Max: 200, TargetLatency: 100 * time.Millisecond},
+	Dedup:       DedupConfig{CacheSize: 10000},
+	Idempotency: IdempotencyConfig{CacheSize: 10000},
+	Validation:  ValidationConfig{Mode: validationModeStrict},
+	Sink: SinkConfig{
+		Type:          sinkPipeline,
+		Filename:      "apm-server.ndjson",
+		RotateEveryKb: 10 * 1024,
+		NumberOfFiles: 7,
+	},
+	Pipeline: PipelineConfig{
+		BulkMaxSize:   2048,
+		FlushInterval: time.Second,
+		Workers:       2,
+	},
+	TemplateCheck: TemplateCheckConfig{Mode: templateCheckWarn},
+	Onboarding:    OnboardingConfig{Once: true},
 }
@@ -0,0 +1,69 @@
+package beater
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// checkConfig verifies the settings that are otherwise only used once the
+// server listens or requests come in, so that `apm-server test config` and
+// starting the server fail right away with a precise error.
+func checkConfig(config Config) error {
+	listeners := []struct {
+		name string
+		ssl  *SSLConfig
+	}{
+		{"ssl", config.SSL},
+		{"rum.ssl", config.RUM.SSL},
+	}
+	if config.Management.isEnabled() {
+		listeners = append(listeners, struct {
+			name string
+			ssl  *SSLConfig
+		}{"management.ssl", config.Management.SSL})
+	}
+	for _, l := range listeners {
+		if !l.ssl.isEnabled() {
+			continue
+		}
+		if _, err := loadKeyPair(l.ssl); err != nil {
+			return fmt.Errorf("invalid %s certificate or key: %v", l.name, err)
+		}
+	}
+
+	if config.Frontend.isEnabled() {
+		for _, origin := range config.Frontend.AllowOrigins {
+			if err := checkOrigin(origin); err != nil {
+				return fmt.Errorf("invalid frontend.allow_origins entry '%s': %v", origin, err)
+			}
+		}
+	}
+
+	if config.Kibana.Enabled {
+		if _, err := common.MakeURL(config.Kibana.Protocol, config.Kibana.Path, config.Kibana.Host, 5601); err != nil {
+			return fmt.Errorf("invalid kibana.host '%s': %v", config.Kibana.Host, err)
+		}
+		if _, err := newKibanaConnector(config.Kibana); err != nil {
+			return fmt.Errorf("invalid kibana.ssl settings: %v", err)
+		}
+	}
+	return nil
+}
+
+// checkOrigin verifies that an allowed origin can match the Origin header
+// sent by browsers, which is made of a scheme, host and optional port.
+func checkOrigin(origin string) error {
+	if origin == "*" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+		return fmt.Errorf("must be '*' or made of scheme, host and port only, e.g. https://example.com")
+	}
+	return nil
+}
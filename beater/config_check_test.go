@@ -0,0 +1,42 @@
+package beater
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckConfig(t *testing.T) {
+	assert.NoError(t, checkConfig(defaultConfig))
+
+	config := defaultConfig
+	config.SSL = &SSLConfig{Cert: "missing.crt", PrivateKey: "missing.key"}
+	err := checkConfig(config)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid ssl certificate or key")
+	}
+
+	true := true
+	config = defaultConfig
+	config.Frontend = &FrontendConfig{Enabled: &true, AllowOrigins: []string{"*", "https://example.com:8080", "example.com"}}
+	err = checkConfig(config)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid frontend.allow_origins entry 'example.com'")
+	}
+
+	config = defaultConfig
+	config.Kibana = KibanaConfig{Enabled: true, Host: "http://[::1"}
+	err = checkConfig(config)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid kibana.host")
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	for _, origin := range []string{"*", "http://example.com", "https://example.com:8443", "http://example.com/"} {
+		assert.NoError(t, checkOrigin(origin), origin)
+	}
+	for _, origin := range []string{"example.com", "http://example.com/app", "http://example.com?a=b", "/path"} {
+		assert.Error(t, checkOrigin(origin), origin)
+	}
+}
@@ -19,6 +19,7 @@ func TestConfig(t *testing.T) {
 			config: []byte(`{
         "host": "localhost:3000",
         "max_unzipped_size": 64,
+        "max_compressed_size": 16,
         "max_header_bytes": 8,
         "read_timeout": 3s,
         "write_timeout": 4s,
@@ -30,10 +31,17 @@ func TestConfig(t *testing.T) {
 					"certificate": "1234cert",
 				},
         "concurrent_requests": 15,
+        "frontend": {
+          "enabled": true,
+          "rate_limit": 1000,
+          "allow_origins": ["example*"],
+          "max_unzipped_size": 32,
+        },
       }`),
 			expectedConfig: Config{
 				Host:               "localhost:3000",
 				MaxUnzippedSize:    64,
+				MaxCompressedSize:  16,
 				MaxHeaderBytes:     8,
 				ReadTimeout:        3000000000,
 				WriteTimeout:       4000000000,
@@ -41,6 +49,12 @@ func TestConfig(t *testing.T) {
 				SecretToken:        "1234random",
 				SSL:                &SSLConfig{Enabled: &truthy, PrivateKey: "1234key", Cert: "1234cert"},
 				ConcurrentRequests: 15,
+				Frontend: &FrontendConfig{
+					Enabled:         &truthy,
+					RateLimit:       1000,
+					AllowOrigins:    []string{"example*"},
+					MaxUnzippedSize: 32,
+				},
 			},
 		},
 		{
@@ -118,3 +132,19 @@ func TestIsEnabled(t *testing.T) {
 		})
 	}
 }
+
+func TestFrontendConfigValidate(t *testing.T) {
+	for _, status := range []int{0, 403, 404} {
+		c := FrontendConfig{DisabledStatus: status}
+		assert.NoError(t, c.Validate(), status)
+	}
+	c := FrontendConfig{DisabledStatus: 410}
+	assert.Error(t, c.Validate())
+}
+
+func TestConfigValidateRUMHost(t *testing.T) {
+	c := Config{Host: "localhost:8200", RUM: RUMConfig{Host: "localhost:8200"}}
+	assert.Error(t, c.Validate())
+	c.RUM.Host = "0.0.0.0:8201"
+	assert.NoError(t, c.Validate())
+}
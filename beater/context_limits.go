@@ -0,0 +1,134 @@
+package beater
+
+import (
+	"encoding/json"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+var contextTruncated = monitoring.NewInt(serverMetrics, "events.context_truncated")
+
+// contextLimitReporter returns a reporter truncating user defined context
+// fields and database statements exceeding the configured size before
+// forwarding the events. Truncated fields keep their type so documents index
+// against the same mapping as untruncated ones, and their names are listed in
+// context.truncated.
+func contextLimitReporter(config ContextLimitsConfig, report reporter) reporter {
+	limits := []struct {
+		key   string
+		limit int
+	}{
+		{"custom", config.MaxCustomSize},
+		{"custom_flattened", config.MaxCustomSize},
+		{"request.body", config.MaxRequestBodySize},
+		{"db.statement", config.MaxDbStatementSize},
+	}
+	return func(events []beat.Event) error {
+		for _, event := range events {
+			var truncated []string
+			for _, l := range limits {
+				if l.limit > 0 && truncateContextField(event.Fields, "context."+l.key, l.limit) {
+					truncated = append(truncated, l.key)
+				}
+			}
+			if len(truncated) > 0 {
+				contextTruncated.Inc()
+				event.Fields.Put("context.truncated", truncated)
+			}
+		}
+		return report(events)
+	}
+}
+
+// truncateContextField returns true if the serialized value of the field
+// exceeded the limit and got truncated. Strings are cut off at the limit,
+// objects are reduced to the keys fitting into the limit, in sorted order.
+// Other values cannot be truncated without changing their type and are
+// removed.
+func truncateContextField(fields common.MapStr, key string, limit int) bool {
+	val, err := fields.GetValue(key)
+	if err != nil || val == nil {
+		return false
+	}
+
+	switch v := val.(type) {
+	case string:
+		if len(v) <= limit {
+			return false
+		}
+		fields.Put(key, truncateString(v, limit))
+		return true
+	case common.MapStr:
+		return truncateObject(fields, key, v, limit)
+	case map[string]interface{}:
+		return truncateObject(fields, key, v, limit)
+	}
+
+	buf, err := json.Marshal(val)
+	if err != nil {
+		logp.Err("Error serializing %s: %v", key, err)
+		return false
+	}
+	if len(buf) <= limit {
+		return false
+	}
+	fields.Delete(key)
+	return true
+}
+
+// truncateString cuts off s at the limit, but not in the middle of a
+// multi-byte character.
+func truncateString(s string, limit int) string {
+	end := limit
+	for end > 0 && !utf8.RuneStart(s[end]) {
+		end--
+	}
+	return s[:end]
+}
+
+// truncateObject replaces the object with the keys that fit into the limit
+// when serialized, values are never split.
+func truncateObject(fields common.MapStr, key string, obj map[string]interface{}, limit int) bool {
+	keys := make([]string, 0, len(obj))
+	sizes := make(map[string]int, len(obj))
+	size := len("{}")
+	for k, v := range obj {
+		name, err := json.Marshal(k)
+		if err != nil {
+			logp.Err("Error serializing %s: %v", key, err)
+			return false
+		}
+		value, err := json.Marshal(v)
+		if err != nil {
+			logp.Err("Error serializing %s: %v", key, err)
+			return false
+		}
+		// "key":value plus the separating comma
+		sizes[k] = len(name) + len(value) + 2
+		size += sizes[k]
+		keys = append(keys, k)
+	}
+	if len(keys) > 0 {
+		size--
+	}
+	if size <= limit {
+		return false
+	}
+
+	sort.Strings(keys)
+	kept := common.MapStr{}
+	size = len("{}") - 1
+	for _, k := range keys {
+		if size+sizes[k] <= limit {
+			kept[k] = obj[k]
+			size += sizes[k]
+		}
+	}
+	fields.Put(key, kept)
+	return true
+}
@@ -0,0 +1,113 @@
+package beater
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestContextLimitReporter(t *testing.T) {
+	var reported []beat.Event
+	report := func(events []beat.Event) error {
+		reported = events
+		return nil
+	}
+
+	events := []beat.Event{
+		{Fields: common.MapStr{"context": common.MapStr{
+			"custom":  map[string]interface{}{"key": "value", "a": "b"},
+			"request": map[string]interface{}{"body": "äöü-body"},
+		}}},
+		{Fields: common.MapStr{"context": common.MapStr{
+			"request": map[string]interface{}{"body": []interface{}{"a", "b", "c"}, "method": "POST"},
+		}}},
+		{Fields: common.MapStr{"context": common.MapStr{
+			"custom":  map[string]interface{}{"a": "b"},
+			"request": map[string]interface{}{"body": "ok"},
+		}}},
+		{Fields: common.MapStr{}},
+	}
+	before := contextTruncated.Get()
+	config := ContextLimitsConfig{MaxCustomSize: 10, MaxRequestBodySize: 5}
+	assert.NoError(t, contextLimitReporter(config, report)(events))
+
+	assert.Equal(t, common.MapStr{
+		"custom":    common.MapStr{"a": "b"},
+		"request":   map[string]interface{}{"body": "äö"},
+		"truncated": []string{"custom", "request.body"},
+	}, reported[0].Fields["context"])
+	assert.Equal(t, common.MapStr{
+		"request":   map[string]interface{}{"method": "POST"},
+		"truncated": []string{"request.body"},
+	}, reported[1].Fields["context"])
+	assert.Equal(t, common.MapStr{
+		"custom":  map[string]interface{}{"a": "b"},
+		"request": map[string]interface{}{"body": "ok"},
+	}, reported[2].Fields["context"])
+	assert.Equal(t, common.MapStr{}, reported[3].Fields)
+	assert.Equal(t, before+2, contextTruncated.Get())
+}
+
+func TestContextLimitReporterDbStatement(t *testing.T) {
+	var reported []beat.Event
+	report := func(events []beat.Event) error {
+		reported = events
+		return nil
+	}
+
+	events := []beat.Event{
+		{Fields: common.MapStr{"context": common.MapStr{
+			"db": common.MapStr{"statement": "SELECT * FROM users", "type": "sql"},
+		}}},
+	}
+	config := ContextLimitsConfig{MaxDbStatementSize: 8}
+	assert.NoError(t, contextLimitReporter(config, report)(events))
+
+	assert.Equal(t, common.MapStr{
+		"db":        common.MapStr{"statement": "SELECT *", "type": "sql"},
+		"truncated": []string{"db.statement"},
+	}, reported[0].Fields["context"])
+}
+
+func TestTruncateContextFieldObject(t *testing.T) {
+	custom := map[string]interface{}{"b": "value", "a": 1, "c": map[string]interface{}{"nested": true}}
+	fields := common.MapStr{"context": common.MapStr{"custom": custom}}
+	assert.False(t, truncateContextField(fields, "context.custom", 100))
+
+	// keys are kept in sorted order as long as they fit, values are not split
+	assert.True(t, truncateContextField(fields, "context.custom", 20))
+	truncated, _ := fields.GetValue("context.custom")
+	assert.Equal(t, common.MapStr{"a": 1, "b": "value"}, truncated)
+	buf, _ := json.Marshal(truncated)
+	assert.True(t, len(buf) <= 20)
+
+	assert.True(t, truncateContextField(fields, "context.custom", 8))
+	truncated, _ = fields.GetValue("context.custom")
+	assert.Equal(t, common.MapStr{"a": 1}, truncated)
+}
+
+func TestContextLimitReporterFlattened(t *testing.T) {
+	var reported []beat.Event
+	report := func(events []beat.Event) error {
+		reported = events
+		return nil
+	}
+
+	events := []beat.Event{
+		{Fields: common.MapStr{"context": common.MapStr{
+			"custom": map[string]interface{}{"key": "value"},
+		}}},
+	}
+	config := ContextLimitsConfig{MaxCustomSize: 10}
+	report = contextMappingReporter(contextMappingFlattened, contextLimitReporter(config, report))
+	assert.NoError(t, report(events))
+
+	assert.Equal(t, common.MapStr{
+		"custom_flattened": `{"key":"va`,
+		"truncated":        []string{"custom_flattened"},
+	}, reported[0].Fields["context"])
+}
@@ -0,0 +1,77 @@
+package beater
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+const (
+	contextMappingDynamic   = "dynamic"
+	contextMappingFlattened = "flattened"
+)
+
+// contextMappingReporter returns a reporter that, in flattened mode, replaces
+// the user defined tags of an event with a list of key=value strings in
+// context.tags_flattened and the custom context with its JSON representation
+// in context.custom_flattened.
+// This keeps high cardinality keys from adding new fields to the index mapping.
+func contextMappingReporter(mapping string, report reporter) reporter {
+	if mapping != contextMappingFlattened {
+		return report
+	}
+	return func(events []beat.Event) error {
+		for _, event := range events {
+			flattenTags(event.Fields)
+			flattenCustom(event.Fields)
+		}
+		return report(events)
+	}
+}
+
+func flattenTags(fields common.MapStr) {
+	val, err := fields.GetValue("context.tags")
+	if err != nil {
+		return
+	}
+	fields.Delete("context.tags")
+
+	var tags map[string]interface{}
+	switch t := val.(type) {
+	case common.MapStr:
+		tags = t
+	case map[string]interface{}:
+		tags = t
+	}
+	if len(tags) == 0 {
+		return
+	}
+
+	flattened := make([]string, 0, len(tags))
+	for k, v := range tags {
+		flattened = append(flattened, fmt.Sprintf("%s=%v", k, v))
+	}
+	sort.Strings(flattened)
+	fields.Put("context.tags_flattened", flattened)
+}
+
+func flattenCustom(fields common.MapStr) {
+	val, err := fields.GetValue("context.custom")
+	if err != nil {
+		return
+	}
+	fields.Delete("context.custom")
+	if val == nil {
+		return
+	}
+	buf, err := json.Marshal(val)
+	if err != nil {
+		logp.Err("Error serializing context.custom: %v", err)
+		return
+	}
+	fields.Put("context.custom_flattened", string(buf))
+}
@@ -0,0 +1,44 @@
+package beater
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestContextMappingReporter(t *testing.T) {
+	var reported []beat.Event
+	report := func(events []beat.Event) error {
+		reported = events
+		return nil
+	}
+	events := func() []beat.Event {
+		return []beat.Event{
+			{Fields: common.MapStr{"context": common.MapStr{
+				"custom": map[string]interface{}{"key": "value"},
+				"tags":   common.MapStr{"b": true, "a": "x", "c": 1.5},
+			}}},
+			{Fields: common.MapStr{"context": common.MapStr{"tags": common.MapStr{}}}},
+		}
+	}
+
+	assert.NoError(t, contextMappingReporter(contextMappingDynamic, report)(events()))
+	assert.Equal(t, events(), reported)
+
+	assert.NoError(t, contextMappingReporter(contextMappingFlattened, report)(events()))
+	assert.Equal(t, common.MapStr{
+		"custom_flattened": `{"key":"value"}`,
+		"tags_flattened":   []string{"a=x", "b=true", "c=1.5"},
+	}, reported[0].Fields["context"])
+	assert.Equal(t, common.MapStr{}, reported[1].Fields["context"])
+}
+
+func TestContextMappingConfig(t *testing.T) {
+	for _, m := range []string{"", "dynamic", "flattened"} {
+		assert.NoError(t, (&Config{ContextMapping: m}).Validate())
+	}
+	assert.Error(t, (&Config{ContextMapping: "json"}).Validate())
+}
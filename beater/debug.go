@@ -0,0 +1,77 @@
+package beater
+
+import (
+	"net/http"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+// DebugTransformURL prefixes the intake routes to get the documents a payload
+// would be indexed as, e.g. /debug/transform/v1/errors.
+const DebugTransformURL = "/debug/transform"
+
+// addDebugRoutes registers a debug route for every intake route, if enabled.
+func addDebugRoutes(mux *http.ServeMux, config Config) {
+	if !config.DebugEndpoint.isEnabled() {
+		return
+	}
+	for path, mapping := range Routes {
+		if path == HealthCheckURL {
+			continue
+		}
+		maxSize := config.MaxUnzippedSize
+		if frontendRoute(path) {
+			maxSize = config.Frontend.MaxUnzippedSize
+		}
+		logp.Info("Path %s added to request handler", DebugTransformURL+path)
+		// the content types configured for the intake route apply to its
+		// debug route
+		contentTypes := append([]string{}, config.ContentTypes[path]...)
+		if frontendRoute(path) {
+			contentTypes = append(contentTypes, config.Frontend.ContentTypes...)
+		}
+		mux.Handle(DebugTransformURL+path, debugHandler(mapping.ProcessorFactory, config, maxSize, contentTypes))
+	}
+}
+
+func debugHandler(pf ProcessorFactory, config Config, maxSize int64, contentTypes []string) http.Handler {
+	return logHandler(
+		tenantAuthHandler(config.SecretToken, config.Tenants,
+			contentLengthHandler(config.RequireContentLength, config.ContentLengthAbove,
+				compressedSizeHandler(config.MaxCompressedSize,
+					contentTypeHandler(nil, contentTypes,
+						gzipResponseHandler(
+							transformRequestHandler(pf, config, maxSize)))))))
+}
+
+// transformRequestHandler processes the request like processRequestHandler,
+// but responds with the resulting documents instead of publishing them.
+// Fields added by the observer, which do not depend on the request, are not
+// part of the documents.
+func transformRequestHandler(pf ProcessorFactory, config Config, maxSize int64) http.Handler {
+	pf = intakeFactory(config, pf)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		docs := []common.MapStr{}
+		capture := func(events []beat.Event) error {
+			for _, event := range events {
+				doc := event.Fields.Clone()
+				doc["@timestamp"] = event.Timestamp
+				docs = append(docs, doc)
+			}
+			return nil
+		}
+		report := processReporter(config.Process, transformReporter(config, nil, nil, capture))
+
+		code, err := processRequest(r, pf, config.processorConfig(), maxSize, requestReporter(r, config, report), nil)
+		if err != nil {
+			sendStatus(w, r, code, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		responseValid.Inc()
+		sendJSON(w, map[string]interface{}{"events": docs})
+	})
+}
@@ -0,0 +1,63 @@
+package beater
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/apm-server/tests"
+	"github.com/elastic/beats/libbeat/beat"
+)
+
+func TestDebugTransformEndpoint(t *testing.T) {
+	data, err := tests.LoadValidData("error")
+	assert.NoError(t, err)
+
+	enabled := true
+	config := defaultConfig
+	config.DebugEndpoint = &DebugEndpointConfig{Enabled: &enabled}
+	config.Dedup.Window = time.Minute
+	mux := newMuxer(config, func(_ []beat.Event) error {
+		t.Fatal("debug endpoint must not publish events")
+		return nil
+	})
+
+	// ids of debugged events are not remembered for deduplication
+	var count int
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequest("POST", DebugTransformURL+BackendErrorsURL, bytes.NewReader(data))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var body struct {
+			Events []map[string]interface{} `json:"events"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.NotEmpty(t, body.Events)
+		if i > 0 {
+			assert.Len(t, body.Events, count)
+		}
+		count = len(body.Events)
+		for _, event := range body.Events {
+			assert.Equal(t, map[string]interface{}{"name": "error", "event": "error"}, event["processor"])
+			assert.Contains(t, event, "@timestamp")
+		}
+	}
+}
+
+func TestDebugTransformEndpointDisabled(t *testing.T) {
+	mux := newMuxer(defaultConfig, nil)
+	req, err := http.NewRequest("POST", DebugTransformURL+BackendErrorsURL, nil)
+	assert.NoError(t, err)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
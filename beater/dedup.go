@@ -0,0 +1,222 @@
+package beater
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/golang-lru"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+// Number of services ids are remembered for.
+const dedupServiceCacheSize = 1000
+
+var (
+	eventsDeduplicated = monitoring.NewInt(serverMetrics, "events.deduplicated")
+	dedupStoreErrors   = monitoring.NewInt(serverMetrics, "dedup.store_errors")
+)
+
+type DedupConfig struct {
+	Window    time.Duration `config:"window"`
+	CacheSize int           `config:"cache_size" validate:"min=1"`
+	Redis     RedisConfig   `config:"redis"`
+}
+
+// dedupStore remembers the ids of published events for the dedup window.
+type dedupStore interface {
+	// seen reports for every id whether it was remembered within the window.
+	seen(ids []dedupID) ([]bool, error)
+	remember(ids []dedupID) error
+	close() error
+}
+
+// deduplicator drops events that were already published within the
+// configured window. Agents retry requests failing with 503, but some of the
+// events might already have been published. Events are identified by their
+// transaction, trace or error id, logs are never considered duplicates.
+// The ids are kept in memory, or in Redis if configured, which keeps them
+// across restarts and shares them between servers.
+type deduplicator struct {
+	store dedupStore
+}
+
+// newDeduplicator returns the deduplicator shared by all requests, or nil if
+// deduplication is disabled.
+func newDeduplicator(config DedupConfig) *deduplicator {
+	if config.Window <= 0 {
+		return nil
+	}
+	if config.Redis.Host != "" {
+		return &deduplicator{store: newRedisDedupStore(config.Window, config.Redis)}
+	}
+	return &deduplicator{store: newMemoryDedupStore(config)}
+}
+
+// reporter returns a reporter dropping the duplicates among the events. Ids
+// are only remembered once the events have been reported successfully, so
+// that events rejected downstream can be retried. Requests are rejected if
+// Redis can't be reached, to not risk publishing duplicates.
+func (d *deduplicator) reporter(report reporter) reporter {
+	if d == nil {
+		return report
+	}
+	return func(events []beat.Event) error {
+		unique, ids, err := filterDuplicates(d.store, events)
+		if err != nil {
+			dedupStoreErrors.Inc()
+			return err
+		}
+		if len(unique) == 0 {
+			return nil
+		}
+		if err := report(unique); err != nil {
+			return err
+		}
+		if err := d.store.remember(ids); err != nil {
+			dedupStoreErrors.Inc()
+			logp.Err("Failed to remember ids of published events: %s", err)
+		}
+		return nil
+	}
+}
+
+// close releases the connections to Redis, if used.
+func (d *deduplicator) close() {
+	if d == nil {
+		return
+	}
+	if err := d.store.close(); err != nil {
+		logp.Err("Failed to close dedup store: %s", err)
+	}
+}
+
+type dedupID struct {
+	service, id string
+}
+
+// filterDuplicates returns the events not seen within the window, and their
+// ids.
+func filterDuplicates(store dedupStore, events []beat.Event) ([]beat.Event, []dedupID, error) {
+	var ids []dedupID
+	eventIDs := make([]*dedupID, len(events))
+	inBatch := map[dedupID]bool{}
+	for i, event := range events {
+		id, ok := eventDedupID(event.Fields)
+		if !ok {
+			continue
+		}
+		if !inBatch[id] {
+			inBatch[id] = true
+			ids = append(ids, id)
+		}
+		eventIDs[i] = &id
+	}
+	if len(ids) == 0 {
+		return events, nil, nil
+	}
+	seen, err := store.seen(ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	duplicates := map[dedupID]bool{}
+	for i, id := range ids {
+		duplicates[id] = seen[i]
+	}
+
+	unique := make([]beat.Event, 0, len(events))
+	var uniqueIDs []dedupID
+	for i, event := range events {
+		if id := eventIDs[i]; id != nil {
+			if duplicates[*id] {
+				eventsDeduplicated.Inc()
+				continue
+			}
+			// later events with the same id in the batch are duplicates
+			duplicates[*id] = true
+			uniqueIDs = append(uniqueIDs, *id)
+		}
+		unique = append(unique, event)
+	}
+	return unique, uniqueIDs, nil
+}
+
+// memoryDedupStore remembers ids in an LRU cache per service.
+type memoryDedupStore struct {
+	window    time.Duration
+	cacheSize int
+	now       func() time.Time
+
+	mu       sync.Mutex
+	services *lru.Cache
+}
+
+func newMemoryDedupStore(config DedupConfig) *memoryDedupStore {
+	services, _ := lru.New(dedupServiceCacheSize)
+	return &memoryDedupStore{window: config.Window, cacheSize: config.CacheSize, now: time.Now, services: services}
+}
+
+func (s *memoryDedupStore) seen(ids []dedupID) ([]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	seen := make([]bool, len(ids))
+	for i, id := range ids {
+		cache, ok := s.services.Get(id.service)
+		if !ok {
+			continue
+		}
+		seenAt, ok := cache.(*lru.Cache).Get(id.id)
+		seen[i] = ok && now.Sub(seenAt.(time.Time)) < s.window
+	}
+	return seen, nil
+}
+
+func (s *memoryDedupStore) remember(ids []dedupID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for _, id := range ids {
+		cache, ok := s.services.Get(id.service)
+		if !ok {
+			cache, _ = lru.New(s.cacheSize)
+			s.services.Add(id.service, cache)
+		}
+		cache.(*lru.Cache).Add(id.id, now)
+	}
+	return nil
+}
+
+func (s *memoryDedupStore) close() error {
+	return nil
+}
+
+// eventDedupID returns the id identifying an event, if it has one.
+func eventDedupID(fields common.MapStr) (dedupID, bool) {
+	get := func(key string) interface{} {
+		v, _ := fields.GetValue(key)
+		return v
+	}
+	service, _ := get("context.app.name").(string)
+	var id string
+	switch get("processor.event") {
+	case "transaction":
+		if txID, ok := get("transaction.id").(string); ok {
+			id = "transaction/" + txID
+		}
+	case "trace":
+		txID, ok := get("trace.transaction_id").(string)
+		if traceID := get("trace.id"); ok && traceID != nil {
+			id = fmt.Sprintf("trace/%s/%v", txID, traceID)
+		}
+	case "error":
+		if errID, ok := get("error.id").(string); ok {
+			id = "error/" + errID
+		}
+	}
+	return dedupID{service, id}, id != ""
+}
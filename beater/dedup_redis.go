@@ -0,0 +1,67 @@
+package beater
+
+import (
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// redisDedupStore remembers ids as Redis keys expiring after the window.
+type redisDedupStore struct {
+	window time.Duration
+	prefix string
+	pool   *redis.Pool
+}
+
+func newRedisDedupStore(window time.Duration, config RedisConfig) *redisDedupStore {
+	prefix := config.KeyPrefix
+	if prefix == "" {
+		prefix = "apm-server:dedup:"
+	}
+	return &redisDedupStore{
+		window: window,
+		prefix: prefix,
+		pool:   newRedisPool(config),
+	}
+}
+
+func (s *redisDedupStore) key(id dedupID) string {
+	return s.prefix + id.service + ":" + id.id
+}
+
+func (s *redisDedupStore) seen(ids []dedupID) ([]bool, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = s.key(id)
+	}
+	values, err := redis.Values(conn.Do("MGET", args...))
+	if err != nil {
+		return nil, err
+	}
+	seen := make([]bool, len(ids))
+	for i := range seen {
+		seen[i] = i < len(values) && values[i] != nil
+	}
+	return seen, nil
+}
+
+func (s *redisDedupStore) remember(ids []dedupID) error {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	ms := int64(s.window / time.Millisecond)
+	for _, id := range ids {
+		if err := conn.Send("SET", s.key(id), 1, "PX", ms); err != nil {
+			return err
+		}
+	}
+	_, err := conn.Do("")
+	return err
+}
+
+func (s *redisDedupStore) close() error {
+	return s.pool.Close()
+}
@@ -0,0 +1,205 @@
+package beater
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func dedupEvent(app, event string, fields common.MapStr) beat.Event {
+	fields.Put("context.app.name", app)
+	fields.Put("processor.event", event)
+	return beat.Event{Fields: fields}
+}
+
+func TestEventDedupID(t *testing.T) {
+	for idx, test := range []struct {
+		event beat.Event
+		id    dedupID
+		ok    bool
+	}{
+		{dedupEvent("a", "transaction", common.MapStr{"transaction": common.MapStr{"id": "1"}}), dedupID{"a", "transaction/1"}, true},
+		{dedupEvent("a", "trace", common.MapStr{"trace": common.MapStr{"id": 2, "transaction_id": "1"}}), dedupID{"a", "trace/1/2"}, true},
+		{dedupEvent("a", "trace", common.MapStr{"trace": common.MapStr{"transaction_id": "1"}}), dedupID{"a", ""}, false},
+		{dedupEvent("a", "error", common.MapStr{"error": common.MapStr{"id": "1"}, "transaction": common.MapStr{"id": "2"}}), dedupID{"a", "error/1"}, true},
+		{dedupEvent("a", "error", common.MapStr{"transaction": common.MapStr{"id": "2"}}), dedupID{"a", ""}, false},
+		{dedupEvent("a", "log", common.MapStr{"log": common.MapStr{"message": "m"}}), dedupID{"a", ""}, false},
+	} {
+		id, ok := eventDedupID(test.event.Fields)
+		assert.Equal(t, test.id, id, "Failed at idx %v", idx)
+		assert.Equal(t, test.ok, ok, "Failed at idx %v", idx)
+	}
+}
+
+func TestDedupReporter(t *testing.T) {
+	var published []beat.Event
+	var fail error
+	report := newDeduplicator(DedupConfig{Window: time.Minute, CacheSize: 10}).reporter(func(events []beat.Event) error {
+		if fail != nil {
+			return fail
+		}
+		published = events
+		return nil
+	})
+	tx := func(app, id string) beat.Event {
+		return dedupEvent(app, "transaction", common.MapStr{"transaction": common.MapStr{"id": id}})
+	}
+	log := dedupEvent("a", "log", common.MapStr{})
+
+	// duplicates within a batch are dropped
+	assert.NoError(t, report([]beat.Event{tx("a", "1"), tx("a", "1"), log, log}))
+	assert.Equal(t, []beat.Event{tx("a", "1"), log, log}, published)
+
+	// ids are tracked per service
+	assert.NoError(t, report([]beat.Event{tx("a", "1"), tx("b", "1")}))
+	assert.Equal(t, []beat.Event{tx("b", "1")}, published)
+
+	// events rejected downstream are not remembered
+	fail = errors.New("queue full")
+	assert.Error(t, report([]beat.Event{tx("a", "2")}))
+	fail = nil
+	published = nil
+	assert.NoError(t, report([]beat.Event{tx("a", "2")}))
+	assert.Equal(t, []beat.Event{tx("a", "2")}, published)
+
+	// batches consisting of duplicates only are not forwarded
+	published = nil
+	assert.NoError(t, report([]beat.Event{tx("a", "2")}))
+	assert.Nil(t, published)
+}
+
+func TestMemoryDedupStoreWindow(t *testing.T) {
+	now := time.Now()
+	s := newMemoryDedupStore(DedupConfig{Window: time.Minute, CacheSize: 10})
+	s.now = func() time.Time { return now }
+	ids := []dedupID{{"a", "error/1"}, {"b", "error/1"}}
+
+	seen, err := s.seen(ids)
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{false, false}, seen)
+	assert.NoError(t, s.remember(ids[:1]))
+	seen, _ = s.seen(ids)
+	assert.Equal(t, []bool{true, false}, seen)
+
+	now = now.Add(time.Minute)
+	seen, _ = s.seen(ids)
+	assert.Equal(t, []bool{false, false}, seen)
+}
+
+// fakeRedis serves the commands used by the redis dedup store and rate
+// limiter.
+type fakeRedis struct {
+	listener net.Listener
+
+	mu   sync.Mutex
+	keys map[string]string
+	ttls map[string]string
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	r := &fakeRedis{listener: l, keys: map[string]string{}, ttls: map[string]string{}}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go r.serve(conn)
+		}
+	}()
+	return r
+}
+
+func (r *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		var n int
+		if _, err := fmt.Fscanf(reader, "*%d\r\n", &n); err != nil {
+			return
+		}
+		args := make([]string, n)
+		for i := range args {
+			var size int
+			fmt.Fscanf(reader, "$%d\r\n", &size)
+			buf := make([]byte, size+2)
+			io.ReadFull(reader, buf)
+			args[i] = string(buf[:size])
+		}
+		r.mu.Lock()
+		switch args[0] {
+		case "MGET":
+			fmt.Fprintf(conn, "*%d\r\n", len(args)-1)
+			for _, key := range args[1:] {
+				if v, ok := r.keys[key]; ok {
+					fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(v), v)
+				} else {
+					fmt.Fprint(conn, "$-1\r\n")
+				}
+			}
+		case "SET":
+			r.keys[args[1]] = args[2]
+			r.ttls[args[1]] = strings.Join(args[3:], " ")
+			fmt.Fprint(conn, "+OK\r\n")
+		case "INCR":
+			n, _ := strconv.Atoi(r.keys[args[1]])
+			r.keys[args[1]] = strconv.Itoa(n + 1)
+			fmt.Fprintf(conn, ":%d\r\n", n+1)
+		case "PEXPIRE":
+			r.ttls[args[1]] = "PX " + args[2]
+			fmt.Fprint(conn, ":1\r\n")
+		case "PTTL":
+			var ttl int
+			if _, err := fmt.Sscanf(r.ttls[args[1]], "PX %d", &ttl); err != nil {
+				ttl = -1
+			}
+			fmt.Fprintf(conn, ":%d\r\n", ttl)
+		default:
+			fmt.Fprint(conn, "-ERR unknown command\r\n")
+		}
+		r.mu.Unlock()
+	}
+}
+
+func TestRedisDedupStore(t *testing.T) {
+	r := newFakeRedis(t)
+	defer r.listener.Close()
+
+	var published []beat.Event
+	dedup := newDeduplicator(DedupConfig{Window: time.Minute, CacheSize: 1, Redis: RedisConfig{Host: r.listener.Addr().String()}})
+	defer dedup.close()
+	report := dedup.reporter(func(events []beat.Event) error {
+		published = events
+		return nil
+	})
+	tx := dedupEvent("a", "transaction", common.MapStr{"transaction": common.MapStr{"id": "1"}})
+
+	assert.NoError(t, report([]beat.Event{tx}))
+	assert.Len(t, published, 1)
+	assert.Equal(t, map[string]string{"apm-server:dedup:a:transaction/1": "1"}, r.keys)
+	assert.Equal(t, map[string]string{"apm-server:dedup:a:transaction/1": "PX 60000"}, r.ttls)
+
+	published = nil
+	assert.NoError(t, report([]beat.Event{tx}))
+	assert.Nil(t, published)
+
+	// requests are rejected while redis is unavailable
+	r.listener.Close()
+	report = newDeduplicator(DedupConfig{Window: time.Minute, CacheSize: 1, Redis: RedisConfig{Host: r.listener.Addr().String()}}).
+		reporter(func(events []beat.Event) error { return nil })
+	assert.Error(t, report([]beat.Event{tx}))
+}
@@ -0,0 +1,343 @@
+package beater
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+const execFilterMaxLineSize = 10 * 1024 * 1024 // 10mb
+
+var (
+	execFilterDropped = monitoring.NewInt(serverMetrics, "events.exec_filter_dropped")
+	execFilterErrors  = monitoring.NewInt(serverMetrics, "exec_filter.errors")
+
+	errExecFilterTimeout = errors.New("exec filter timed out")
+	errExecFilterExited  = errors.New("exec filter exited")
+	errExecFilterStopped = errors.New("exec filter stopped")
+)
+
+type ExecFilterConfig struct {
+	Route     string            `config:"route" validate:"required"`
+	Command   []string          `config:"command" validate:"required"`
+	Timeout   time.Duration     `config:"timeout"`
+	Env       map[string]string `config:"env"`
+	Processes int               `config:"processes"`
+}
+
+// execFilter passes events through an external process for teams that need
+// custom scrubbing or enrichment without rebuilding the server. Every event
+// is written to the stdin of the process as a JSON document on its own line,
+// and for every line written the process must respond with a line on stdout:
+// the document to publish in place of the event, or `null` to drop it.
+// The process is started on first use and kept running. It is killed and
+// restarted with the next request if it exits, answers with invalid JSON or
+// does not answer within the timeout; the events of the affected request are
+// rejected, so that unfiltered data is never published. The process doesn't
+// inherit the environment of the server, which might hold secrets, it only
+// gets PATH and the configured variables. A process filters the events of
+// one request at a time.
+type execFilter struct {
+	command []string
+	timeout time.Duration
+	env     []string
+
+	mu      sync.Mutex
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	lines   chan []byte
+	stopped bool
+}
+
+func (c *ExecFilterConfig) Validate() error {
+	if !strings.HasPrefix(c.Route, "/") {
+		return fmt.Errorf("invalid exec_filters route '%s', must start with '/'", c.Route)
+	}
+	if c.Processes < 0 {
+		return fmt.Errorf("invalid exec_filters processes %d, must not be negative", c.Processes)
+	}
+	return nil
+}
+
+func newExecFilter(config ExecFilterConfig) *execFilter {
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+	env := []string{"PATH=" + os.Getenv("PATH")}
+	for name, value := range config.Env {
+		env = append(env, name+"="+value)
+	}
+	return &execFilter{command: config.Command, timeout: timeout, env: env}
+}
+
+// start runs the process, with the lock held.
+func (f *execFilter) start() error {
+	cmd := exec.Command(f.command[0], f.command[1:]...)
+	cmd.Env = f.env
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	logp.Info("Started exec filter %v, pid=%d", f.command, cmd.Process.Pid)
+
+	lines := make(chan []byte)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 64*1024), execFilterMaxLineSize)
+		for scanner.Scan() {
+			lines <- append([]byte(nil), scanner.Bytes()...)
+		}
+		if err := scanner.Err(); err != nil {
+			logp.Err("Reading from exec filter %v failed: %s", f.command, err)
+		}
+		cmd.Wait()
+	}()
+	f.cmd, f.stdin, f.lines = cmd, stdin, lines
+	return nil
+}
+
+// stop kills the process, with the lock held.
+func (f *execFilter) stop() {
+	if f.cmd == nil {
+		return
+	}
+	f.stdin.Close()
+	f.cmd.Process.Kill()
+	// drain remaining output, so the reading goroutine ends
+	go func(lines chan []byte) {
+		for range lines {
+		}
+	}(f.lines)
+	f.cmd, f.stdin, f.lines = nil, nil, nil
+}
+
+// filter returns the events as returned by the process, without the events
+// it dropped.
+func (f *execFilter) filter(events []beat.Event) ([]beat.Event, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.stopped {
+		return nil, errExecFilterStopped
+	}
+	if f.cmd == nil {
+		if err := f.start(); err != nil {
+			return nil, fmt.Errorf("starting exec filter %v failed: %s", f.command, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	for _, event := range events {
+		line, err := json.Marshal(event.Fields)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	// write in the background, a process answering while its input is still
+	// written must not block forever
+	writeErr := make(chan error, 1)
+	go func(w io.Writer) {
+		_, err := w.Write(buf.Bytes())
+		writeErr <- err
+	}(f.stdin)
+
+	filtered := make([]beat.Event, 0, len(events))
+	timeout := time.NewTimer(f.timeout)
+	defer timeout.Stop()
+	for i := range events {
+		var line []byte
+		var ok bool
+		select {
+		case line, ok = <-f.lines:
+		case <-timeout.C:
+			return nil, f.fail(errExecFilterTimeout)
+		}
+		if !ok {
+			if err := <-writeErr; err != nil {
+				return nil, f.fail(err)
+			}
+			return nil, f.fail(errExecFilterExited)
+		}
+		fields, err := decodeExecFilterDoc(line)
+		if err != nil {
+			return nil, f.fail(err)
+		}
+		if fields == nil {
+			execFilterDropped.Inc()
+			continue
+		}
+		events[i].Fields = fields
+		filtered = append(filtered, events[i])
+	}
+	if err := <-writeErr; err != nil {
+		return nil, f.fail(err)
+	}
+	return filtered, nil
+}
+
+// shutdown kills the process, which is not restarted anymore.
+func (f *execFilter) shutdown() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stopped = true
+	f.stop()
+}
+
+func (f *execFilter) fail(err error) error {
+	execFilterErrors.Inc()
+	logp.Err("Exec filter %v failed, restarting it with the next request: %s", f.command, err)
+	f.stop()
+	return fmt.Errorf("exec filter failed: %s", err)
+}
+
+// decodeExecFilterDoc decodes a document returned by an exec filter. Whole
+// numbers are decoded as int, like they are created by the processors.
+func decodeExecFilterDoc(line []byte) (common.MapStr, error) {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	var doc map[string]interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid document: %s", err)
+	}
+	if doc == nil {
+		return nil, nil
+	}
+	return convertNumbers(doc).(common.MapStr), nil
+}
+
+func convertNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := common.MapStr{}
+		for key, value := range v {
+			m[key] = convertNumbers(value)
+		}
+		return m
+	case []interface{}:
+		for i, value := range v {
+			v[i] = convertNumbers(value)
+		}
+		return v
+	case json.Number:
+		if i, err := strconv.Atoi(v.String()); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return v
+}
+
+// execFilterPool runs the configured number of processes of an exec filter,
+// so that requests to its routes are not all serialized on a single process.
+// Requests wait for a free process.
+type execFilterPool struct {
+	filters []*execFilter
+	free    chan *execFilter
+}
+
+func newExecFilterPool(config ExecFilterConfig) *execFilterPool {
+	n := config.Processes
+	if n <= 0 {
+		n = 1
+	}
+	p := &execFilterPool{free: make(chan *execFilter, n)}
+	for i := 0; i < n; i++ {
+		f := newExecFilter(config)
+		p.filters = append(p.filters, f)
+		p.free <- f
+	}
+	return p
+}
+
+func (p *execFilterPool) filter(events []beat.Event) ([]beat.Event, error) {
+	f := <-p.free
+	defer func() { p.free <- f }()
+	return f.filter(events)
+}
+
+// shutdown kills all processes of the pool.
+func (p *execFilterPool) shutdown() {
+	for _, f := range p.filters {
+		f.shutdown()
+	}
+}
+
+// eventFilter changes or drops events, like an exec filter.
+type eventFilter interface {
+	filter(events []beat.Event) ([]beat.Event, error)
+}
+
+// execFilterReporter returns a reporter passing events through the exec
+// filter before forwarding them. Requests are rejected if filtering fails.
+func execFilterReporter(f eventFilter, report reporter) reporter {
+	return func(events []beat.Event) error {
+		filtered, err := f.filter(events)
+		if err != nil {
+			return err
+		}
+		if len(filtered) == 0 {
+			return nil
+		}
+		return report(filtered)
+	}
+}
+
+// routeExecFilters holds the exec filters configured for route prefixes.
+type routeExecFilters struct {
+	routes []string
+	pools  []*execFilterPool
+}
+
+func newRouteExecFilters(configs []ExecFilterConfig) *routeExecFilters {
+	r := &routeExecFilters{}
+	for _, config := range configs {
+		r.routes = append(r.routes, config.Route)
+		r.pools = append(r.pools, newExecFilterPool(config))
+	}
+	return r
+}
+
+// reporter wraps report with the exec filter of the first route prefix the
+// path starts with, if any.
+func (r *routeExecFilters) reporter(path string, report reporter) reporter {
+	for i, prefix := range r.routes {
+		if strings.HasPrefix(path, prefix) {
+			return execFilterReporter(r.pools[i], report)
+		}
+	}
+	return report
+}
+
+// shutdown kills the processes of all exec filters, once no more requests
+// are handled.
+func (r *routeExecFilters) shutdown() {
+	for _, p := range r.pools {
+		p.shutdown()
+	}
+}
@@ -0,0 +1,209 @@
+package beater
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/apm-server/tests"
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// TestExecFilterHelperProcess is not a real test, it is run as exec filter
+// by the tests below. It drops documents with a `drop` field, hangs on
+// documents with a `hang` field, returns its environment for documents with
+// an `env` field and marks all other documents as scrubbed,
+// noting whether they contained any process fields of the app.
+func TestExecFilterHelperProcess(t *testing.T) {
+	if os.Getenv("GO_WANT_EXEC_FILTER_HELPER") != "1" {
+		return
+	}
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var doc map[string]interface{}
+		json.Unmarshal(scanner.Bytes(), &doc)
+		switch {
+		case doc["drop"] != nil:
+			fmt.Println("null")
+		case doc["hang"] != nil:
+			time.Sleep(time.Minute)
+		case doc["env"] != nil:
+			doc["env"] = os.Environ()
+			out, _ := json.Marshal(doc)
+			fmt.Println(string(out))
+		default:
+			if context, ok := doc["context"].(map[string]interface{}); ok {
+				if app, ok := context["app"].(map[string]interface{}); ok {
+					_, argv := app["argv"]
+					_, title := app["process_title"]
+					doc["process_seen"] = argv || title
+				}
+			}
+			doc["scrubbed"] = true
+			out, _ := json.Marshal(doc)
+			fmt.Println(string(out))
+		}
+	}
+	os.Exit(0)
+}
+
+func helperExecFilterConfig(route string) ExecFilterConfig {
+	return ExecFilterConfig{
+		Route:   route,
+		Command: []string{os.Args[0], "-test.run=TestExecFilterHelperProcess"},
+		Timeout: 5 * time.Second,
+		Env:     map[string]string{"GO_WANT_EXEC_FILTER_HELPER": "1"},
+	}
+}
+
+func helperExecFilter(t *testing.T) *execFilter {
+	return newExecFilter(helperExecFilterConfig("/v1/errors"))
+}
+
+func TestExecFilterReporter(t *testing.T) {
+	f := helperExecFilter(t)
+	defer f.stop()
+
+	var published []beat.Event
+	report := execFilterReporter(f, func(events []beat.Event) error {
+		published = events
+		return nil
+	})
+
+	events := []beat.Event{
+		{Fields: common.MapStr{"error": common.MapStr{"id": "a"}, "duration": common.MapStr{"us": 12}}},
+		{Fields: common.MapStr{"drop": true}},
+		{Fields: common.MapStr{"error": common.MapStr{"id": "b"}}},
+	}
+	assert.NoError(t, report(events))
+	assert.Equal(t, []beat.Event{
+		{Fields: common.MapStr{"error": common.MapStr{"id": "a"}, "duration": common.MapStr{"us": 12}, "scrubbed": true}},
+		{Fields: common.MapStr{"error": common.MapStr{"id": "b"}, "scrubbed": true}},
+	}, published)
+
+	// the process is kept running between requests
+	pid := f.cmd.Process.Pid
+	assert.NoError(t, report([]beat.Event{{Fields: common.MapStr{"a": 1.5}}}))
+	assert.Equal(t, []beat.Event{{Fields: common.MapStr{"a": 1.5, "scrubbed": true}}}, published)
+	assert.Equal(t, pid, f.cmd.Process.Pid)
+}
+
+func TestExecFilterEnv(t *testing.T) {
+	os.Setenv("APM_EXEC_FILTER_TEST_SECRET", "secret")
+	defer os.Unsetenv("APM_EXEC_FILTER_TEST_SECRET")
+	config := helperExecFilterConfig("/v1/errors")
+	config.Env["A"] = "b"
+	f := newExecFilter(config)
+	defer f.stop()
+
+	var published []beat.Event
+	report := execFilterReporter(f, func(events []beat.Event) error {
+		published = events
+		return nil
+	})
+	assert.NoError(t, report([]beat.Event{{Fields: common.MapStr{"env": true}}}))
+	if assert.Len(t, published, 1) {
+		env := published[0].Fields["env"]
+		assert.Len(t, env, 3)
+		assert.Contains(t, env, "A=b")
+		assert.Contains(t, env, "PATH="+os.Getenv("PATH"))
+		assert.NotContains(t, env, "APM_EXEC_FILTER_TEST_SECRET=secret")
+	}
+}
+
+func TestExecFilterTimeout(t *testing.T) {
+	f := helperExecFilter(t)
+	f.timeout = 100 * time.Millisecond
+	defer f.stop()
+
+	report := execFilterReporter(f, func(events []beat.Event) error { return nil })
+	assert.Error(t, report([]beat.Event{{Fields: common.MapStr{"hang": true}}}))
+	assert.Nil(t, f.cmd)
+
+	// the process is restarted with the next request
+	assert.NoError(t, report([]beat.Event{{Fields: common.MapStr{"a": 1}}}))
+}
+
+func TestExecFilterFailingCommand(t *testing.T) {
+	f := newExecFilter(ExecFilterConfig{Route: "/", Command: []string{"/does/not/exist"}})
+	report := execFilterReporter(f, func(events []beat.Event) error { return nil })
+	assert.Error(t, report([]beat.Event{{Fields: common.MapStr{"a": 1}}}))
+}
+
+func TestExecFilterProcessFields(t *testing.T) {
+	data, err := tests.LoadValidData("transaction")
+	assert.NoError(t, err)
+
+	config := defaultConfig
+	config.Process = ProcessConfig{}
+	config.ExecFilters = []ExecFilterConfig{helperExecFilterConfig(BackendTransactionsURL)}
+	var published []beat.Event
+	mux := newMuxer(config, func(events []beat.Event) error {
+		published = append(published, events...)
+		return nil
+	})
+	defer mux.close()
+
+	req, err := http.NewRequest("POST", BackendTransactionsURL, bytes.NewReader(data))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	// argv and the process title are dropped before events reach the filter
+	assert.NotEmpty(t, published)
+	for _, event := range published {
+		assert.Equal(t, true, event.Fields["scrubbed"])
+		assert.Equal(t, false, event.Fields["process_seen"])
+	}
+}
+
+func TestExecFilterPool(t *testing.T) {
+	config := helperExecFilterConfig("/v1/errors")
+	config.Processes = 2
+	p := newExecFilterPool(config)
+	report := execFilterReporter(p, func(events []beat.Event) error { return nil })
+
+	// requests don't wait for a busy process while another one is free
+	busy := <-p.free
+	assert.NoError(t, report([]beat.Event{{Fields: common.MapStr{"a": 1}}}))
+	p.free <- busy
+	assert.NoError(t, report([]beat.Event{{Fields: common.MapStr{"a": 1}}}))
+
+	// processes are not restarted after shutdown
+	p.shutdown()
+	for _, f := range p.filters {
+		assert.Nil(t, f.cmd)
+	}
+	assert.Equal(t, errExecFilterStopped, report([]beat.Event{{Fields: common.MapStr{"a": 1}}}))
+}
+
+func TestRouteExecFilters(t *testing.T) {
+	filters := newRouteExecFilters([]ExecFilterConfig{
+		{Route: "/v1/errors", Command: []string{"a"}},
+		{Route: "/v1/", Command: []string{"b"}},
+	})
+	var called bool
+	report := func(events []beat.Event) error {
+		called = true
+		return nil
+	}
+
+	// without filter the reporter is returned as is
+	filters.reporter(HealthCheckURL, report)(nil)
+	assert.True(t, called)
+
+	assert.Equal(t, []string{"a"}, filters.pools[0].filters[0].command)
+	assert.Error(t, filters.reporter(BackendErrorsURL, report)([]beat.Event{{Fields: common.MapStr{}}}))
+	assert.Error(t, (&ExecFilterConfig{Route: "v1"}).Validate())
+}
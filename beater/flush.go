@@ -0,0 +1,130 @@
+package beater
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+const (
+	// flushedParam is the query parameter asking the server to respond only
+	// once the events of a request have been handed to libbeat. Serverless
+	// runtimes freeze the agent as soon as the response is received, so this
+	// is the only way for them to learn whether their events made it to the
+	// output queue.
+	flushedParam = "flushed"
+
+	// syncParam is the query parameter asking the server to respond only once
+	// the output acknowledged the events of a request, for agents running in
+	// short lived processes like CI jobs or cron tasks, which can't retry
+	// later.
+	syncParam = "sync"
+)
+
+var (
+	flushedRequests = monitoring.NewInt(serverMetrics, "requests.flushed")
+	syncRequests    = monitoring.NewInt(serverMetrics, "requests.sync")
+
+	errFlushTimeout = errors.New("timeout waiting for events to be published")
+	errAckTimeout   = errors.New("timeout waiting for events to be acknowledged by the output")
+)
+
+// publishTracker is attached to the events of a request as private data. The
+// publisher counts tracked events when they are enqueued and again once they
+// have been forwarded to libbeat and acknowledged by the output.
+type publishTracker struct {
+	published sync.WaitGroup
+	acked     sync.WaitGroup
+}
+
+func flushRequested(r *http.Request) bool {
+	return r.URL.Query().Get(flushedParam) == "true"
+}
+
+func syncRequested(r *http.Request) bool {
+	return r.URL.Query().Get(syncParam) == "true"
+}
+
+// trackingReporter returns a reporter attaching the tracker to all events.
+func trackingReporter(tracker *publishTracker, report reporter) reporter {
+	return func(events []beat.Event) error {
+		for i := range events {
+			events[i].Private = tracker
+		}
+		return report(events)
+	}
+}
+
+// trackEnqueued adds delta for every tracked event in the batch.
+func trackEnqueued(batch []beat.Event, delta int) {
+	for _, event := range batch {
+		if tracker, ok := event.Private.(*publishTracker); ok {
+			tracker.published.Add(delta)
+			tracker.acked.Add(delta)
+		}
+	}
+}
+
+func trackPublished(batch []beat.Event) {
+	for _, event := range batch {
+		if tracker, ok := event.Private.(*publishTracker); ok {
+			tracker.published.Done()
+		}
+	}
+}
+
+// trackAcked is called by libbeat with the private data of all events
+// acknowledged by the output.
+func trackAcked(private []interface{}) {
+	for _, p := range private {
+		if tracker, ok := p.(*publishTracker); ok {
+			tracker.acked.Done()
+		}
+	}
+}
+
+// waitDelivered waits for the events of a request sent with the flushed or
+// sync query parameter to be published or acknowledged. Events enqueued by
+// the request are still published if waiting times out or the request is
+// canceled.
+func waitDelivered(r *http.Request, tracker *publishTracker, config Config) (int, error) {
+	var err error
+	if syncRequested(r) {
+		syncRequests.Inc()
+		err = waitGroup(r.Context(), &tracker.acked, config.AckTimeout, errAckTimeout)
+	} else {
+		flushedRequests.Inc()
+		err = waitGroup(r.Context(), &tracker.published, config.PublishTimeout, errFlushTimeout)
+	}
+	switch err {
+	case nil:
+		return http.StatusAccepted, nil
+	case errRequestCanceled:
+		return requestCanceled()
+	default:
+		return http.StatusServiceUnavailable, err
+	}
+}
+
+// waitGroup blocks until the counter of wg is zero, returning timeoutErr
+// after timeout.
+func waitGroup(ctx context.Context, wg *sync.WaitGroup, timeout time.Duration, timeoutErr error) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errRequestCanceled
+	case <-time.After(timeout):
+		return timeoutErr
+	}
+}
@@ -0,0 +1,108 @@
+package beater
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/apm-server/tests"
+)
+
+func TestFlushedRequests(t *testing.T) {
+	payload, err := tests.LoadValidData("error")
+	assert.NoError(t, err)
+
+	send := func(mux http.Handler, url string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("POST", url, bytes.NewReader(payload))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	pipeline := &blockingPipeline{release: make(chan struct{})}
+	pub, err := newPublisher(pipeline, 5, time.Second)
+	assert.NoError(t, err)
+	defer pub.Stop()
+
+	config := defaultConfig
+	config.PublishTimeout = 20 * time.Millisecond
+	mux := newMuxer(config, pub.Send)
+
+	// the events are enqueued, but not published in time
+	w := send(mux, BackendErrorsURL+"?flushed=true")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	var body map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "ERR_FLUSH_TIMEOUT", body["code"])
+
+	// requests not asking for it don't wait
+	assert.Equal(t, http.StatusAccepted, send(mux, BackendErrorsURL).Code)
+
+	// all events sent so far are published before the response
+	close(pipeline.release)
+	assert.Equal(t, http.StatusAccepted, send(mux, BackendErrorsURL+"?flushed=true").Code)
+	pipeline.mu.Lock()
+	assert.Equal(t, 3*4, len(pipeline.published))
+	pipeline.mu.Unlock()
+}
+
+func TestSyncRequests(t *testing.T) {
+	payload, err := tests.LoadValidData("error")
+	assert.NoError(t, err)
+
+	pipeline := &blockingPipeline{release: make(chan struct{})}
+	close(pipeline.release)
+	pub, err := newPublisher(pipeline, 5, time.Second)
+	assert.NoError(t, err)
+	defer pub.Stop()
+
+	config := defaultConfig
+	config.AckTimeout = 20 * time.Millisecond
+	mux := newMuxer(config, pub.Send)
+
+	send := func() *httptest.ResponseRecorder {
+		req, err := http.NewRequest("POST", BackendErrorsURL+"?sync=true", bytes.NewReader(payload))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	// the events are published, but never acknowledged
+	w := send()
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	var body map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "ERR_ACK_TIMEOUT", body["code"])
+
+	// the output acknowledges published events
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(time.Millisecond):
+			}
+			pipeline.mu.Lock()
+			published := pipeline.published
+			pipeline.published = nil
+			pipeline.mu.Unlock()
+			private := make([]interface{}, len(published))
+			for i, event := range published {
+				private[i] = event.Private
+			}
+			pipeline.ack(private)
+		}
+	}()
+	assert.Equal(t, http.StatusAccepted, send().Code)
+}
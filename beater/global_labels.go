@@ -0,0 +1,60 @@
+package beater
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// globalLabelsReporter returns a reporter adding the configured labels to
+// the user defined tags of every event, e.g. to record the environment or
+// region of everything ingested by this server. Tags sent by agents take
+// precedence over labels with the same key.
+func globalLabelsReporter(labels common.MapStr, report reporter) reporter {
+	if len(labels) == 0 {
+		return report
+	}
+	return func(events []beat.Event) error {
+		for _, event := range events {
+			addGlobalLabels(event.Fields, labels)
+		}
+		return report(events)
+	}
+}
+
+func addGlobalLabels(fields common.MapStr, labels common.MapStr) {
+	tags := common.MapStr{}
+	if val, err := fields.GetValue("context.tags"); err == nil {
+		switch t := val.(type) {
+		case common.MapStr:
+			tags = t
+		case map[string]interface{}:
+			tags = t
+		}
+	}
+	for k, v := range labels {
+		if _, ok := tags[k]; !ok {
+			tags[k] = v
+		}
+	}
+	fields.Put("context.tags", tags)
+}
+
+// validateGlobalLabels ensures labels follow the same rules as tags sent by
+// agents: keys must not contain dots, asterisks or double quotes, and values
+// must be strings, booleans or numbers.
+func validateGlobalLabels(labels common.MapStr) error {
+	for k, v := range labels {
+		if strings.ContainsAny(k, `.*"`) {
+			return fmt.Errorf("invalid global_labels key '%s', must not contain '.', '*' or '\"'", k)
+		}
+		switch v.(type) {
+		case string, bool, int, int64, uint64, float64:
+		default:
+			return fmt.Errorf("invalid global_labels value for '%s', must be a string, boolean or number", k)
+		}
+	}
+	return nil
+}
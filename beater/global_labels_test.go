@@ -0,0 +1,59 @@
+package beater
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestGlobalLabelsReporter(t *testing.T) {
+	var reported []beat.Event
+	report := func(events []beat.Event) error {
+		reported = events
+		return nil
+	}
+
+	events := []beat.Event{
+		{Fields: common.MapStr{"context": common.MapStr{
+			"tags": common.MapStr{"env": "staging", "team": "a"},
+		}}},
+		{Fields: common.MapStr{"context": common.MapStr{"user": common.MapStr{"id": "1"}}}},
+		{Fields: common.MapStr{}},
+	}
+	labels := common.MapStr{"env": "production", "region": "eu"}
+	assert.NoError(t, globalLabelsReporter(labels, report)(events))
+
+	assert.Equal(t, common.MapStr{"env": "staging", "team": "a", "region": "eu"}, reported[0].Fields["context"].(common.MapStr)["tags"])
+	assert.Equal(t, common.MapStr{"env": "production", "region": "eu"}, reported[1].Fields["context"].(common.MapStr)["tags"])
+	assert.Equal(t, common.MapStr{"context": common.MapStr{"tags": common.MapStr{"env": "production", "region": "eu"}}}, reported[2].Fields)
+
+	// every event gets its own tags
+	reported[1].Fields.Put("context.tags.env", "changed")
+	assert.Equal(t, "eu", reported[2].Fields["context"].(common.MapStr)["tags"].(common.MapStr)["region"])
+	assert.Equal(t, "production", labels["env"])
+}
+
+func TestGlobalLabelsConfig(t *testing.T) {
+	for _, test := range []struct {
+		config map[string]interface{}
+		valid  bool
+	}{
+		{config: map[string]interface{}{"env": "production", "shard": 3, "canary": true}, valid: true},
+		{config: map[string]interface{}{"a*b": "c"}, valid: false},
+		{config: map[string]interface{}{"team": map[string]interface{}{"name": "a"}}, valid: false},
+		{config: map[string]interface{}{"team.name": "a"}, valid: false},
+	} {
+		c, err := common.NewConfigFrom(map[string]interface{}{"global_labels": test.config})
+		assert.NoError(t, err)
+		config := defaultConfig
+		err = c.Unpack(&config)
+		if test.valid {
+			assert.NoError(t, err, test.config)
+		} else {
+			assert.Error(t, err, test.config)
+		}
+	}
+}
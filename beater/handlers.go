@@ -1,12 +1,14 @@
 package beater
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"strings"
 
 	"github.com/elastic/apm-server/processor"
+	"github.com/elastic/beats/libbeat/beat"
 	"github.com/elastic/beats/libbeat/logp"
 
 	"compress/gzip"
@@ -17,13 +19,18 @@ import (
 
 	"crypto/subtle"
 
-	"github.com/hashicorp/golang-lru"
-	"golang.org/x/time/rate"
+	"github.com/satori/go.uuid"
 
+	"math"
+	"mime"
 	"net"
+	"strconv"
+	"time"
 
 	err "github.com/elastic/apm-server/processor/error"
 	"github.com/elastic/apm-server/processor/healthcheck"
+	"github.com/elastic/apm-server/processor/log"
+	"github.com/elastic/apm-server/processor/otlp"
 	"github.com/elastic/apm-server/processor/transaction"
 	"github.com/elastic/beats/libbeat/monitoring"
 )
@@ -33,16 +40,23 @@ const (
 	FrontendTransactionsURL = "/v1/client-side/transactions"
 	BackendErrorsURL        = "/v1/errors"
 	FrontendErrorsURL       = "/v1/client-side/errors"
+	BackendLogsURL          = "/v1/logs"
 	HealthCheckURL          = "/healthcheck"
+	OTLPLogsURL             = "/otlp/v1/logs"
 
-	rateLimitCacheSize       = 1000
-	rateLimitBurstMultiplier = 2
+	// statusClientClosedRequest is used for requests the client stopped
+	// waiting for, there is no standard status code for these.
+	statusClientClosedRequest = 499
 
 	supportedHeaders = "Content-Type, Content-Encoding, Accept"
 	supportedMethods = "POST, OPTIONS"
 )
 
-type ProcessorFactory func() processor.Processor
+type ProcessorFactory func(processor.Config) processor.Processor
+
+type contextKey string
+
+const requestIDKey contextKey = "requestID"
 
 type ProcessorHandler func(ProcessorFactory, Config, reporter) http.Handler
 
@@ -57,43 +71,154 @@ var (
 	responseValid  = monitoring.NewInt(serverMetrics, "response.valid")
 	responseErrors = monitoring.NewInt(serverMetrics, "response.errors")
 
+	missingContentLength = monitoring.NewInt(serverMetrics, "requests.missing_content_length")
+	decodingErrors       = monitoring.NewInt(serverMetrics, "decoding.errors")
+	canceledRequests     = monitoring.NewInt(serverMetrics, "requests.canceled")
+
 	errInvalidToken    = errors.New("invalid token")
 	errForbidden       = errors.New("forbidden request")
 	errPOSTRequestOnly = errors.New("only POST requests are supported")
 	errTooManyRequests = errors.New("too many requests")
+	errRequestTooLarge = errors.New("request body too large")
+
+	errContentLengthMismatch = errors.New("request body exceeds declared content length")
+	errContentLengthRequired = errors.New("content length required")
+	errRouteDisabled         = errors.New("route disabled")
+	errRequestCanceled       = errors.New("request canceled by the client")
+	errUnsupportedMediaType  = errors.New("unsupported content type")
+
+	// errorCodes are machine readable identifiers sent along with the error
+	// message, allowing agents to decide whether to retry a request.
+	errorCodes = map[error]string{
+		errInvalidToken:          "ERR_INVALID_TOKEN",
+		errForbidden:             "ERR_FORBIDDEN",
+		errPOSTRequestOnly:       "ERR_METHOD_NOT_ALLOWED",
+		errTooManyRequests:       "ERR_RATE_LIMITED",
+		errRequestTooLarge:       "ERR_REQUEST_TOO_LARGE",
+		errContentLengthMismatch: "ERR_CONTENT_LENGTH_MISMATCH",
+		errContentLengthRequired: "ERR_CONTENT_LENGTH_REQUIRED",
+		errRouteDisabled:         "ERR_ROUTE_DISABLED",
+		errQuotaExceeded:         "ERR_QUOTA_EXCEEDED",
+		errOverloaded:            "ERR_OVERLOADED",
+		errInFlightBytes:         "ERR_IN_FLIGHT_BYTES",
+		errIdempotencyConflict:   "ERR_IDEMPOTENCY_CONFLICT",
+		errRequestCanceled:       "ERR_REQUEST_CANCELED",
+		errUnsupportedMediaType:  "ERR_UNSUPPORTED_MEDIA_TYPE",
+		errFlushTimeout:          "ERR_FLUSH_TIMEOUT",
+		errAckTimeout:            "ERR_ACK_TIMEOUT",
+		errGETRequestOnly:        "ERR_METHOD_NOT_ALLOWED",
+		errFull:                  "ERR_QUEUE_FULL",
+		errTimestampOutOfRange:   "ERR_TIMESTAMP_OUT_OF_RANGE",
+		errKibanaUnavailable:     "ERR_KIBANA_UNAVAILABLE",
+		errMissingServiceName:    "ERR_MISSING_SERVICE_NAME",
+	}
 
 	Routes = map[string]routeMapping{
 		BackendTransactionsURL:  {backendHandler, transaction.NewProcessor},
 		FrontendTransactionsURL: {frontendHandler, transaction.NewProcessor},
 		BackendErrorsURL:        {backendHandler, err.NewProcessor},
 		FrontendErrorsURL:       {frontendHandler, err.NewProcessor},
+		BackendLogsURL:          {backendHandler, log.NewProcessor},
 		HealthCheckURL:          {healthCheckHandler, healthcheck.NewProcessor},
+		OTLPLogsURL:             {backendHandler, otlp.NewLogsProcessor},
 	}
 )
 
-func newMuxer(config Config, report reporter) *http.ServeMux {
-	mux := http.NewServeMux()
+// muxer serves the routes of the server, and keeps track of the resources
+// its handlers hold on to beyond single requests.
+type muxer struct {
+	*http.ServeMux
+	webSockets  *webSocketConns
+	execFilters *routeExecFilters
+}
+
+func newMuxer(config Config, report reporter) *muxer {
+	mux := &muxer{ServeMux: http.NewServeMux(), webSockets: newWebSocketConns()}
+
+	recorder, err := newRequestRecorder(config.RecordRequests, config.Process, config.Tenants)
+	if err != nil {
+		logp.Err("Recording requests disabled: %s", err)
+	}
+
+	kibana, err := newKibanaConnector(config.Kibana)
+	if err != nil {
+		logp.Err("Kibana connection disabled: %s", err)
+	}
+	if kibana != nil {
+		logp.Info("Path %s added to request handler", AgentConfigURL)
+		mux.Handle(AgentConfigURL, routeMetricsHandler(AgentConfigURL, agentConfigHandler(config, kibana)))
+	}
+	var limiter *adaptiveLimiter
+	if config.Concurrency.Adaptive {
+		limiter = newAdaptiveLimiter(config.Concurrency, config.ConcurrentRequests)
+	}
+	report = limiter.reporter(report)
+	budget := newMemoryBudget(config.MaxInFlightBytes)
+	idempotency := newIdempotencyCache(config.Idempotency)
+	mux.execFilters = newRouteExecFilters(config.ExecFilters)
+	// argv and the process title must not reach the exec filters either
+	routeReporter := func(path string) reporter {
+		return processReporter(config.Process, mux.execFilters.reporter(path, report))
+	}
 
 	for path, mapping := range Routes {
+		if config.routeDisabled(path) {
+			logp.Info("Path %s disabled", path)
+			mux.Handle(path, routeMetricsHandler(path, logHandler(routeDisabledHandler())))
+			continue
+		}
 		logp.Info("Path %s added to request handler", path)
-		mux.Handle(path, mapping.ProcessorHandler(mapping.ProcessorFactory, config, report))
+		h := mapping.ProcessorHandler(mapping.ProcessorFactory, config, routeReporter(path))
+		if path == HealthCheckURL && kibana != nil {
+			h = kibanaHealthCheckHandler(kibana)
+		}
+		if path != HealthCheckURL {
+			h = idempotency.handler(limiter.handler(budget.handler(h)))
+		}
+		if config.routeDeprecated(path) {
+			h = deprecationHandler(path, h)
+		}
+		if path != HealthCheckURL && recorder.records(path) {
+			logp.Info("Recording requests to %s in %s", path, recorder.dir)
+			h = recorder.handler(path, h)
+		}
+		mux.Handle(path, routeMetricsHandler(path, h))
+	}
+	if config.Frontend.webSocketEnabled() {
+		logp.Info("Path %s added to request handler", FrontendWebSocketURL)
+		mux.Handle(FrontendWebSocketURL, routeMetricsHandler(FrontendWebSocketURL, webSocketHandler(config, mux.webSockets, budget, limiter, routeReporter)))
 	}
+	addDebugRoutes(mux.ServeMux, config)
+	addManagementRoutes(mux.ServeMux, config)
 
 	return mux
 }
 
+// close closes the WebSocket connections and stops the exec filters, once
+// the servers using the muxer are shut down.
+func (mux *muxer) close() {
+	mux.webSockets.closeAll()
+	mux.execFilters.shutdown()
+}
+
 func backendHandler(pf ProcessorFactory, config Config, report reporter) http.Handler {
 	return logHandler(
-		authHandler(config.SecretToken,
-			processRequestHandler(pf, config, report)))
+		tenantAuthHandler(config.SecretToken, config.Tenants,
+			contentLengthHandler(config.RequireContentLength, config.ContentLengthAbove,
+				compressedSizeHandler(config.MaxCompressedSize,
+					contentTypeHandler(config.ContentTypes, nil,
+						processRequestHandler(pf, config, config.MaxUnzippedSize, report))))))
 }
 
 func frontendHandler(pf ProcessorFactory, config Config, report reporter) http.Handler {
 	return logHandler(
-		frontendSwitchHandler(config.Frontend.isEnabled(),
-			ipRateLimitHandler(config.Frontend.RateLimit,
+		frontendSwitchHandler(config.Frontend.isEnabled(), config.Frontend.DisabledStatus,
+			ipRateLimitHandler(config.Frontend.RateLimit, config.Frontend.RateLimiter,
 				corsHandler(config.Frontend.AllowOrigins,
-					processRequestHandler(pf, config, report)))))
+					contentLengthHandler(config.RequireContentLength, config.ContentLengthAbove,
+						compressedSizeHandler(config.MaxCompressedSize,
+							contentTypeHandler(config.ContentTypes, config.Frontend.ContentTypes,
+								processRequestHandler(pf, config, config.Frontend.MaxUnzippedSize, report))))))))
 }
 
 func healthCheckHandler(_ ProcessorFactory, _ Config, _ reporter) http.Handler {
@@ -105,40 +230,42 @@ func healthCheckHandler(_ ProcessorFactory, _ Config, _ reporter) http.Handler {
 
 func logHandler(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		logp.Debug("handler", "Request: URI=%s, method=%s, content-length=%d", r.RequestURI, r.Method, r.ContentLength)
+		reqID := uuid.NewV4().String()
+		logp.Debug("handler", "Request: ID=%s, URI=%s, method=%s, content-length=%d", reqID, r.RequestURI, r.Method, r.ContentLength)
 		requestCounter.Inc()
-		h.ServeHTTP(w, r)
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey, reqID)))
 	})
 }
 
-func frontendSwitchHandler(feSwitch bool, h http.Handler) http.Handler {
+// requestID returns the ID assigned to the request by the logHandler.
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey).(string)
+	return id
+}
+
+// routeDisabledHandler responds to requests to routes turned off in the
+// config, telling agents the route is gone for good rather than unknown.
+func routeDisabledHandler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if feSwitch {
-			h.ServeHTTP(w, r)
-		} else {
-			sendStatus(w, r, http.StatusForbidden, errForbidden)
-		}
+		routeDisabled(r.URL.Path)
+		sendStatus(w, r, http.StatusGone, errRouteDisabled)
 	})
 }
 
-func ipRateLimitHandler(rateLimit int, h http.Handler) http.Handler {
-
-	cache, _ := lru.New(rateLimitCacheSize)
-
-	var deny = func(ip string) bool {
-		if !cache.Contains(ip) {
-			cache.Add(ip, rate.NewLimiter(rate.Limit(rateLimit), rateLimit*rateLimitBurstMultiplier))
-		}
-		var limiter, _ = cache.Get(ip)
-		return !limiter.(*rate.Limiter).Allow()
-	}
-
+func frontendSwitchHandler(feSwitch bool, disabledStatus int, h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if deny(extractIP(r)) {
-			sendStatus(w, r, http.StatusTooManyRequests, errTooManyRequests)
-			return
+		if feSwitch {
+			h.ServeHTTP(w, r)
+		} else {
+			routeDisabled(r.URL.Path)
+			if disabledStatus == http.StatusNotFound {
+				// respond as for unknown paths, not telling the route exists
+				responseErrors.Inc()
+				http.NotFound(w, r)
+				return
+			}
+			sendStatus(w, r, http.StatusForbidden, errForbidden)
 		}
-		h.ServeHTTP(w, r)
 	})
 }
 
@@ -197,21 +324,11 @@ func isAuthorized(req *http.Request, secretToken string) bool {
 }
 
 func corsHandler(allowedOrigins []string, h http.Handler) http.Handler {
-
-	var isAllowed = func(origin string) bool {
-		for _, allowed := range allowedOrigins {
-			if origin == allowed || allowed == "*" {
-				return true
-			}
-		}
-		return false
-	}
-
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
 		// origin header is always set by the browser
 		origin := r.Header.Get("Origin")
-		validOrigin := isAllowed(origin)
+		validOrigin := originAllowed(allowedOrigins, origin)
 
 		if r.Method == "OPTIONS" {
 
@@ -245,56 +362,259 @@ func corsHandler(allowedOrigins []string, h http.Handler) http.Handler {
 	})
 }
 
-func processRequestHandler(pf ProcessorFactory, config Config, report reporter) http.Handler {
+// contentTypeHandler rejects POST requests not sent with a content type
+// accepted by the route with 415 Unsupported Media Type. Besides
+// application/json and MessagePack, routes accept the content types configured
+// for their path and the given ones, which are all treated as JSON requests.
+// Browsers sending data with navigator.sendBeacon for example can't set the
+// content type to application/json without a CORS preflight request, which is
+// not possible while a page is unloaded.
+func contentTypeHandler(routeContentTypes map[string][]string, contentTypes []string, h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		code, err := processRequest(r, pf, config.MaxUnzippedSize, report)
+		if r.Method != "POST" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err == nil && containsFold(msgpackContentTypes, mediaType) {
+			r.Header.Set("Content-Type", "application/msgpack")
+			h.ServeHTTP(w, r)
+			return
+		}
+		if err != nil || !(strings.EqualFold(mediaType, "application/json") ||
+			containsFold(contentTypes, mediaType) ||
+			containsFold(routeContentTypes[r.URL.Path], mediaType)) {
+			sendStatus(w, r, http.StatusUnsupportedMediaType, errUnsupportedMediaType)
+			return
+		}
+		r.Header.Set("Content-Type", "application/json")
+		h.ServeHTTP(w, r)
+	})
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// contentLengthHandler ensures a request body is not bigger than its declared
+// Content-Length. Requests without a Content-Length are counted, and refused
+// if required is set once more than above bytes of their body have been read,
+// so that small chunked requests are still accepted.
+func contentLengthHandler(required bool, above int64, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength < 0 {
+			missingContentLength.Inc()
+			if required && r.Body != nil {
+				r.Body = &sizeLimitedReader{ReadCloser: r.Body, remaining: above, err: errContentLengthRequired}
+			}
+		} else if r.Body != nil {
+			r.Body = &sizeLimitedReader{ReadCloser: r.Body, remaining: r.ContentLength, err: errContentLengthMismatch}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// compressedSizeHandler limits the size of compressed request bodies before
+// they get decompressed. Requests announcing a bigger Content-Length are
+// rejected right away, for all others the body is cut off as soon as more than
+// maxSize bytes have been read.
+func compressedSizeHandler(maxSize int64, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if maxSize > 0 && r.Body != nil && isCompressed(r) {
+			if r.ContentLength > maxSize {
+				sendStatus(w, r, http.StatusRequestEntityTooLarge, errRequestTooLarge)
+				return
+			}
+			r.Body = &sizeLimitedReader{ReadCloser: r.Body, remaining: maxSize, err: errRequestTooLarge}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func isCompressed(r *http.Request) bool {
+	switch r.Header.Get("Content-Encoding") {
+	case "deflate", "gzip":
+		return true
+	}
+	return false
+}
+
+// sizeLimitedReader counts the bytes read from the underlying reader and
+// returns err once more than the remaining bytes are read.
+type sizeLimitedReader struct {
+	io.ReadCloser
+	remaining int64
+	err       error
+}
+
+func (r *sizeLimitedReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.remaining -= int64(n)
+	if r.remaining < 0 {
+		return n, r.err
+	}
+	return n, err
+}
+
+// intakeFactory wraps the processor factory of an intake route, applying the
+// compatibility rules and the validation mode to every payload, however it is
+// received.
+func intakeFactory(config Config, pf ProcessorFactory) ProcessorFactory {
+	return compatFactory(config.Compatibility, validationFactory(config.Validation, pf))
+}
+
+// processRequestHandler reads at most maxSize bytes of the decompressed request
+// body. The limit is set per route, as frontend payloads are expected to be
+// considerably smaller than backend payloads.
+func processRequestHandler(pf ProcessorFactory, config Config, maxSize int64, report reporter) http.Handler {
+	pf = intakeFactory(config, pf)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		phases := newRequestPhases()
+		report := requestReporter(r, config, report)
+		var tracker *publishTracker
+		if flushRequested(r) || syncRequested(r) {
+			tracker = &publishTracker{}
+			report = trackingReporter(tracker, report)
+		}
+		code, err := processRequest(r, pf, config.processorConfig(), maxSize, report, phases)
+		if err == nil && tracker != nil {
+			code, err = waitDelivered(r, tracker, config)
+			phases.done("deliver")
+		}
 		sendStatus(w, r, code, err)
+		logSlowRequest(r, config.Logging.SlowRequestThreshold, code, phases)
 	})
 }
 
-func processRequest(r *http.Request, pf ProcessorFactory, maxSize int64, report reporter) (int, error) {
+func processRequest(r *http.Request, pf ProcessorFactory, pc processor.Config, maxSize int64, report reporter, phases *requestPhases) (int, error) {
 
-	processor := pf()
+	processor := pf(pc)
 
 	if r.Method != "POST" {
 		return http.StatusMethodNotAllowed, errPOSTRequestOnly
 	}
 
 	reader, err := decodeData(r)
+	if code, ok := sizeErrorStatus(err); ok {
+		return code, err
+	}
 	if err != nil {
-		return http.StatusBadRequest, errors.New(fmt.Sprintf("Decoding error: %s", err.Error()))
+		decodingErrors.Inc()
+		return http.StatusBadRequest, newCodedError("ERR_DECODING", fmt.Errorf("Decoding error: %s", err.Error()))
 	}
 	defer reader.Close()
 
 	// Limit size of request to prevent for example zip bombs
-	limitedReader := io.LimitReader(reader, maxSize)
+	limitedReader := reservedReader(r, io.LimitReader(reader, maxSize))
 	buf, err := ioutil.ReadAll(limitedReader)
+	if code, ok := sizeErrorStatus(err); ok {
+		return code, err
+	}
 	if err != nil {
 		// If we run out of memory, for example
-		return http.StatusInternalServerError, errors.New(fmt.Sprintf("Data read error: %s", err.Error()))
+		return http.StatusInternalServerError, newCodedError("ERR_READ", fmt.Errorf("Data read error: %s", err.Error()))
 
 	}
+	if r.Header.Get("Content-Type") == "application/msgpack" {
+		if buf, err = msgpackToJSON(buf); err != nil {
+			decodingErrors.Inc()
+			return http.StatusBadRequest, newCodedError("ERR_DECODING", fmt.Errorf("Decoding error: %s", err.Error()))
+		}
+		if int64(len(buf)) > maxSize {
+			return http.StatusRequestEntityTooLarge, errRequestTooLarge
+		}
+	}
+	phases.done("read")
+
+	// stop processing requests the client stopped waiting for
+	ctx := r.Context()
+	if ctx.Err() != nil {
+		return requestCanceled()
+	}
+	return processPayload(r.URL.Path, processor, buf, cancelableReporter(ctx, report), phases)
+}
 
-	if err = processor.Validate(buf); err != nil {
-		return http.StatusBadRequest, err
+// processPayload validates and transforms a payload sent to the given path
+// and reports the resulting events.
+func processPayload(path string, processor processor.Processor, buf []byte, report reporter, phases *requestPhases) (int, error) {
+	if err := processor.Validate(buf); err != nil {
+		intakeStats.invalid(buf)
+		return http.StatusBadRequest, newCodedError("ERR_VALIDATION", err)
 	}
+	phases.done("validate")
 
 	list, err := processor.Transform(buf)
 	if err != nil {
-		return http.StatusBadRequest, err
+		intakeStats.invalid(buf)
+		return http.StatusBadRequest, newCodedError("ERR_INVALID_PAYLOAD", err)
 	}
+	phases.done("transform")
+	routeAgentStats.request(path, list)
 
-	if err = report(list); err != nil {
+	err = report(list)
+	phases.done("enqueue")
+	if err != nil {
+		intakeStats.dropped(list)
+		if err == errRequestCanceled {
+			return requestCanceled()
+		}
+		if err == errTimestampOutOfRange {
+			return http.StatusBadRequest, err
+		}
+		if e, ok := err.(*retryAfterError); ok && e.error == errQuotaExceeded {
+			return http.StatusTooManyRequests, err
+		}
+		if _, ok := err.(*agentDeniedError); ok {
+			return http.StatusForbidden, newCodedError("ERR_AGENT_DENIED", err)
+		}
 		return http.StatusServiceUnavailable, err
 	}
 
+	intakeStats.accepted(list)
 	return http.StatusAccepted, nil
 }
 
+// cancelableReporter returns a reporter refusing to report events once the
+// context is done, e.g. because the client closed the connection.
+func cancelableReporter(ctx context.Context, report reporter) reporter {
+	return func(events []beat.Event) error {
+		if ctx.Err() != nil {
+			return errRequestCanceled
+		}
+		return report(events)
+	}
+}
+
+func requestCanceled() (int, error) {
+	canceledRequests.Inc()
+	return statusClientClosedRequest, errRequestCanceled
+}
+
+// sizeErrorStatus returns the status code for errors caused by violated
+// request body size limits.
+func sizeErrorStatus(err error) (int, bool) {
+	switch err {
+	case errRequestTooLarge:
+		return http.StatusRequestEntityTooLarge, true
+	case errContentLengthMismatch:
+		return http.StatusBadRequest, true
+	case errContentLengthRequired:
+		return http.StatusLengthRequired, true
+	case errInFlightBytes:
+		return http.StatusServiceUnavailable, true
+	}
+	return 0, false
+}
+
 func decodeData(req *http.Request) (io.ReadCloser, error) {
 
-	if req.Header.Get("Content-Type") != "application/json" {
-		return nil, fmt.Errorf("invalid content type: %s", req.Header.Get("Content-Type"))
+	if ct := req.Header.Get("Content-Type"); ct != "application/json" && ct != "application/msgpack" {
+		return nil, fmt.Errorf("invalid content type: %s", ct)
 	}
 
 	reader := req.Body
@@ -321,28 +641,86 @@ func decodeData(req *http.Request) (io.ReadCloser, error) {
 	return reader, nil
 }
 
-func sendStatus(w http.ResponseWriter, r *http.Request, code int, err error) {
-	content_type := "text/plain; charset=utf-8"
-	if acceptsJSON(r) {
-		content_type = "application/json"
+// codedError attaches an error code to errors that are created while
+// processing a request.
+type codedError struct {
+	code string
+	err  error
+}
+
+func newCodedError(code string, err error) error {
+	return &codedError{code: code, err: err}
+}
+
+func (e *codedError) Error() string {
+	return e.err.Error()
+}
+
+// retryAfterError wraps errors of requests that are likely to succeed if
+// retried after the given duration.
+type retryAfterError struct {
+	error
+	after time.Duration
+}
+
+// errorCode returns the machine readable code of an error, falling back to
+// a generic code derived from the status code.
+func errorCode(err error, status int) string {
+	if e, ok := err.(*retryAfterError); ok {
+		err = e.error
 	}
-	w.Header().Set("Content-Type", content_type)
-	w.WriteHeader(code)
+	if e, ok := err.(*codedError); ok {
+		return e.code
+	}
+	if code, ok := errorCodes[err]; ok {
+		return code
+	}
+	if status >= 500 {
+		return "ERR_INTERNAL"
+	}
+	return "ERR_REQUEST"
+}
 
+// sendStatus writes the status code. Errors are always sent as a JSON object
+// containing the error message, a machine readable error code and the ID of
+// the request.
+func sendStatus(w http.ResponseWriter, r *http.Request, code int, err error) {
 	if err == nil {
+		content_type := "text/plain; charset=utf-8"
+		if acceptsJSON(r) {
+			content_type = "application/json"
+		}
+		w.Header().Set("Content-Type", content_type)
+		w.WriteHeader(code)
+
 		responseValid.Inc()
 		logp.Debug("request", "request successful, code=%d", code)
 		return
 	}
 
-	logp.Err("%s, code=%d", err.Error(), code)
+	reqID := requestID(r)
+	logp.Err("%s, code=%d, request_id=%s", err.Error(), code, reqID)
 
 	responseErrors.Inc()
-	if acceptsJSON(r) {
-		sendJSON(w, map[string]interface{}{"error": err.Error()})
-	} else {
-		sendPlain(w, err.Error())
+	if e, ok := err.(*retryAfterError); ok {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(e.after)))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	sendJSON(w, map[string]interface{}{
+		"error":      err.Error(),
+		"code":       errorCode(err, code),
+		"request_id": reqID,
+	})
+}
+
+// retryAfterSeconds rounds up to full seconds, as required by the
+// Retry-After header, and never returns less than one second.
+func retryAfterSeconds(d time.Duration) int {
+	if sec := int(math.Ceil(d.Seconds())); sec > 1 {
+		return sec
 	}
+	return 1
 }
 
 func acceptsJSON(r *http.Request) bool {
@@ -359,7 +737,3 @@ func sendJSON(w http.ResponseWriter, msg map[string]interface{}) {
 
 	w.Write(buf)
 }
-
-func sendPlain(w http.ResponseWriter, msg string) {
-	w.Write([]byte(msg))
-}
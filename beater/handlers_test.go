@@ -2,15 +2,22 @@ package beater
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
 	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
+	perr "github.com/elastic/apm-server/processor/error"
 	"github.com/elastic/apm-server/tests"
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
 )
 
 func TestDecode(t *testing.T) {
@@ -31,66 +38,250 @@ func TestDecode(t *testing.T) {
 	assert.Equal(t, transactionBytes, body)
 }
 
-func TestJSONFailureResponse(t *testing.T) {
-	req, err := http.NewRequest("POST", "_", nil)
+func TestDecodingErrorsCounted(t *testing.T) {
+	req, err := http.NewRequest("POST", "_", bytes.NewReader([]byte("{}")))
 	assert.Nil(t, err)
+	req.Header.Add("Content-Type", "text/plain")
+
+	before := decodingErrors.Get()
+	code, err := processRequest(req, Routes[BackendErrorsURL].ProcessorFactory, defaultConfig.processorConfig(), 1024, nil, nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Error(t, err)
+	assert.Equal(t, before+1, decodingErrors.Get())
+}
+
+func TestCompressedSizeHandler(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(bytes.Repeat([]byte("a"), 1024*1024))
+	zw.Close()
 
-	req.Header.Set("Accept", "application/json")
+	var served bool
+	var readErr error
+	h := compressedSizeHandler(100, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served = true
+		_, readErr = ioutil.ReadAll(r.Body)
+	}))
+
+	// declared content length exceeds the limit
+	req, _ := http.NewRequest("POST", "_", bytes.NewReader(buf.Bytes()))
+	req.Header.Set("Content-Encoding", "gzip")
 	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.False(t, served)
 
-	sendStatus(w, req, 400, errors.New("Cannot compare apples to oranges"))
+	// unknown content length, body is cut off while reading
+	req, _ = http.NewRequest("POST", "_", ioutil.NopCloser(bytes.NewReader(buf.Bytes())))
+	req.Header.Set("Content-Encoding", "gzip")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	assert.True(t, served)
+	assert.Equal(t, errRequestTooLarge, readErr)
 
-	resp := w.Result()
-	body, _ := ioutil.ReadAll(resp.Body)
-	assert.Equal(t, 400, w.Code)
-	assert.Equal(t, body, []byte(`{"error":"Cannot compare apples to oranges"}`))
-	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	// uncompressed bodies are not limited
+	served, readErr = false, nil
+	req, _ = http.NewRequest("POST", "_", bytes.NewReader(buf.Bytes()))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.True(t, served)
+	assert.NoError(t, readErr)
 }
 
-func TestJSONFailureResponseWhenAcceptingAnything(t *testing.T) {
-	req, err := http.NewRequest("POST", "_", nil)
-	assert.Nil(t, err)
-	req.Header.Set("Accept", "*/*")
+func TestContentLengthHandler(t *testing.T) {
+	var readErr error
+	h := func(required bool) http.Handler {
+		return contentLengthHandler(required, 8, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, readErr = ioutil.ReadAll(r.Body)
+		}))
+	}
+
+	// body exceeds declared content length
+	req, _ := http.NewRequest("POST", "_", bytes.NewReader([]byte("0123456789")))
+	req.ContentLength = 5
+	h(false).ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, errContentLengthMismatch, readErr)
+
+	// missing content length
+	before := missingContentLength.Get()
+	req, _ = http.NewRequest("POST", "_", bytes.NewReader([]byte("0123456789")))
+	req.ContentLength = -1
 	w := httptest.NewRecorder()
+	h(false).ServeHTTP(w, req)
+	assert.NoError(t, readErr)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, before+1, missingContentLength.Get())
 
-	sendStatus(w, req, 400, errors.New("Cannot compare apples to oranges"))
+	// required only once the body exceeds the threshold
+	req, _ = http.NewRequest("POST", "_", bytes.NewReader([]byte("01234567")))
+	req.ContentLength = -1
+	h(true).ServeHTTP(httptest.NewRecorder(), req)
+	assert.NoError(t, readErr)
 
-	resp := w.Result()
-	body, _ := ioutil.ReadAll(resp.Body)
-	assert.Equal(t, 400, w.Code)
-	assert.Equal(t, body, []byte(`{"error":"Cannot compare apples to oranges"}`))
-	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	req, _ = http.NewRequest("POST", "_", bytes.NewReader([]byte("0123456789")))
+	req.ContentLength = -1
+	h(true).ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, errContentLengthRequired, readErr)
+	code, ok := sizeErrorStatus(readErr)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusLengthRequired, code)
 }
 
-func TestHTMLFailureResponse(t *testing.T) {
-	req, err := http.NewRequest("POST", "_", nil)
-	assert.Nil(t, err)
-	req.Header.Set("Accept", "text/html")
+func TestContentTypeHandler(t *testing.T) {
+	cfg, err := common.NewConfigWithYAML([]byte(`
+content_types:
+  /v1/transactions: ["application/vnd.elastic.apm+json"]
+`), "")
+	assert.NoError(t, err)
+	config := defaultConfig
+	assert.NoError(t, cfg.Unpack(&config))
+
+	var contentType string
+	h := contentTypeHandler(config.ContentTypes, []string{"text/plain"}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+	}))
+
+	for _, test := range []struct {
+		path, sent string
+		code       int
+	}{
+		{"/v1/errors", "application/json", http.StatusOK},
+		{"/v1/errors", "application/json; charset=utf-8", http.StatusOK},
+		{"/v1/errors", "text/plain;charset=UTF-8", http.StatusOK},
+		{"/v1/errors", "Text/Plain", http.StatusOK},
+		{"/v1/errors", "application/vnd.elastic.apm+json", http.StatusUnsupportedMediaType},
+		{"/v1/transactions", "application/vnd.elastic.apm+json", http.StatusOK},
+		{"/v1/transactions", "application/x-www-form-urlencoded", http.StatusUnsupportedMediaType},
+		{"/v1/transactions", "", http.StatusUnsupportedMediaType},
+	} {
+		contentType = ""
+		req, _ := http.NewRequest("POST", test.path, nil)
+		req.Header.Set("Content-Type", test.sent)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		assert.Equal(t, test.code, w.Code, "Failed for %s %s", test.path, test.sent)
+		if test.code == http.StatusOK {
+			assert.Equal(t, "application/json", contentType, "Failed for %s %s", test.path, test.sent)
+		} else {
+			assert.Contains(t, w.Body.String(), "ERR_UNSUPPORTED_MEDIA_TYPE")
+		}
+	}
+
+	// only requests sending data are checked
+	req, _ := http.NewRequest("OPTIONS", "/v1/errors", nil)
 	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestCanceledRequest(t *testing.T) {
+	payload, err := tests.LoadValidData("error")
+	assert.NoError(t, err)
 
-	sendStatus(w, req, 400, errors.New("Cannot compare apples to oranges"))
+	var reported bool
+	report := func([]beat.Event) error {
+		reported = true
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequest("POST", BackendErrorsURL, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	cancel()
 
-	resp := w.Result()
-	body, _ := ioutil.ReadAll(resp.Body)
-	assert.Equal(t, 400, w.Code)
-	assert.Equal(t, body, []byte(`Cannot compare apples to oranges`))
-	assert.Equal(t, "text/plain; charset=utf-8", resp.Header.Get("Content-Type"))
+	before := canceledRequests.Get()
+	code, err := processRequest(req.WithContext(ctx), perr.NewProcessor, defaultConfig.processorConfig(), defaultConfig.MaxUnzippedSize, report, nil)
+	assert.Equal(t, statusClientClosedRequest, code)
+	assert.Equal(t, errRequestCanceled, err)
+	assert.False(t, reported)
+	assert.Equal(t, before+1, canceledRequests.Get())
+
+	// the client going away while the payload is processed
+	ctx, cancel = context.WithCancel(context.Background())
+	cancelable := cancelableReporter(ctx, report)
+	assert.NoError(t, cancelable(nil))
+	assert.True(t, reported)
+	cancel()
+	assert.Equal(t, errRequestCanceled, cancelable(nil))
 }
 
-func TestFailureResponseNoAcceptHeader(t *testing.T) {
+func TestFailureResponse(t *testing.T) {
+	for _, accept := range []string{"application/json", "*/*", "text/html", ""} {
+		req, err := http.NewRequest("POST", "_", nil)
+		assert.Nil(t, err)
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+
+		sendStatus(w, req, 400, errors.New("Cannot compare apples to oranges"))
+
+		resp := w.Result()
+		body, _ := ioutil.ReadAll(resp.Body)
+		assert.Equal(t, 400, w.Code)
+		assert.Equal(t, `{"code":"ERR_REQUEST","error":"Cannot compare apples to oranges","request_id":""}`, string(body))
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	}
+}
+
+func TestFailureResponseErrorCode(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{errFull, http.StatusServiceUnavailable, "ERR_QUEUE_FULL"},
+		{errTooManyRequests, http.StatusTooManyRequests, "ERR_RATE_LIMITED"},
+		{newCodedError("ERR_VALIDATION", errors.New("invalid")), http.StatusBadRequest, "ERR_VALIDATION"},
+		{errors.New("unknown"), http.StatusInternalServerError, "ERR_INTERNAL"},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.code, errorCode(c.err, c.status))
+	}
+}
+
+func TestFailureResponseRequestID(t *testing.T) {
+	h := logHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sendStatus(w, r, http.StatusServiceUnavailable, errFull)
+	}))
 	req, err := http.NewRequest("POST", "_", nil)
 	assert.Nil(t, err)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
 
-	req.Header.Del("Accept")
+	var body map[string]string
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "ERR_QUEUE_FULL", body["code"])
+	assert.Equal(t, errFull.Error(), body["error"])
+	assert.Len(t, body["request_id"], 36)
+}
+
+func TestRetryAfterHeader(t *testing.T) {
+	req, err := http.NewRequest("POST", "_", nil)
+	assert.Nil(t, err)
 
 	w := httptest.NewRecorder()
-	sendStatus(w, req, 400, errors.New("Cannot compare apples to oranges"))
+	sendStatus(w, req, http.StatusServiceUnavailable, &retryAfterError{errFull, 2500 * time.Millisecond})
+	assert.Equal(t, "3", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), "ERR_QUEUE_FULL")
+
+	w = httptest.NewRecorder()
+	sendStatus(w, req, http.StatusServiceUnavailable, &retryAfterError{errFull, 0})
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
 
-	resp := w.Result()
-	body, _ := ioutil.ReadAll(resp.Body)
-	assert.Equal(t, 400, w.Code)
-	assert.Equal(t, body, []byte(`Cannot compare apples to oranges`))
-	assert.Equal(t, "text/plain; charset=utf-8", resp.Header.Get("Content-Type"))
+	w = httptest.NewRecorder()
+	sendStatus(w, req, http.StatusBadRequest, errForbidden)
+	assert.Equal(t, "", w.Header().Get("Retry-After"))
+}
+
+func TestRateLimitRetryAfter(t *testing.T) {
+	h := ipRateLimitHandler(1, defaultConfig.Frontend.RateLimiter, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	var w *httptest.ResponseRecorder
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest("POST", "_", nil)
+		req.RemoteAddr = "10.11.12.13:8080"
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), "ERR_RATE_LIMITED")
 }
 
 func TestIsAuthorized(t *testing.T) {
@@ -142,3 +333,30 @@ func TestExtractIP(t *testing.T) {
 	assert.Equal(t, "10.11.12.13", extractIP(req(nil, nil)))
 	assert.Equal(t, "10.11.12.13", extractIP(req(new(string), new(string))))
 }
+
+func TestDisabledRoutes(t *testing.T) {
+	config := defaultConfig
+	config.DisabledRoutes = []string{"/v1/client-side/", BackendLogsURL}
+	mux := newMuxer(config, nil)
+
+	for _, path := range []string{FrontendErrorsURL, FrontendTransactionsURL, BackendLogsURL} {
+		req, err := http.NewRequest("POST", path, bytes.NewReader([]byte("{}")))
+		assert.NoError(t, err)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusGone, w.Code, path)
+		assert.Contains(t, w.Body.String(), "route disabled", path)
+	}
+
+	req, err := http.NewRequest("GET", HealthCheckURL, nil)
+	assert.NoError(t, err)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestDisabledRoutesConfig(t *testing.T) {
+	config := defaultConfig
+	config.DisabledRoutes = []string{"v1/errors"}
+	assert.Error(t, config.Validate())
+}
@@ -0,0 +1,165 @@
+package beater
+
+import (
+	"crypto/sha256"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/golang-lru"
+
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+const idempotencyKeyHeader = "Idempotency-Key"
+
+var (
+	idempotentReplayed = monitoring.NewInt(serverMetrics, "requests.idempotent_replayed")
+
+	errIdempotencyConflict = errors.New("a request with the same idempotency key is in progress")
+)
+
+type IdempotencyConfig struct {
+	TTL       time.Duration `config:"ttl"`
+	CacheSize int           `config:"cache_size" validate:"min=1"`
+}
+
+// idempotencyCache remembers the responses to requests sent with an
+// Idempotency-Key header. Requests repeating a key within the TTL get the
+// stored response, without their payload being processed again, which makes
+// retrying requests safe for agents. Only successful responses and those
+// rejecting the payload itself are stored, requests failing for other
+// reasons, like server errors or rate limits, are expected to be retried.
+type idempotencyCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu         sync.Mutex
+	responses  *lru.Cache
+	inProgress map[[sha256.Size]byte]bool
+}
+
+type storedResponse struct {
+	code        int
+	contentType string
+	body        []byte
+	expires     time.Time
+}
+
+func newIdempotencyCache(config IdempotencyConfig) *idempotencyCache {
+	if config.TTL <= 0 {
+		return nil
+	}
+	responses, _ := lru.New(config.CacheSize)
+	return &idempotencyCache{
+		ttl:        config.TTL,
+		now:        time.Now,
+		responses:  responses,
+		inProgress: map[[sha256.Size]byte]bool{},
+	}
+}
+
+// idempotencyKey identifies a request by its path, its authorization and the
+// key set by the client, so that responses are never returned to clients
+// using a different secret token.
+func idempotencyKey(r *http.Request) ([sha256.Size]byte, bool) {
+	key := r.Header.Get(idempotencyKeyHeader)
+	if key == "" {
+		return [sha256.Size]byte{}, false
+	}
+	return sha256.Sum256([]byte(r.URL.Path + "\n" + r.Header.Get("Authorization") + "\n" + key)), true
+}
+
+// begin returns the stored response for the key, or marks the key as in
+// progress if there is none.
+func (c *idempotencyCache) begin(key [sha256.Size]byte) (*storedResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if res, ok := c.responses.Get(key); ok {
+		if stored := res.(*storedResponse); c.now().Before(stored.expires) {
+			return stored, nil
+		}
+		c.responses.Remove(key)
+	}
+	if c.inProgress[key] {
+		return nil, errIdempotencyConflict
+	}
+	c.inProgress[key] = true
+	return nil, nil
+}
+
+func (c *idempotencyCache) end(key [sha256.Size]byte, rec *responseRecorder) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.inProgress, key)
+	if storableResponse(rec.code) {
+		c.responses.Add(key, &storedResponse{
+			code:        rec.code,
+			contentType: rec.Header().Get("Content-Type"),
+			body:        rec.body,
+			expires:     c.now().Add(c.ttl),
+		})
+	}
+}
+
+// storableResponse returns true for status codes that the same request
+// would get again: success, or an invalid or too large payload.
+func storableResponse(code int) bool {
+	switch {
+	case code >= 200 && code < 300:
+		return true
+	case code == http.StatusBadRequest, code == http.StatusRequestEntityTooLarge:
+		return true
+	}
+	return false
+}
+
+func (c *idempotencyCache) handler(h http.Handler) http.Handler {
+	if c == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, ok := idempotencyKey(r)
+		if !ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+		stored, err := c.begin(key)
+		if err != nil {
+			sendStatus(w, r, http.StatusConflict, err)
+			return
+		}
+		if stored != nil {
+			idempotentReplayed.Inc()
+			w.Header().Set("Idempotent-Replayed", "true")
+			if stored.contentType != "" {
+				w.Header().Set("Content-Type", stored.contentType)
+			}
+			w.WriteHeader(stored.code)
+			w.Write(stored.body)
+			return
+		}
+		rec := &responseRecorder{ResponseWriter: w, code: http.StatusOK}
+		defer c.end(key, rec)
+		h.ServeHTTP(rec, r)
+	})
+}
+
+// responseRecorder passes a response on while keeping a copy of its status
+// code and body.
+type responseRecorder struct {
+	http.ResponseWriter
+	code int
+	body []byte
+}
+
+func (r *responseRecorder) WriteHeader(code int) {
+	r.code = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	r.body = append(r.body, p...)
+	return r.ResponseWriter.Write(p)
+}
@@ -0,0 +1,108 @@
+package beater
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/apm-server/tests"
+	"github.com/elastic/beats/libbeat/beat"
+)
+
+func TestIdempotencyKeyRequests(t *testing.T) {
+	payload, err := tests.LoadValidData("transaction")
+	assert.NoError(t, err)
+
+	var reported, fail int
+	config := defaultConfig
+	config.Idempotency = IdempotencyConfig{TTL: time.Minute, CacheSize: 10}
+	mux := newMuxer(config, func(events []beat.Event) error {
+		if fail > 0 {
+			fail--
+			return errFull
+		}
+		reported++
+		return nil
+	})
+	send := func(key, token string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("POST", BackendTransactionsURL, bytes.NewReader(payload))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", token)
+		if key != "" {
+			req.Header.Set(idempotencyKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusAccepted, send("a", "").Code)
+	w := send("a", "")
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "true", w.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, 1, reported)
+
+	// keys are scoped to the authorization
+	assert.Equal(t, http.StatusAccepted, send("a", "Bearer other").Code)
+	assert.Equal(t, 2, reported)
+
+	// requests without key are always processed
+	send("", "")
+	send("", "")
+	assert.Equal(t, 4, reported)
+
+	// server errors are not stored
+	fail = 1
+	assert.Equal(t, http.StatusServiceUnavailable, send("b", "").Code)
+	w = send("b", "")
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "", w.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, 5, reported)
+}
+
+func TestIdempotencyCache(t *testing.T) {
+	now := time.Now()
+	c := newIdempotencyCache(IdempotencyConfig{TTL: time.Minute, CacheSize: 10})
+	c.now = func() time.Time { return now }
+	assert.Nil(t, newIdempotencyCache(IdempotencyConfig{CacheSize: 10}))
+
+	req, _ := http.NewRequest("POST", "/v1/errors", nil)
+	_, ok := idempotencyKey(req)
+	assert.False(t, ok)
+	req.Header.Set(idempotencyKeyHeader, "a")
+	key, ok := idempotencyKey(req)
+	assert.True(t, ok)
+
+	stored, err := c.begin(key)
+	assert.Nil(t, stored)
+	assert.NoError(t, err)
+	_, err = c.begin(key)
+	assert.Equal(t, errIdempotencyConflict, err)
+
+	c.end(key, &responseRecorder{ResponseWriter: httptest.NewRecorder(), code: http.StatusBadRequest, body: []byte("invalid")})
+	stored, err = c.begin(key)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("invalid"), stored.body)
+
+	// expired responses are not returned
+	now = now.Add(time.Minute)
+	stored, err = c.begin(key)
+	assert.NoError(t, err)
+	assert.Nil(t, stored)
+
+	// responses to requests that are to be retried are not stored
+	for _, code := range []int{http.StatusTooManyRequests, http.StatusUnauthorized, http.StatusServiceUnavailable} {
+		c.end(key, &responseRecorder{ResponseWriter: httptest.NewRecorder(), code: code})
+		stored, err = c.begin(key)
+		assert.NoError(t, err)
+		assert.Nil(t, stored, "code %d", code)
+	}
+	c.end(key, &responseRecorder{ResponseWriter: httptest.NewRecorder(), code: http.StatusAccepted})
+	stored, _ = c.begin(key)
+	assert.Equal(t, http.StatusAccepted, stored.code)
+}
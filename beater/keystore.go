@@ -0,0 +1,120 @@
+package beater
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+// KeystoreFile is the name of the keystore in the data path.
+const KeystoreFile = "apm-server.keystore"
+
+var keystoreKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Keystore holds secrets referenced in the config as ${KEY}, so that they
+// don't need to be stored in the config file. Secrets are made available to
+// the config like environment variables, which take precedence, and removed
+// from the environment once the config is loaded. The keystore is protected
+// by its file permissions only, it is not encrypted.
+type Keystore struct {
+	path     string
+	secrets  map[string]string
+	exported []string
+}
+
+// OpenKeystore reads the keystore at path. A keystore that doesn't exist yet
+// is empty.
+func OpenKeystore(path string) (*Keystore, error) {
+	k := &Keystore{path: path, secrets: map[string]string{}}
+	buf, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return k, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(buf, &k.secrets); err != nil {
+		return nil, fmt.Errorf("reading keystore %s: %v", path, err)
+	}
+	return k, nil
+}
+
+// Keys returns the keys of the stored secrets, in order.
+func (k *Keystore) Keys() []string {
+	keys := make([]string, 0, len(k.secrets))
+	for key := range k.secrets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (k *Keystore) Has(key string) bool {
+	_, ok := k.secrets[key]
+	return ok
+}
+
+// Set stores a secret, keys must be valid environment variable names.
+func (k *Keystore) Set(key, value string) error {
+	if !keystoreKeyPattern.MatchString(key) {
+		return fmt.Errorf("invalid key '%s', must only contain letters, digits and underscores", key)
+	}
+	k.secrets[key] = value
+	return nil
+}
+
+func (k *Keystore) Remove(key string) {
+	delete(k.secrets, key)
+}
+
+// Save writes the keystore, readable by the owner only.
+func (k *Keystore) Save() error {
+	buf, err := json.Marshal(k.secrets)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(k.path), KeystoreFile)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0600); err == nil {
+		_, err = tmp.Write(buf)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), k.path)
+}
+
+// SetEnv makes the secrets available for references in the config, unless
+// an environment variable of the same name is set.
+func (k *Keystore) SetEnv() error {
+	for key, value := range k.secrets {
+		if _, ok := os.LookupEnv(key); ok {
+			continue
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return err
+		}
+		k.exported = append(k.exported, key)
+	}
+	return nil
+}
+
+// UnsetEnv removes the secrets set by SetEnv from the environment, so that
+// they are not inherited by child processes. References in the config can't
+// be resolved afterwards.
+func (k *Keystore) UnsetEnv() {
+	for _, key := range k.exported {
+		os.Unsetenv(key)
+	}
+	k.exported = nil
+}
@@ -0,0 +1,60 @@
+package beater
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeystore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apm-server-keystore")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, KeystoreFile)
+
+	k, err := OpenKeystore(path)
+	assert.NoError(t, err)
+	assert.Empty(t, k.Keys())
+	assert.NoError(t, k.Set("ES_PASSWORD", "secret"))
+	assert.NoError(t, k.Set("APM_TOKEN", "token"))
+	assert.Error(t, k.Set("apm-server.secret_token", "token"))
+	assert.NoError(t, k.Save())
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	k, err = OpenKeystore(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"APM_TOKEN", "ES_PASSWORD"}, k.Keys())
+	k.Remove("APM_TOKEN")
+	assert.False(t, k.Has("APM_TOKEN"))
+	assert.True(t, k.Has("ES_PASSWORD"))
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte("{"), 0600))
+	_, err = OpenKeystore(path)
+	assert.Error(t, err)
+}
+
+func TestKeystoreSetEnv(t *testing.T) {
+	k := &Keystore{secrets: map[string]string{
+		"APM_KEYSTORE_TEST_A": "keystore",
+		"APM_KEYSTORE_TEST_B": "keystore",
+	}}
+	os.Setenv("APM_KEYSTORE_TEST_B", "env")
+	defer os.Unsetenv("APM_KEYSTORE_TEST_A")
+	defer os.Unsetenv("APM_KEYSTORE_TEST_B")
+
+	assert.NoError(t, k.SetEnv())
+	assert.Equal(t, "keystore", os.Getenv("APM_KEYSTORE_TEST_A"))
+	assert.Equal(t, "env", os.Getenv("APM_KEYSTORE_TEST_B"))
+
+	// only the secrets set from the keystore are removed
+	k.UnsetEnv()
+	_, ok := os.LookupEnv("APM_KEYSTORE_TEST_A")
+	assert.False(t, ok)
+	assert.Equal(t, "env", os.Getenv("APM_KEYSTORE_TEST_B"))
+}
@@ -0,0 +1,280 @@
+package beater
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/monitoring"
+	"github.com/elastic/beats/libbeat/outputs"
+	"github.com/elastic/beats/libbeat/setup/kibana"
+)
+
+const (
+	AgentConfigURL = "/config/v1/agents"
+
+	kibanaAgentConfigPath = "/api/apm/settings/agent-configuration/search"
+
+	// kibanaStatusInterval is how long the Kibana status reported by the
+	// healthcheck is cached, and how often connecting is retried.
+	kibanaStatusInterval = 10 * time.Second
+)
+
+var (
+	agentConfigRequests = monitoring.NewInt(serverMetrics, "requests.agent_config")
+	kibanaErrors        = monitoring.NewInt(serverMetrics, "kibana.errors")
+
+	errKibanaUnavailable  = errors.New("Kibana is not available")
+	errMissingServiceName = errors.New("service.name query parameter is required")
+)
+
+// KibanaConfig configures the connection to Kibana, which serves the central
+// agent configuration. The settings are the same as for setup.kibana, which
+// they default to, so that `apm-server setup` imports the index pattern and
+// dashboards into the same Kibana.
+type KibanaConfig struct {
+	Enabled  bool               `config:"enabled"`
+	Protocol string             `config:"protocol"`
+	Host     string             `config:"host"`
+	Path     string             `config:"path"`
+	Username string             `config:"username"`
+	Password string             `config:"password"`
+	TLS      *outputs.TLSConfig `config:"ssl"`
+	Timeout  time.Duration      `config:"timeout"`
+}
+
+func (c *KibanaConfig) Validate() error {
+	if c.Enabled && c.Host == "" {
+		return errors.New("kibana.host is required when kibana is enabled")
+	}
+	return nil
+}
+
+// kibanaConnector connects to Kibana on first use, as the server must not
+// depend on Kibana being up when it starts.
+type kibanaConnector struct {
+	config *common.Config
+
+	mu          sync.Mutex
+	client      *kibana.Client
+	status      kibanaStatus
+	statusCheck time.Time
+}
+
+type kibanaStatus struct {
+	Connected bool   `json:"connected"`
+	Version   string `json:"version,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+func newKibanaConnector(config KibanaConfig) (*kibanaConnector, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+	if _, err := outputs.LoadTLSConfig(config.TLS); err != nil {
+		return nil, err
+	}
+	settings := map[string]interface{}{
+		"host":     config.Host,
+		"path":     config.Path,
+		"username": config.Username,
+		"password": config.Password,
+	}
+	if config.Protocol != "" {
+		settings["protocol"] = config.Protocol
+	}
+	if config.Timeout > 0 {
+		settings["timeout"] = config.Timeout.String()
+	}
+	if config.TLS != nil {
+		settings["ssl"] = config.TLS
+	}
+	cfg, err := common.NewConfigFrom(settings)
+	if err != nil {
+		return nil, err
+	}
+	return &kibanaConnector{config: cfg}, nil
+}
+
+// connect returns the client, connecting first if needed. Connecting is
+// retried at most every kibanaStatusInterval.
+func (k *kibanaConnector) connect() (*kibana.Client, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.client != nil {
+		return k.client, nil
+	}
+	if !k.statusCheck.IsZero() && time.Since(k.statusCheck) < kibanaStatusInterval {
+		return nil, errKibanaUnavailable
+	}
+	k.statusCheck = time.Now()
+	client, err := kibana.NewKibanaClient(k.config)
+	if err != nil {
+		kibanaErrors.Inc()
+		k.status = kibanaStatus{Error: err.Error()}
+		logp.Err("Error connecting to Kibana: %v", err)
+		return nil, errKibanaUnavailable
+	}
+	k.client = client
+	k.status = kibanaStatus{Connected: true, Version: client.GetVersion()}
+	return client, nil
+}
+
+// currentStatus checks the status of Kibana, caching the result for
+// kibanaStatusInterval so that frequent healthchecks don't hit Kibana.
+func (k *kibanaConnector) currentStatus() kibanaStatus {
+	client, err := k.connect()
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if err != nil || time.Since(k.statusCheck) < kibanaStatusInterval {
+		return k.status
+	}
+	k.statusCheck = time.Now()
+	if err := client.SetVersion(); err != nil {
+		kibanaErrors.Inc()
+		k.status = kibanaStatus{Error: err.Error()}
+	} else {
+		k.status = kibanaStatus{Connected: true, Version: client.GetVersion()}
+	}
+	return k.status
+}
+
+// agentConfig looks up the settings configured in Kibana for the agents of a
+// service. Services without configuration get empty settings.
+func (k *kibanaConnector) agentConfig(service, environment string) (map[string]interface{}, error) {
+	client, err := k.connect()
+	if err != nil {
+		return nil, err
+	}
+	query := map[string]interface{}{
+		"service": map[string]interface{}{"name": service},
+	}
+	if environment != "" {
+		query["service"].(map[string]interface{})["environment"] = environment
+	}
+	body, err := json.Marshal(query)
+	if err != nil {
+		return nil, err
+	}
+	status, result, err := client.Request("POST", kibanaAgentConfigPath, nil, bytes.NewReader(body))
+	if status == http.StatusNotFound {
+		return map[string]interface{}{}, nil
+	}
+	if err != nil {
+		kibanaErrors.Inc()
+		logp.Err("Error fetching agent configuration from Kibana: %v", err)
+		return nil, errKibanaUnavailable
+	}
+	var doc struct {
+		Source struct {
+			Settings map[string]interface{} `json:"settings"`
+		} `json:"_source"`
+	}
+	if err := json.Unmarshal(result, &doc); err != nil {
+		return nil, fmt.Errorf("invalid agent configuration response from Kibana: %v", err)
+	}
+	if doc.Source.Settings == nil {
+		return map[string]interface{}{}, nil
+	}
+	return doc.Source.Settings, nil
+}
+
+// agentConfigHandler serves the configuration set up in Kibana for the agent
+// of the service given by the service.name and optional service.environment
+// query parameters.
+func agentConfigHandler(config Config, kibana *kibanaConnector) http.Handler {
+	return logHandler(
+		tenantAuthHandler(config.SecretToken, config.Tenants,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != "GET" {
+					sendStatus(w, r, http.StatusMethodNotAllowed, errGETRequestOnly)
+					return
+				}
+				agentConfigRequests.Inc()
+				query := r.URL.Query()
+				service := query.Get("service.name")
+				if service == "" {
+					sendStatus(w, r, http.StatusBadRequest, errMissingServiceName)
+					return
+				}
+				settings, err := kibana.agentConfig(service, query.Get("service.environment"))
+				if err != nil {
+					sendStatus(w, r, http.StatusServiceUnavailable, err)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				responseValid.Inc()
+				sendJSON(w, settings)
+			})))
+}
+
+// kibanaHealthCheckHandler responds like the healthcheck, additionally
+// reporting whether Kibana is reachable. The server is healthy either way.
+func kibanaHealthCheckHandler(kibana *kibanaConnector) http.Handler {
+	return logHandler(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			responseValid.Inc()
+			sendJSON(w, map[string]interface{}{"kibana": kibana.currentStatus()})
+		}))
+}
+
+// KibanaSettings returns the setup.kibana settings derived from the
+// apm-server.kibana config, as full setting names mapped to their values.
+// Settings configured explicitly are left out and take precedence.
+func KibanaSettings(cfg *common.Config) (map[string]interface{}, error) {
+	sub, err := cfg.Child("apm-server.kibana", -1)
+	if err != nil {
+		return nil, nil
+	}
+	var config KibanaConfig
+	if err := sub.Unpack(&config); err != nil {
+		return nil, fmt.Errorf("Error reading config file: %v", err)
+	}
+	if !config.Enabled {
+		return nil, nil
+	}
+	var fields common.MapStr
+	if err := sub.Unpack(&fields); err != nil {
+		return nil, fmt.Errorf("Error reading config file: %v", err)
+	}
+	settings := map[string]interface{}{}
+	for key, value := range fields.Flatten() {
+		name := "setup.kibana." + key
+		if key != "enabled" && !hasPath(cfg, name) {
+			settings[name] = value
+		}
+	}
+	return settings, nil
+}
+
+// TestKibana connects to the Kibana configured in apm-server.kibana,
+// returning its version.
+func TestKibana(cfg *common.Config) (string, error) {
+	config := defaultConfig.Kibana
+	if sub, err := cfg.Child("apm-server.kibana", -1); err == nil {
+		if err := sub.Unpack(&config); err != nil {
+			return "", fmt.Errorf("Error reading config file: %v", err)
+		}
+	}
+	if !config.Enabled {
+		return "", errors.New("kibana is not enabled, set apm-server.kibana.enabled")
+	}
+	connector, err := newKibanaConnector(config)
+	if err != nil {
+		return "", err
+	}
+	client, err := kibana.NewKibanaClient(connector.config)
+	if err != nil {
+		return "", err
+	}
+	return client.GetVersion(), nil
+}
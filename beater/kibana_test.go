@@ -0,0 +1,131 @@
+package beater
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func fakeKibana(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/status":
+			w.Write([]byte(`{"name": "kibana", "version": {"number": "6.4.0"}}`))
+		case kibanaAgentConfigPath:
+			body, _ := ioutil.ReadAll(r.Body)
+			var query map[string]map[string]string
+			assert.NoError(t, json.Unmarshal(body, &query))
+			if query["service"]["name"] != "opbeans" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			assert.Equal(t, "production", query["service"]["environment"])
+			w.Write([]byte(`{"_source": {"settings": {"transaction_sample_rate": "0.5"}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestAgentConfigHandler(t *testing.T) {
+	kb := fakeKibana(t)
+	defer kb.Close()
+	kbURL, _ := url.Parse(kb.URL)
+
+	config := defaultConfig
+	config.Kibana = KibanaConfig{Enabled: true, Host: kbURL.Host}
+	mux := newMuxer(config, func([]beat.Event) error { return nil })
+
+	for _, test := range []struct {
+		query string
+		code  int
+		body  string
+	}{
+		{"service.name=opbeans&service.environment=production", http.StatusOK, `{"transaction_sample_rate":"0.5"}`},
+		{"service.name=unknown", http.StatusOK, `{}`},
+		{"", http.StatusBadRequest, "ERR_MISSING_SERVICE_NAME"},
+	} {
+		req := httptest.NewRequest("GET", AgentConfigURL+"?"+test.query, nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		assert.Equal(t, test.code, w.Code, test.query)
+		assert.Contains(t, w.Body.String(), test.body, test.query)
+	}
+
+	req := httptest.NewRequest("GET", HealthCheckURL, nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"kibana": {"connected": true, "version": "6.4.0"}}`, w.Body.String())
+}
+
+func TestAgentConfigKibanaDown(t *testing.T) {
+	kb := fakeKibana(t)
+	kbURL, _ := url.Parse(kb.URL)
+	kb.Close()
+
+	config := defaultConfig
+	config.Kibana = KibanaConfig{Enabled: true, Host: kbURL.Host}
+	mux := newMuxer(config, func([]beat.Event) error { return nil })
+
+	req := httptest.NewRequest("GET", AgentConfigURL+"?service.name=opbeans", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "ERR_KIBANA_UNAVAILABLE")
+
+	req = httptest.NewRequest("GET", HealthCheckURL, nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"connected":false`)
+}
+
+func TestKibanaSettings(t *testing.T) {
+	cfg, err := common.NewConfigFrom(map[string]interface{}{
+		"apm-server.kibana": map[string]interface{}{
+			"enabled":  true,
+			"host":     "kibana:5601",
+			"username": "elastic",
+		},
+		"setup.kibana.username": "admin",
+	})
+	assert.NoError(t, err)
+	settings, err := KibanaSettings(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"setup.kibana.host": "kibana:5601"}, settings)
+
+	cfg, err = common.NewConfigFrom(map[string]interface{}{"apm-server.kibana.host": "kibana:5601"})
+	assert.NoError(t, err)
+	settings, err = KibanaSettings(cfg)
+	assert.NoError(t, err)
+	assert.Empty(t, settings)
+}
+
+func TestTestKibana(t *testing.T) {
+	kb := fakeKibana(t)
+	defer kb.Close()
+
+	cfg, err := common.NewConfigFrom(map[string]interface{}{
+		"apm-server.kibana": map[string]interface{}{"enabled": true, "host": kb.URL},
+	})
+	assert.NoError(t, err)
+	version, err := TestKibana(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, "6.4.0", version)
+
+	_, err = TestKibana(common.NewConfig())
+	assert.Error(t, err)
+
+	kb.Close()
+	_, err = TestKibana(cfg)
+	assert.Error(t, err)
+}
@@ -0,0 +1,74 @@
+package beater
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+const (
+	ExpvarURL = "/debug/vars"
+	PprofURL  = "/debug/pprof/"
+)
+
+// ManagementConfig sets up a separate listener for the operational routes,
+// the healthcheck, quota usage and debug routes, so that they aren't
+// exposed on the intake address. The listener additionally serves expvar,
+// pprof and the settings in force. It listens on localhost by default.
+type ManagementConfig struct {
+	Enabled *bool      `config:"enabled"`
+	Host    string     `config:"host"`
+	SSL     *SSLConfig `config:"ssl"`
+}
+
+func (c *ManagementConfig) isEnabled() bool {
+	return c != nil && (c.Enabled == nil || *c.Enabled)
+}
+
+// managementRoute returns true for the routes served on the management
+// listener, if enabled.
+func managementRoute(path string) bool {
+	return path == HealthCheckURL || path == QuotaUsageURL || strings.HasPrefix(path, "/debug/")
+}
+
+// addManagementRoutes registers the routes only served on the management
+// listener.
+func addManagementRoutes(mux *http.ServeMux, config Config) {
+	if !config.Management.isEnabled() {
+		return
+	}
+	logp.Info("Path %s added to request handler", ExpvarURL)
+	mux.HandleFunc(ExpvarURL, expvarHandler)
+	// the command line is not served, as it can contain settings like
+	// passwords passed with -E
+	logp.Info("Path %s added to request handler", PprofURL)
+	mux.HandleFunc(PprofURL, pprof.Index)
+	mux.HandleFunc(PprofURL+"profile", pprof.Profile)
+	mux.HandleFunc(PprofURL+"symbol", pprof.Symbol)
+	mux.HandleFunc(PprofURL+"trace", pprof.Trace)
+	logp.Info("Path %s added to request handler", EffectiveConfigURL)
+	mux.Handle(EffectiveConfigURL, effectiveConfigHandler(config))
+}
+
+// expvarHandler serves the published variables like expvar.Handler, except
+// for the command line.
+func expvarHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprintf(w, "{\n")
+	first := true
+	expvar.Do(func(kv expvar.KeyValue) {
+		if kv.Key == "cmdline" {
+			return
+		}
+		if !first {
+			fmt.Fprintf(w, ",\n")
+		}
+		first = false
+		fmt.Fprintf(w, "%q: %s", kv.Key, kv.Value)
+	})
+	fmt.Fprintf(w, "\n}\n")
+}
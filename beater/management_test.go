@@ -0,0 +1,63 @@
+package beater
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManagementListener(t *testing.T) {
+	true := true
+	cfg := defaultConfig
+	cfg.Host = "localhost:8200"
+	cfg.Quotas = QuotaConfig{Period: "hourly", Events: 100}
+
+	status := func(l listener, path string) int {
+		req, _ := http.NewRequest("GET", path, nil)
+		rec := httptest.NewRecorder()
+		l.server.Handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// without management listener, operational routes are served on host
+	listeners := newListeners(cfg, newQuotaTracker(cfg.Quotas), nopReporter)
+	assert.Len(t, listeners, 1)
+	assert.Equal(t, http.StatusOK, status(listeners[0], HealthCheckURL))
+	assert.Equal(t, http.StatusNotFound, status(listeners[0], ExpvarURL))
+
+	cfg.Management = &ManagementConfig{Enabled: &true, Host: "localhost:8202"}
+	listeners = newListeners(cfg, newQuotaTracker(cfg.Quotas), nopReporter)
+	assert.Len(t, listeners, 2)
+	intake, management := listeners[0], listeners[1]
+	assert.Equal(t, "localhost:8202", management.server.Addr)
+	for _, path := range []string{HealthCheckURL, QuotaUsageURL, ExpvarURL, PprofURL, EffectiveConfigURL} {
+		assert.Equal(t, http.StatusNotFound, status(intake, path), path)
+		assert.Equal(t, http.StatusOK, status(management, path), path)
+	}
+	assert.Equal(t, http.StatusNotFound, status(management, BackendErrorsURL))
+	assert.Equal(t, http.StatusMethodNotAllowed, status(intake, BackendErrorsURL))
+
+	// the command line is not exposed
+	assert.Equal(t, http.StatusNotFound, status(management, PprofURL+"cmdline"))
+	req, _ := http.NewRequest("GET", ExpvarURL, nil)
+	rec := httptest.NewRecorder()
+	management.server.Handler.ServeHTTP(rec, req)
+	var vars map[string]interface{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &vars))
+	assert.Contains(t, vars, "memstats")
+	assert.NotContains(t, vars, "cmdline")
+}
+
+func TestManagementConfigValidate(t *testing.T) {
+	true := true
+	c := Config{Host: "localhost:8200", Management: &ManagementConfig{Enabled: &true, Host: "localhost:8200"}}
+	assert.Error(t, c.Validate())
+	c.Management.Host = "localhost:8202"
+	assert.NoError(t, c.Validate())
+	c.Management.Enabled = new(bool)
+	c.Management.Host = "localhost:8200"
+	assert.NoError(t, c.Validate())
+}
@@ -0,0 +1,109 @@
+package beater
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+const memoryReservationKey contextKey = "memoryReservation"
+
+var (
+	inFlightBytes         = monitoring.NewInt(serverMetrics, "in_flight_bytes")
+	inFlightBytesRejected = monitoring.NewInt(serverMetrics, "requests.in_flight_bytes_rejected")
+
+	errInFlightBytes = errors.New("too much data in flight, retry later")
+)
+
+// memoryBudget caps the number of decompressed request body bytes held in
+// memory by all requests together, to prevent running out of memory when
+// many large requests arrive at the same time.
+type memoryBudget struct {
+	mu    sync.Mutex
+	limit int64
+	used  int64
+}
+
+func newMemoryBudget(limit int64) *memoryBudget {
+	if limit <= 0 {
+		return nil
+	}
+	return &memoryBudget{limit: limit}
+}
+
+// reserve charges n bytes to the budget, unless it would be exceeded. A nil
+// budget is unlimited.
+func (b *memoryBudget) reserve(n int64) bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.used+n > b.limit {
+		return false
+	}
+	b.used += n
+	inFlightBytes.Set(b.used)
+	return true
+}
+
+func (b *memoryBudget) release(n int64) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.used -= n
+	inFlightBytes.Set(b.used)
+}
+
+// memoryReservation holds the bytes reserved for a single request.
+type memoryReservation struct {
+	budget   *memoryBudget
+	reserved int64
+}
+
+// handler passes a reservation on with the request, which is charged for the
+// request body as it is read, and released once the request is handled.
+func (b *memoryBudget) handler(h http.Handler) http.Handler {
+	if b == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res := &memoryReservation{budget: b}
+		defer func() { b.release(res.reserved) }()
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), memoryReservationKey, res)))
+	})
+}
+
+// reservedReader returns a reader charging the bytes read to the reservation
+// of the request, if there is one. Once the budget is exhausted,
+// errInFlightBytes is returned.
+func reservedReader(r *http.Request, reader io.Reader) io.Reader {
+	res, ok := r.Context().Value(memoryReservationKey).(*memoryReservation)
+	if !ok {
+		return reader
+	}
+	return &memoryReservationReader{Reader: reader, res: res}
+}
+
+type memoryReservationReader struct {
+	io.Reader
+	res *memoryReservation
+}
+
+func (r *memoryReservationReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		if !r.res.budget.reserve(int64(n)) {
+			inFlightBytesRejected.Inc()
+			return n, errInFlightBytes
+		}
+		r.res.reserved += int64(n)
+	}
+	return n, err
+}
@@ -0,0 +1,59 @@
+package beater
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/apm-server/tests"
+	"github.com/elastic/beats/libbeat/beat"
+)
+
+func TestMemoryBudget(t *testing.T) {
+	assert.Nil(t, newMemoryBudget(0))
+
+	b := newMemoryBudget(10)
+	assert.True(t, b.reserve(6))
+	assert.False(t, b.reserve(5))
+	assert.True(t, b.reserve(4))
+	b.release(10)
+	assert.Equal(t, int64(0), b.used)
+}
+
+func TestMemoryBudgetRequests(t *testing.T) {
+	payload, err := tests.LoadValidData("error")
+	assert.NoError(t, err)
+
+	send := func(mux http.Handler) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("POST", BackendErrorsURL, bytes.NewReader(payload))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	config := defaultConfig
+	config.MaxInFlightBytes = int64(len(payload))
+	var inFlight int64
+	mux := newMuxer(config, func([]beat.Event) error {
+		inFlight = inFlightBytes.Get()
+		return nil
+	})
+	assert.Equal(t, http.StatusAccepted, send(mux).Code)
+	assert.Equal(t, int64(len(payload)), inFlight)
+	assert.Equal(t, int64(0), inFlightBytes.Get())
+
+	// the budget is exhausted while reading
+	config.MaxInFlightBytes = int64(len(payload) / 2)
+	w := send(newMuxer(config, func([]beat.Event) error { return nil }))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	var body map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "ERR_IN_FLIGHT_BYTES", body["code"])
+	assert.Equal(t, int64(0), inFlightBytes.Get())
+}
@@ -0,0 +1,178 @@
+package beater
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Nesting depth up to which MessagePack payloads are decoded.
+const msgpackMaxDepth = 100
+
+var (
+	// msgpackContentTypes are the content types MessagePack payloads can be
+	// sent with, there is no registered one.
+	msgpackContentTypes = []string{"application/msgpack", "application/x-msgpack", "application/vnd.msgpack"}
+
+	errMsgpackTruncated = errors.New("unexpected end of MessagePack data")
+	errMsgpackDepth     = errors.New("MessagePack data nested too deeply")
+)
+
+// msgpackToJSON converts a MessagePack encoded payload to JSON, so that it
+// can be validated and transformed like payloads sent as JSON. Payloads are
+// considerably smaller when sent as MessagePack, especially for agents
+// sending many numbers. Binary data and extension types have no JSON
+// equivalent and are rejected, as are maps with keys other than strings.
+func msgpackToJSON(buf []byte) ([]byte, error) {
+	d := msgpackDecoder{buf: buf}
+	v, err := d.decode(0)
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(buf) {
+		return nil, fmt.Errorf("%d bytes of trailing MessagePack data", len(buf)-d.pos)
+	}
+	return json.Marshal(v)
+}
+
+type msgpackDecoder struct {
+	buf []byte
+	pos int
+}
+
+func (d *msgpackDecoder) decode(depth int) (interface{}, error) {
+	if depth > msgpackMaxDepth {
+		return nil, errMsgpackDepth
+	}
+	b, err := d.read(1)
+	if err != nil {
+		return nil, err
+	}
+	switch t := b[0]; {
+	case t <= 0x7f:
+		return int64(t), nil
+	case t >= 0xe0:
+		return int64(int8(t)), nil
+	case t&0xf0 == 0x80:
+		return d.decodeMap(int(t&0x0f), depth)
+	case t&0xf0 == 0x90:
+		return d.decodeArray(int(t&0x0f), depth)
+	case t&0xe0 == 0xa0:
+		return d.decodeString(int(t & 0x1f))
+	}
+
+	switch t := b[0]; t {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xca:
+		n, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(n))), err
+	case 0xcb:
+		n, err := d.uint(8)
+		return math.Float64frombits(n), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return d.uint(1 << (t - 0xcc))
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (t - 0xd0)
+		n, err := d.uint(size)
+		// sign extend the value to 64 bit
+		shift := uint(64 - 8*size)
+		return int64(n<<shift) >> shift, err
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.uint(1 << (t - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeString(int(n))
+	case 0xdc, 0xdd:
+		n, err := d.uint(2 << (t - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeArray(int(n), depth)
+	case 0xde, 0xdf:
+		n, err := d.uint(2 << (t - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeMap(int(n), depth)
+	}
+	return nil, fmt.Errorf("unsupported MessagePack type 0x%x", b[0])
+}
+
+func (d *msgpackDecoder) decodeString(n int) (string, error) {
+	b, err := d.read(n)
+	return string(b), err
+}
+
+func (d *msgpackDecoder) decodeArray(n int, depth int) ([]interface{}, error) {
+	// every element takes at least one byte, don't allocate more than that
+	if n > len(d.buf)-d.pos {
+		return nil, errMsgpackTruncated
+	}
+	arr := make([]interface{}, n)
+	for i := range arr {
+		v, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		arr[i] = v
+	}
+	return arr, nil
+}
+
+func (d *msgpackDecoder) decodeMap(n int, depth int) (map[string]interface{}, error) {
+	if 2*n > len(d.buf)-d.pos {
+		return nil, errMsgpackTruncated
+	}
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			return nil, fmt.Errorf("MessagePack map key must be a string, got %T", k)
+		}
+		v, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = v
+	}
+	return m, nil
+}
+
+// uint reads a big endian unsigned integer of the given size in bytes.
+func (d *msgpackDecoder) uint(size int) (uint64, error) {
+	b, err := d.read(size)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	default:
+		return binary.BigEndian.Uint64(b), nil
+	}
+}
+
+func (d *msgpackDecoder) read(n int) ([]byte, error) {
+	if n < 0 || n > len(d.buf)-d.pos {
+		return nil, errMsgpackTruncated
+	}
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
@@ -0,0 +1,152 @@
+package beater
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/apm-server/tests"
+	"github.com/elastic/beats/libbeat/beat"
+)
+
+func TestMsgpackToJSON(t *testing.T) {
+	for _, test := range []struct {
+		msgpack []byte
+		json    string
+	}{
+		{[]byte{0x05}, `5`},
+		{[]byte{0xff}, `-1`},
+		{[]byte{0xd0, 0x80}, `-128`},
+		{[]byte{0xd1, 0xfc, 0x18}, `-1000`},
+		{[]byte{0xcd, 0x03, 0xe8}, `1000`},
+		{[]byte{0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, `18446744073709551615`},
+		{[]byte{0xca, 0x3f, 0xc0, 0x00, 0x00}, `1.5`},
+		{[]byte{0xc0}, `null`},
+		{[]byte{0xa3, 'f', 'o', 'o'}, `"foo"`},
+		{[]byte{0xd9, 0x03, 'f', 'o', 'o'}, `"foo"`},
+		{[]byte{0x93, 0xc3, 0xc2, 0xc0}, `[true,false,null]`},
+		{[]byte{0xdc, 0x00, 0x01, 0x01}, `[1]`},
+		{[]byte{0x82, 0xa1, 'a', 0x01, 0xa1, 'b', 0x91, 0xa1, 'c'}, `{"a":1,"b":["c"]}`},
+	} {
+		out, err := msgpackToJSON(test.msgpack)
+		assert.NoError(t, err)
+		assert.Equal(t, test.json, string(out))
+	}
+
+	for _, invalid := range [][]byte{
+		{},
+		{0xa3, 'f'},
+		{0xdd, 0xff, 0xff, 0xff, 0xff},
+		{0x81, 0x01, 0x01},
+		{0xc4, 0x01, 0x00},
+		{0x01, 0x02},
+		bytes.Repeat([]byte{0x91}, msgpackMaxDepth+2),
+	} {
+		_, err := msgpackToJSON(invalid)
+		assert.Error(t, err, "% x", invalid)
+	}
+}
+
+func TestMsgpackRequest(t *testing.T) {
+	payload, err := tests.LoadValidData("error")
+	assert.NoError(t, err)
+	var data interface{}
+	assert.NoError(t, json.Unmarshal(payload, &data))
+	var buf bytes.Buffer
+	encodeMsgpack(&buf, data)
+
+	var reported []beat.Event
+	mux := newMuxer(defaultConfig, func(events []beat.Event) error {
+		reported = events
+		return nil
+	})
+	req, err := http.NewRequest("POST", BackendErrorsURL, bytes.NewReader(buf.Bytes()))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-msgpack")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	assert.Len(t, reported, 4)
+
+	req, err = http.NewRequest("POST", BackendErrorsURL, bytes.NewReader(payload))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/msgpack")
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "ERR_DECODING")
+}
+
+func TestMsgpackDebugRequest(t *testing.T) {
+	payload, err := tests.LoadValidData("error")
+	assert.NoError(t, err)
+	var data interface{}
+	assert.NoError(t, json.Unmarshal(payload, &data))
+	var buf bytes.Buffer
+	encodeMsgpack(&buf, data)
+
+	enabled := true
+	config := defaultConfig
+	config.DebugEndpoint = &DebugEndpointConfig{Enabled: &enabled}
+	mux := newMuxer(config, nil)
+	req, err := http.NewRequest("POST", DebugTransformURL+BackendErrorsURL, bytes.NewReader(buf.Bytes()))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-msgpack")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var body struct {
+		Events []map[string]interface{} `json:"events"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Len(t, body.Events, 4)
+
+	req, err = http.NewRequest("POST", DebugTransformURL+BackendErrorsURL, bytes.NewReader(payload))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "text/plain")
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+}
+
+// encodeMsgpack encodes values decoded from JSON, using the widest formats
+// only.
+func encodeMsgpack(buf *bytes.Buffer, v interface{}) {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+	case bool:
+		if v {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case float64:
+		buf.WriteByte(0xcb)
+		binary.Write(buf, binary.BigEndian, math.Float64bits(v))
+	case string:
+		buf.WriteByte(0xdb)
+		binary.Write(buf, binary.BigEndian, uint32(len(v)))
+		buf.WriteString(v)
+	case []interface{}:
+		buf.WriteByte(0xdd)
+		binary.Write(buf, binary.BigEndian, uint32(len(v)))
+		for _, e := range v {
+			encodeMsgpack(buf, e)
+		}
+	case map[string]interface{}:
+		buf.WriteByte(0xdf)
+		binary.Write(buf, binary.BigEndian, uint32(len(v)))
+		for k, e := range v {
+			encodeMsgpack(buf, k)
+			encodeMsgpack(buf, e)
+		}
+	}
+}
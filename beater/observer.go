@@ -0,0 +1,91 @@
+package beater
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+const observerType = "apm-server"
+
+// requestReporter returns a reporter adding information about the request
+// to all events created while processing it, before forwarding them.
+// If the events of a request lie further in the future than the configured
+// clock skew threshold, all of them are shifted back by the same amount, so
+// that the latest event is set to the time the request was received.
+// Afterwards the timestamp policy is applied. Events of requests authorized
+// for a tenant are stamped with the tenant id.
+func requestReporter(r *http.Request, config Config, report reporter) reporter {
+	report = tenantReporter(r, report)
+	observer := config.Observer
+	policy := config.EventTimestamp
+	if !observer.RequestID && !observer.IngestTimestamp && config.ClockSkewThreshold <= 0 &&
+		policy.MaxFuture <= 0 && policy.MaxPast <= 0 {
+		return report
+	}
+
+	reqID := requestID(r)
+	received := time.Now()
+	return func(events []beat.Event) error {
+		skew := clockSkew(events, received, config.ClockSkewThreshold)
+		for i := range events {
+			event := &events[i]
+			if observer.RequestID {
+				event.Fields.Put("observer.request_id", reqID)
+			}
+			if observer.IngestTimestamp {
+				event.Fields.Put("observer.ingest_timestamp", common.Time(received))
+			}
+			if skew > 0 {
+				event.Timestamp = event.Timestamp.Add(-skew)
+				event.Fields.Put("observer.clock_skew", common.MapStr{"us": int64(skew / time.Microsecond)})
+			}
+		}
+		if err := applyTimestampPolicy(events, received, policy); err != nil {
+			return err
+		}
+		return report(events)
+	}
+}
+
+// clockSkew returns by how much the latest event timestamp lies ahead of the
+// time the request was received, if it exceeds the threshold. Events in the
+// past can't be told apart from events that were buffered by the agent, so
+// they are never considered skewed.
+func clockSkew(events []beat.Event, received time.Time, threshold time.Duration) time.Duration {
+	if threshold <= 0 || len(events) == 0 {
+		return 0
+	}
+	latest := events[0].Timestamp
+	for _, event := range events[1:] {
+		if event.Timestamp.After(latest) {
+			latest = event.Timestamp
+		}
+	}
+	if skew := latest.Sub(received); skew > threshold {
+		return skew
+	}
+	return 0
+}
+
+// observerReporter returns a reporter adding information about this
+// server instance to all events before forwarding them.
+func observerReporter(info beat.Info, config Config, report reporter) reporter {
+	observer := common.MapStr{
+		"hostname":  info.Hostname,
+		"version":   info.Version,
+		"id":        info.UUID.String(),
+		"type":      observerType,
+		"listening": config.Host,
+	}
+	return func(events []beat.Event) error {
+		for _, event := range events {
+			for k, v := range observer {
+				event.Fields.Put("observer."+k, v)
+			}
+		}
+		return report(events)
+	}
+}
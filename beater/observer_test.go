@@ -0,0 +1,112 @@
+package beater
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestRequestReporter(t *testing.T) {
+	var reported []beat.Event
+	report := func(events []beat.Event) error {
+		reported = events
+		return nil
+	}
+
+	cases := []struct {
+		config ObserverConfig
+		keys   []string
+	}{
+		{config: ObserverConfig{}, keys: nil},
+		{config: ObserverConfig{RequestID: true}, keys: []string{"request_id"}},
+		{config: ObserverConfig{RequestID: true, IngestTimestamp: true}, keys: []string{"request_id", "ingest_timestamp"}},
+	}
+	for _, c := range cases {
+		var reqID string
+		h := logHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reqID = requestID(r)
+			requestReporter(r, Config{Observer: c.config}, report)([]beat.Event{{Fields: common.MapStr{}}})
+		}))
+		req, _ := http.NewRequest("POST", "_", nil)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		assert.Len(t, reported, 1)
+		observer, _ := reported[0].Fields.GetValue("observer")
+		if c.keys == nil {
+			assert.Nil(t, observer)
+			continue
+		}
+		assert.Len(t, observer, len(c.keys))
+		for _, k := range c.keys {
+			assert.Contains(t, observer, k)
+		}
+		assert.Equal(t, reqID, observer.(common.MapStr)["request_id"])
+	}
+}
+
+func TestObserverReporter(t *testing.T) {
+	var reported []beat.Event
+	report := func(events []beat.Event) error {
+		reported = events
+		return nil
+	}
+
+	info := beat.Info{Hostname: "myhost", Version: "7.0.0"}
+	config := Config{Host: "localhost:8200"}
+	events := []beat.Event{
+		{Fields: common.MapStr{}},
+		{Fields: common.MapStr{"observer": common.MapStr{"request_id": "123"}}},
+	}
+	err := observerReporter(info, config, report)(events)
+	assert.NoError(t, err)
+
+	assert.Len(t, reported, 2)
+	expected := common.MapStr{
+		"hostname":  "myhost",
+		"version":   "7.0.0",
+		"id":        info.UUID.String(),
+		"type":      "apm-server",
+		"listening": "localhost:8200",
+	}
+	assert.Equal(t, expected, reported[0].Fields["observer"])
+	expected["request_id"] = "123"
+	assert.Equal(t, expected, reported[1].Fields["observer"])
+}
+
+func TestRequestReporterClockSkew(t *testing.T) {
+	var reported []beat.Event
+	report := func(events []beat.Event) error {
+		reported = events
+		return nil
+	}
+	req, _ := http.NewRequest("POST", "_", nil)
+	config := Config{ClockSkewThreshold: time.Minute}
+
+	now := time.Now()
+	inSync := []beat.Event{
+		{Timestamp: now.Add(-time.Hour), Fields: common.MapStr{}},
+		{Timestamp: now.Add(30 * time.Second), Fields: common.MapStr{}},
+	}
+	requestReporter(req, config, report)(inSync)
+	assert.Equal(t, now.Add(-time.Hour), reported[0].Timestamp)
+	assert.Equal(t, now.Add(30*time.Second), reported[1].Timestamp)
+	assert.Nil(t, reported[0].Fields["observer"])
+
+	skewed := []beat.Event{
+		{Timestamp: now.Add(time.Hour), Fields: common.MapStr{}},
+		{Timestamp: now.Add(2 * time.Hour), Fields: common.MapStr{}},
+	}
+	reporter := requestReporter(req, config, report)
+	reporter(skewed)
+	skew := reported[1].Timestamp.Sub(now.Add(2 * time.Hour))
+	assert.True(t, skew < -2*time.Hour+time.Second && skew > -2*time.Hour-time.Second, skew.String())
+	assert.Equal(t, time.Hour, reported[1].Timestamp.Sub(reported[0].Timestamp))
+	us, _ := reported[0].Fields.GetValue("observer.clock_skew.us")
+	assert.Equal(t, int64(-skew/time.Microsecond), us)
+}
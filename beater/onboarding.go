@@ -1,6 +1,9 @@
 package beater
 
 import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
 	"time"
 
 	"github.com/elastic/beats/libbeat/beat"
@@ -8,11 +11,43 @@ import (
 	"github.com/elastic/beats/libbeat/logp"
 )
 
-func notifyListening(config Config, reporter reporter) {
+// onboardingFile is stored in the data path once the onboarding document was
+// acknowledged by the output, recording when the server was first seen.
+const onboardingFile = "onboarding.json"
+
+// OnboardingConfig controls the document published once the server is up,
+// which the Kibana setup instructions look for to tell whether a server is
+// running and connected to Elasticsearch. By default it is only published on
+// the first start of a server.
+type OnboardingConfig struct {
+	Enabled *bool `config:"enabled"`
+	Once    bool  `config:"once"`
+}
+
+func (c *OnboardingConfig) isEnabled() bool {
+	return c != nil && (c.Enabled == nil || *c.Enabled)
+}
+
+type onboardingState struct {
+	FirstSeen time.Time `json:"first_seen"`
+}
+
+func notifyListening(info beat.Info, config Config, statePath string, reporter reporter) {
+	if !config.Onboarding.isEnabled() {
+		return
+	}
+	state, seen := readOnboardingState(statePath)
+	if seen && config.Onboarding.Once {
+		logp.Debug("onboarding", "Onboarding document already published on %s", state.FirstSeen)
+		return
+	}
 
 	var isServerUp = func() bool {
-		secure := config.SSL.isEnabled()
-		return isServerUp(secure, config.Host, 10, time.Second)
+		// the healthcheck is only served on the management listener if enabled
+		if config.Management.isEnabled() {
+			return isServerUp(config.Management.SSL.isEnabled(), config.Management.Host, 10, time.Second)
+		}
+		return isServerUp(config.SSL.isEnabled(), config.Host, 10, time.Second)
 	}
 
 	if isServerUp() {
@@ -20,9 +55,51 @@ func notifyListening(config Config, reporter reporter) {
 
 		event := beat.Event{
 			Timestamp: time.Now(),
-			Fields:    common.MapStr{"listening": config.Host},
+			Fields: common.MapStr{
+				"listening": config.Host,
+				"processor": common.MapStr{"name": "onboarding", "event": "onboarding"},
+				"onboarding": common.MapStr{
+					"first_seen": common.Time(state.FirstSeen),
+				},
+			},
+		}
+		tracker := &publishTracker{}
+		report := observerReporter(info, config, trackingReporter(tracker, reporter))
+		if err := report([]beat.Event{event}); err != nil {
+			logp.Err("Error publishing onboarding document: %v", err)
+			return
 		}
-		events := []beat.Event{event}
-		reporter(events)
+		tracker.acked.Wait()
+		if !seen {
+			writeOnboardingState(statePath, state)
+		}
+	}
+}
+
+// readOnboardingState returns the state stored when the onboarding document
+// was first published, or a new state if there is none.
+func readOnboardingState(path string) (onboardingState, bool) {
+	state := onboardingState{FirstSeen: time.Now()}
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logp.Warn("Error reading onboarding state: %v", err)
+		}
+		return state, false
+	}
+	if err := json.Unmarshal(buf, &state); err != nil {
+		logp.Warn("Error reading onboarding state from %s: %v", path, err)
+		return onboardingState{FirstSeen: time.Now()}, false
+	}
+	return state, true
+}
+
+func writeOnboardingState(path string, state onboardingState) {
+	buf, err := json.Marshal(state)
+	if err == nil {
+		err = ioutil.WriteFile(path, buf, 0600)
+	}
+	if err != nil {
+		logp.Warn("Error writing onboarding state to %s: %v", path, err)
 	}
 }
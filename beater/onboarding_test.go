@@ -1,14 +1,24 @@
 package beater
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
 	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
 )
 
 func TestNotifyUpServerDown(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apm-server-onboarding")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	statePath := filepath.Join(dir, onboardingFile)
+
 	config := defaultConfig
 	var saved []beat.Event
 	var reporter = func(events []beat.Event) error {
@@ -18,9 +28,33 @@ func TestNotifyUpServerDown(t *testing.T) {
 
 	server := newServer(config, reporter)
 	go run(server, config)
+	defer stop(server, 0)
 
-	notifyListening(config, reporter)
+	info := beat.Info{Version: "1.2.3"}
+	notifyListening(info, config, statePath, reporter)
 
+	assert.Len(t, saved, 1)
 	listening := saved[0].Fields["listening"].(string)
 	assert.Equal(t, "localhost:8200", listening)
+	event, _ := saved[0].Fields.GetValue("processor.event")
+	assert.Equal(t, "onboarding", event)
+	version, _ := saved[0].Fields.GetValue("observer.version")
+	assert.Equal(t, "1.2.3", version)
+	firstSeen, err := saved[0].Fields.GetValue("onboarding.first_seen")
+	assert.NoError(t, err)
+
+	// published only on the first start by default
+	notifyListening(info, config, statePath, reporter)
+	assert.Len(t, saved, 1)
+
+	config.Onboarding.Once = false
+	notifyListening(info, config, statePath, reporter)
+	assert.Len(t, saved, 2)
+	secondSeen, _ := saved[1].Fields.GetValue("onboarding.first_seen")
+	assert.True(t, time.Time(firstSeen.(common.Time)).Equal(time.Time(secondSeen.(common.Time))))
+
+	disabled := false
+	config.Onboarding.Enabled = &disabled
+	notifyListening(info, config, statePath, reporter)
+	assert.Len(t, saved, 2)
 }
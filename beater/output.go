@@ -0,0 +1,55 @@
+package beater
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/outputs"
+)
+
+type logstashOutputConfig struct {
+	Hosts       []string           `config:"hosts"`
+	LoadBalance bool               `config:"loadbalance"`
+	TLS         *outputs.TLSConfig `config:"ssl"`
+}
+
+// checkOutput validates the output configuration when the server starts,
+// instead of failing once the first events are published.
+func checkOutput(beatConfig *beat.BeatConfig, config Config) error {
+	if beatConfig == nil || !beatConfig.Output.IsSet() {
+		return nil
+	}
+	name := beatConfig.Output.Name()
+	if config.Sink.Type != "" && config.Sink.Type != sinkPipeline {
+		logp.Warn("Events are written to the %s sink, the configured %s output is not used", config.Sink.Type, name)
+		return nil
+	}
+	if name == "logstash" {
+		return checkLogstashOutput(beatConfig.Output.Config())
+	}
+	return nil
+}
+
+// checkLogstashOutput validates the hosts and TLS settings of the Logstash
+// output. Events are indexed by Logstash, so the index template needs to be
+// loaded into Elasticsearch manually.
+func checkLogstashOutput(cfg *common.Config) error {
+	var config logstashOutputConfig
+	if err := cfg.Unpack(&config); err != nil {
+		return fmt.Errorf("invalid output.logstash config: %v", err)
+	}
+	if len(config.Hosts) == 0 {
+		return errors.New("output.logstash.hosts must not be empty")
+	}
+	if _, err := outputs.LoadTLSConfig(config.TLS); err != nil {
+		return fmt.Errorf("invalid output.logstash.ssl config: %v", err)
+	}
+	if len(config.Hosts) > 1 && !config.LoadBalance {
+		logp.Info("Logstash load balancing disabled, events are sent to one of %d hosts at a time", len(config.Hosts))
+	}
+	logp.Info("Publishing events to Logstash, the index template is not loaded automatically, run `apm-server setup --template` with the Elasticsearch output enabled to load it")
+	return nil
+}
@@ -0,0 +1,61 @@
+package beater
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestCheckOutput(t *testing.T) {
+	for name, test := range map[string]struct {
+		output map[string]interface{}
+		sink   string
+		valid  bool
+	}{
+		"none": {
+			valid: true,
+		},
+		"elasticsearch": {
+			output: map[string]interface{}{"elasticsearch.hosts": []string{"localhost:9200"}},
+			valid:  true,
+		},
+		"logstash": {
+			output: map[string]interface{}{"logstash.hosts": []string{"a:5044", "b:5044"}, "logstash.loadbalance": true},
+			valid:  true,
+		},
+		"logstash without hosts": {
+			output: map[string]interface{}{"logstash.loadbalance": true},
+		},
+		"logstash with missing certificate": {
+			output: map[string]interface{}{
+				"logstash.hosts":                       []string{"localhost:5044"},
+				"logstash.ssl.certificate_authorities": []string{"/does/not/exist.pem"},
+			},
+		},
+		"logstash not used": {
+			output: map[string]interface{}{"logstash.loadbalance": true},
+			sink:   "null",
+			valid:  true,
+		},
+	} {
+		var beatConfig beat.BeatConfig
+		if test.output != nil {
+			cfg, err := common.NewConfigFrom(map[string]interface{}{"output": test.output})
+			assert.NoError(t, err)
+			assert.NoError(t, cfg.Unpack(&beatConfig))
+		}
+		config := defaultConfig
+		if test.sink != "" {
+			config.Sink.Type = test.sink
+		}
+		err := checkOutput(&beatConfig, config)
+		if test.valid {
+			assert.NoError(t, err, name)
+		} else {
+			assert.Error(t, err, name)
+		}
+	}
+}
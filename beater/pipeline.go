@@ -0,0 +1,129 @@
+package beater
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+// Number of events the libbeat memory queue holds, unless configured.
+const defaultQueueEvents = 4096
+
+var (
+	pipelineMetrics = monitoring.Default.NewRegistry("apm-server.pipeline")
+
+	// outputFailed holds libbeat's *monitoring.Uint counting the events the
+	// Elasticsearch output failed to index and retries.
+	outputFailed atomic.Value
+)
+
+func init() {
+	monitoring.NewFunc(pipelineMetrics, "output_failed_events", func(_ monitoring.Mode, vs monitoring.Visitor) {
+		vs.OnInt(outputFailedEvents())
+	})
+}
+
+// PipelineConfig tunes how events are batched and sent to Elasticsearch. The
+// libbeat defaults are meant for beats shipping few large documents, APM
+// agents send many small ones at a high rate, which are indexed more
+// efficiently in bigger bulk requests.
+type PipelineConfig struct {
+	BulkMaxSize   int           `config:"bulk_max_size"`
+	FlushInterval time.Duration `config:"flush_interval"`
+	Workers       int           `config:"workers"`
+}
+
+func (c *PipelineConfig) Validate() error {
+	if c.BulkMaxSize < 1 {
+		return errors.New("pipeline.bulk_max_size must be at least 1")
+	}
+	if c.FlushInterval <= 0 {
+		return errors.New("pipeline.flush_interval must be positive")
+	}
+	if c.Workers < 1 {
+		return errors.New("pipeline.workers must be at least 1")
+	}
+	return nil
+}
+
+// PipelineSettings returns the libbeat output and queue settings derived
+// from the apm-server.pipeline config, as full setting names mapped to their
+// values. Settings configured explicitly are left out and take precedence.
+// Nothing is returned if events are not sent to Elasticsearch.
+func PipelineSettings(cfg *common.Config) (map[string]interface{}, error) {
+	es, err := cfg.Child("output.elasticsearch", -1)
+	if err != nil || !es.Enabled() {
+		return nil, nil
+	}
+	pipeline := defaultConfig.Pipeline
+	if sub, err := cfg.Child("apm-server.pipeline", -1); err == nil {
+		if err := sub.Unpack(&pipeline); err != nil {
+			return nil, fmt.Errorf("Error reading config file: %v", err)
+		}
+	}
+	queue := struct {
+		Events int `config:"events"`
+	}{defaultQueueEvents}
+	if sub, err := cfg.Child("queue.mem", -1); err == nil {
+		if err := sub.Unpack(&queue); err != nil {
+			return nil, fmt.Errorf("Error reading config file: %v", err)
+		}
+	}
+
+	// the queue hands events to the output once a full bulk is available or
+	// the flush interval passed, it can't wait for more events than it holds
+	minEvents := pipeline.BulkMaxSize
+	if minEvents > queue.Events {
+		minEvents = queue.Events
+	}
+
+	settings := map[string]interface{}{}
+	for name, value := range map[string]interface{}{
+		"output.elasticsearch.bulk_max_size": pipeline.BulkMaxSize,
+		"output.elasticsearch.worker":        pipeline.Workers,
+		"queue.mem.flush.timeout":            pipeline.FlushInterval.String(),
+		"queue.mem.flush.min_events":         minEvents,
+	} {
+		if !hasPath(cfg, name) {
+			settings[name] = value
+		}
+	}
+	return settings, nil
+}
+
+// watchOutputFailed picks up the counter of failed events libbeat registers
+// when loading the output, if events are sent to Elasticsearch. Besides
+// indexing errors, it counts the items Elasticsearch rejects with 429 once its
+// bulk queue is full, which are retried.
+func watchOutputFailed(registry *monitoring.Registry, output string) {
+	if output != "elasticsearch" {
+		return
+	}
+	if failed, ok := registry.Get("libbeat.output.events.failed").(*monitoring.Uint); ok {
+		outputFailed.Store(failed)
+	}
+}
+
+// outputFailedEvents returns the number of events the Elasticsearch output
+// failed to index, it is reported as apm-server.pipeline.output_failed_events.
+func outputFailedEvents() int64 {
+	if failed, ok := outputFailed.Load().(*monitoring.Uint); ok && failed != nil {
+		return int64(failed.Get())
+	}
+	return 0
+}
+
+// hasPath reports whether the dotted setting is configured.
+func hasPath(cfg *common.Config, path string) bool {
+	idx := strings.LastIndex(path, ".")
+	if idx < 0 {
+		return cfg.HasField(path)
+	}
+	parent, err := cfg.Child(path[:idx], -1)
+	return err == nil && parent.HasField(path[idx+1:])
+}
@@ -0,0 +1,96 @@
+package beater
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+func TestPipelineSettings(t *testing.T) {
+	for name, test := range map[string]struct {
+		config   map[string]interface{}
+		settings map[string]interface{}
+	}{
+		"defaults": {
+			config: map[string]interface{}{"output.elasticsearch.hosts": []string{"localhost:9200"}},
+			settings: map[string]interface{}{
+				"output.elasticsearch.bulk_max_size": 2048,
+				"output.elasticsearch.worker":        2,
+				"queue.mem.flush.timeout":            "1s",
+				"queue.mem.flush.min_events":         2048,
+			},
+		},
+		"configured": {
+			config: map[string]interface{}{
+				"apm-server.pipeline.bulk_max_size":  8192,
+				"apm-server.pipeline.flush_interval": "200ms",
+				"output.elasticsearch.hosts":         []string{"localhost:9200"},
+				"output.elasticsearch.worker":        4,
+			},
+			settings: map[string]interface{}{
+				"output.elasticsearch.bulk_max_size": 8192,
+				"queue.mem.flush.timeout":            "200ms",
+				"queue.mem.flush.min_events":         defaultQueueEvents,
+			},
+		},
+		"queue configured": {
+			config: map[string]interface{}{
+				"output.elasticsearch.hosts": []string{"localhost:9200"},
+				"queue.mem.events":           1024,
+				"queue.mem.flush.timeout":    "5s",
+			},
+			settings: map[string]interface{}{
+				"output.elasticsearch.bulk_max_size": 2048,
+				"output.elasticsearch.worker":        2,
+				"queue.mem.flush.min_events":         1024,
+			},
+		},
+		"elasticsearch disabled": {
+			config: map[string]interface{}{
+				"output.elasticsearch.enabled": false,
+				"output.logstash.hosts":        []string{"localhost:5044"},
+			},
+		},
+		"logstash": {
+			config: map[string]interface{}{"output.logstash.hosts": []string{"localhost:5044"}},
+		},
+	} {
+		cfg, err := common.NewConfigFrom(test.config)
+		assert.NoError(t, err)
+		settings, err := PipelineSettings(cfg)
+		assert.NoError(t, err, name)
+		if test.settings == nil {
+			assert.Empty(t, settings, name)
+		} else {
+			assert.Equal(t, test.settings, settings, name)
+		}
+	}
+
+	cfg, err := common.NewConfigFrom(map[string]interface{}{
+		"apm-server.pipeline.workers": 0,
+		"output.elasticsearch.hosts":  []string{"localhost:9200"},
+	})
+	assert.NoError(t, err)
+	_, err = PipelineSettings(cfg)
+	assert.Error(t, err)
+}
+
+func TestOutputFailedEvents(t *testing.T) {
+	defer outputFailed.Store((*monitoring.Uint)(nil))
+
+	registry := monitoring.NewRegistry()
+	failed := monitoring.NewUint(registry.NewRegistry("libbeat").NewRegistry("output"), "events.failed")
+	failed.Add(3)
+
+	watchOutputFailed(registry, "logstash")
+	assert.Equal(t, int64(0), outputFailedEvents())
+
+	watchOutputFailed(registry, "elasticsearch")
+	assert.Equal(t, int64(3), outputFailedEvents())
+	failed.Inc()
+	snapshot := monitoring.CollectFlatSnapshot(pipelineMetrics, monitoring.Full, false)
+	assert.Equal(t, int64(4), snapshot.Ints["output_failed_events"])
+}
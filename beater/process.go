@@ -0,0 +1,55 @@
+package beater
+
+import (
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+var processRedacted = monitoring.NewInt(serverMetrics, "events.process_redacted")
+
+// ProcessConfig decides whether the command line arguments and the title of
+// the process an app runs in are kept. Both are captured by agents, but
+// often contain secrets like connection strings or tokens.
+type ProcessConfig struct {
+	CaptureArgv  bool `config:"capture_argv"`
+	CaptureTitle bool `config:"capture_title"`
+}
+
+// droppedAppKeys returns the keys of the app fields that are not to be kept.
+func (c ProcessConfig) droppedAppKeys() []string {
+	var keys []string
+	if !c.CaptureArgv {
+		keys = append(keys, "argv")
+	}
+	if !c.CaptureTitle {
+		keys = append(keys, "process_title")
+	}
+	return keys
+}
+
+// processReporter returns a reporter removing the process fields that are
+// not to be kept from the events before forwarding them. It must come before
+// any reporter handing events to other processes, like the exec filters.
+func processReporter(config ProcessConfig, report reporter) reporter {
+	var keys []string
+	for _, key := range config.droppedAppKeys() {
+		keys = append(keys, "context.app."+key)
+	}
+	if len(keys) == 0 {
+		return report
+	}
+	return func(events []beat.Event) error {
+		for _, event := range events {
+			redacted := false
+			for _, key := range keys {
+				if event.Fields.Delete(key) == nil {
+					redacted = true
+				}
+			}
+			if redacted {
+				processRedacted.Inc()
+			}
+		}
+		return report(events)
+	}
+}
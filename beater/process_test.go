@@ -0,0 +1,47 @@
+package beater
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestProcessReporter(t *testing.T) {
+	var reported []beat.Event
+	report := func(events []beat.Event) error {
+		reported = events
+		return nil
+	}
+	event := func() beat.Event {
+		return beat.Event{Fields: common.MapStr{"context": common.MapStr{"app": common.MapStr{
+			"name":          "app",
+			"argv":          []string{"-password", "secret"},
+			"process_title": "node",
+		}}}}
+	}
+
+	before := processRedacted.Get()
+	events := []beat.Event{event(), {Fields: common.MapStr{"trace": common.MapStr{"name": "query"}}}}
+	assert.NoError(t, processReporter(ProcessConfig{CaptureTitle: true}, report)(events))
+	assert.Equal(t, common.MapStr{"name": "app", "process_title": "node"}, reported[0].Fields["context"].(common.MapStr)["app"])
+	assert.Equal(t, common.MapStr{"trace": common.MapStr{"name": "query"}}, reported[1].Fields)
+	assert.Equal(t, before+1, processRedacted.Get())
+
+	assert.NoError(t, processReporter(ProcessConfig{}, report)([]beat.Event{event()}))
+	assert.Equal(t, common.MapStr{"name": "app"}, reported[0].Fields["context"].(common.MapStr)["app"])
+}
+
+func TestProcessReporterCaptureAll(t *testing.T) {
+	var reported []beat.Event
+	report := func(events []beat.Event) error {
+		reported = events
+		return nil
+	}
+	fields := common.MapStr{"context": common.MapStr{"app": common.MapStr{"argv": []string{"-v"}, "process_title": "node"}}}
+	config := defaultConfig.Process
+	assert.NoError(t, processReporter(config, report)([]beat.Event{{Fields: fields.Clone()}}))
+	assert.Equal(t, fields, reported[0].Fields)
+}
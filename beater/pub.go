@@ -3,9 +3,12 @@ package beater
 import (
 	"errors"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/monitoring"
 )
 
 // publisher forwards batches of events to libbeat. It uses GuaranteedSend
@@ -16,26 +19,50 @@ import (
 // number requests(events) active in the system can exceed the queue size. Only
 // the number of concurrent HTTP requests trying to publish at the same time is limited.
 type publisher struct {
-	events chan []beat.Event
-	client beat.Client
-	wg     sync.WaitGroup
+	// avgPublishDuration is a moving average of the time in nanoseconds it
+	// takes to forward a batch to libbeat, used to estimate the queue drain time.
+	// avgEnqueueLatency is a moving average of the time in nanoseconds
+	// requests wait for their batch to be accepted by the queue.
+	// Both are accessed atomically, keep them first for 64-bit alignment.
+	avgPublishDuration int64
+	avgEnqueueLatency  int64
+
+	events  chan []beat.Event
+	client  beat.Client
+	timeout time.Duration
+	wg      sync.WaitGroup
 }
 
 var (
 	errFull              = errors.New("Queue is full")
 	errInvalidBufferSize = errors.New("Request buffer must be > 0")
+
+	queueMetrics  = monitoring.Default.NewRegistry("apm-server.queue")
+	queueCapacity = monitoring.NewInt(queueMetrics, "capacity")
+	queueBatches  = monitoring.NewInt(queueMetrics, "batches")
+	queueEvents   = monitoring.NewInt(queueMetrics, "events")
+	queueFull     = monitoring.NewInt(queueMetrics, "full")
+
+	queueRejectedEvents  = monitoring.NewInt(queueMetrics, "rejected_events")
+	queueEnqueueLatency  = monitoring.NewInt(queueMetrics, "enqueue_latency.us")
+	queuePublishDuration = monitoring.NewInt(queueMetrics, "publish_duration.us")
+	queuePublishedBatch  = monitoring.NewInt(queueMetrics, "published.batches")
+	queuePublishedEvents = monitoring.NewInt(queueMetrics, "published.events")
+	queueLastBatchSize   = monitoring.NewInt(queueMetrics, "published.last_batch_size")
 )
 
 // newPublisher creates a new publisher instance. A new go-routine is started
 // for forwarding events to libbeat. Stop must be called to close the
-// beat.Client and free resources.
-func newPublisher(pipeline beat.Pipeline, N int) (*publisher, error) {
+// beat.Client and free resources. Sending a batch fails if the queue stays
+// full for longer than timeout.
+func newPublisher(pipeline beat.Pipeline, N int, timeout time.Duration) (*publisher, error) {
 	if N <= 0 {
 		return nil, errInvalidBufferSize
 	}
 
 	client, err := pipeline.ConnectWith(beat.ClientConfig{
 		PublishMode: beat.GuaranteedSend,
+		ACKEvents:   trackAcked,
 
 		// TODO: We want to wait for events in pipeline on shutdown?
 		//       If set >0 `Close` will block for the duration or until pipeline is empty
@@ -46,12 +73,14 @@ func newPublisher(pipeline beat.Pipeline, N int) (*publisher, error) {
 	}
 
 	p := &publisher{
-		client: client,
+		client:  client,
+		timeout: timeout,
 
 		// Set channel size to N - 1. One request will be actively processed by the
 		// worker, while the other concurrent requests will be buffered in the queue.
 		events: make(chan []beat.Event, N-1),
 	}
+	queueCapacity.Set(int64(cap(p.events)))
 
 	p.wg.Add(1)
 	go p.run()
@@ -68,20 +97,66 @@ func (p *publisher) Stop() {
 }
 
 // Send tries to forward events to the publishers worker. If the queue is full,
-// an error is returned.
+// an error is returned, containing an estimate of when the queue will be drained.
 // Calling send after Stop will cause a panic.
 func (p *publisher) Send(batch []beat.Event) error {
+	start := time.Now()
+	// counted before enqueuing, as the worker might publish the batch right away
+	queueEvents.Add(int64(len(batch)))
+	trackEnqueued(batch, 1)
 	select {
 	case p.events <- batch:
+		queueBatches.Set(int64(len(p.events)))
+		latency := updateMovingAverage(&p.avgEnqueueLatency, time.Since(start))
+		queueEnqueueLatency.Set(int64(latency / time.Microsecond))
 		return nil
-	case <-time.After(time.Second * 1): // this forces the go scheduler to try something else for a while
-		return errFull
+	case <-time.After(p.timeout): // this forces the go scheduler to try something else for a while
+		queueEvents.Sub(int64(len(batch)))
+		trackEnqueued(batch, -1)
+		queueFull.Inc()
+		queueRejectedEvents.Add(int64(len(batch)))
+		estimate := p.drainEstimate()
+		logp.Warn("Queue is full, rejected %d events: queued batches=%d, queued events=%d, drain estimate=%s",
+			len(batch), len(p.events), queueEvents.Get(), estimate)
+		return &retryAfterError{errFull, estimate}
 	}
 }
 
+// drainEstimate returns the expected time until all queued batches
+// are forwarded.
+func (p *publisher) drainEstimate() time.Duration {
+	avg := atomic.LoadInt64(&p.avgPublishDuration)
+	return time.Duration(avg * int64(len(p.events)+1))
+}
+
 func (p *publisher) run() {
 	defer p.wg.Done()
 	for batch := range p.events {
+		queueBatches.Set(int64(len(p.events)))
+		queueEvents.Sub(int64(len(batch)))
+		start := time.Now()
 		p.client.PublishAll(batch)
+		duration := time.Since(start)
+		trackPublished(batch)
+		avg := updateMovingAverage(&p.avgPublishDuration, duration)
+
+		queuePublishDuration.Set(int64(avg / time.Microsecond))
+		queuePublishedBatch.Inc()
+		queuePublishedEvents.Add(int64(len(batch)))
+		queueLastBatchSize.Set(int64(len(batch)))
+		logp.Debug("publisher", "Published batch: events=%d, duration=%s, queued batches=%d",
+			len(batch), duration, len(p.events))
+	}
+}
+
+// updateMovingAverage adds the duration to the moving average stored in avg
+// and returns the new average.
+func updateMovingAverage(avg *int64, d time.Duration) time.Duration {
+	old := atomic.LoadInt64(avg)
+	updated := int64(d)
+	if old != 0 {
+		updated = (old*7 + int64(d)) / 8
 	}
+	atomic.StoreInt64(avg, updated)
+	return time.Duration(updated)
 }
@@ -0,0 +1,70 @@
+package beater
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+)
+
+// blockingPipeline publishes events only once release is closed.
+type blockingPipeline struct {
+	release chan struct{}
+	ack     func([]interface{})
+
+	mu        sync.Mutex
+	published []beat.Event
+}
+
+func (p *blockingPipeline) Connect() (beat.Client, error) { return p, nil }
+func (p *blockingPipeline) ConnectWith(config beat.ClientConfig) (beat.Client, error) {
+	p.ack = config.ACKEvents
+	return p, nil
+}
+func (p *blockingPipeline) SetACKHandler(beat.PipelineACKHandler) error { return nil }
+func (p *blockingPipeline) Publish(event beat.Event)                    { p.PublishAll([]beat.Event{event}) }
+func (p *blockingPipeline) Close() error                                { return nil }
+
+func (p *blockingPipeline) PublishAll(events []beat.Event) {
+	<-p.release
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.published = append(p.published, events...)
+}
+
+func TestPublisherMetrics(t *testing.T) {
+	pipeline := &blockingPipeline{release: make(chan struct{})}
+	pub, err := newPublisher(pipeline, 2, time.Second)
+	assert.NoError(t, err)
+
+	batches, events, queued := queuePublishedBatch.Get(), queuePublishedEvents.Get(), queueEvents.Get()
+	full, rejected := queueFull.Get(), queueRejectedEvents.Get()
+
+	// the first batch is taken by the worker, blocking on publishing, the
+	// second one fills the queue and the third one is rejected
+	assert.NoError(t, pub.Send(make([]beat.Event, 3)))
+	time.Sleep(10 * time.Millisecond)
+	assert.NoError(t, pub.Send(make([]beat.Event, 2)))
+	assert.Equal(t, queued+2, queueEvents.Get())
+	assert.Error(t, pub.Send(make([]beat.Event, 4)))
+	assert.Equal(t, full+1, queueFull.Get())
+	assert.Equal(t, rejected+4, queueRejectedEvents.Get())
+	assert.Equal(t, queued+2, queueEvents.Get())
+
+	close(pipeline.release)
+	pub.Stop()
+	assert.Len(t, pipeline.published, 5)
+	assert.Equal(t, batches+2, queuePublishedBatch.Get())
+	assert.Equal(t, events+5, queuePublishedEvents.Get())
+	assert.Equal(t, int64(2), queueLastBatchSize.Get())
+	assert.Equal(t, queued, queueEvents.Get())
+}
+
+func TestUpdateMovingAverage(t *testing.T) {
+	var avg int64
+	assert.Equal(t, 8*time.Millisecond, updateMovingAverage(&avg, 8*time.Millisecond))
+	assert.Equal(t, 9*time.Millisecond, updateMovingAverage(&avg, 16*time.Millisecond))
+}
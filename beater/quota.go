@@ -0,0 +1,247 @@
+package beater
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+// QuotaUsageURL reports how much of their event quota tenants and services
+// have used in the current period.
+const QuotaUsageURL = "/quota"
+
+const (
+	quotaPeriodHourly = "hourly"
+	quotaPeriodDaily  = "daily"
+)
+
+var (
+	quotaExceeded = monitoring.NewInt(serverMetrics, "requests.quota_exceeded")
+
+	errQuotaExceeded  = errors.New("event quota exceeded")
+	errGETRequestOnly = errors.New("only GET requests are supported")
+)
+
+type QuotaConfig struct {
+	Period   string         `config:"period"`
+	Events   int            `config:"events"`
+	Tenants  map[string]int `config:"tenants"`
+	Services map[string]int `config:"services"`
+}
+
+func (c *QuotaConfig) Validate() error {
+	switch c.Period {
+	case "", quotaPeriodHourly, quotaPeriodDaily:
+	default:
+		return fmt.Errorf("invalid quotas.period '%s', must be one of %s, %s",
+			c.Period, quotaPeriodHourly, quotaPeriodDaily)
+	}
+	if c.Events < 0 {
+		return fmt.Errorf("invalid quotas.events %d, must not be negative", c.Events)
+	}
+	for _, limits := range []map[string]int{c.Tenants, c.Services} {
+		for name, limit := range limits {
+			if limit < 0 {
+				return fmt.Errorf("invalid quota %d for '%s', must not be negative", limit, name)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *QuotaConfig) periodName() string {
+	if c.Period == "" {
+		return quotaPeriodDaily
+	}
+	return c.Period
+}
+
+func (c *QuotaConfig) isEnabled() bool {
+	return c.Events > 0 || len(c.Tenants) > 0 || len(c.Services) > 0
+}
+
+// quotaKey identifies what events are counted against, a tenant or a service.
+type quotaKey struct {
+	kind, name string
+}
+
+const (
+	quotaKindTenant  = "tenants"
+	quotaKindService = "services"
+)
+
+// quotaTracker counts the events accepted per tenant, or per service for
+// requests without tenant, within the current hour or day. Periods start at
+// full hours or at midnight UTC, all counts are reset when a new one starts.
+type quotaTracker struct {
+	config QuotaConfig
+	now    func() time.Time
+
+	mu     sync.Mutex
+	start  time.Time
+	counts map[quotaKey]int
+}
+
+// newQuotaTracker returns nil if no quota is configured.
+func newQuotaTracker(config QuotaConfig) *quotaTracker {
+	if !config.isEnabled() {
+		return nil
+	}
+	return &quotaTracker{config: config, now: time.Now, counts: map[quotaKey]int{}}
+}
+
+func (q *quotaTracker) periodLength() time.Duration {
+	if q.config.Period == quotaPeriodHourly {
+		return time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// rotate resets the counts once the current period is over. It must be called
+// with the lock held.
+func (q *quotaTracker) rotate() {
+	start := q.now().UTC().Truncate(q.periodLength())
+	if !start.Equal(q.start) {
+		q.start = start
+		q.counts = map[quotaKey]int{}
+	}
+}
+
+// key returns what an event is counted against: the tenant it was sent for,
+// or the app that sent it.
+func (q *quotaTracker) key(event beat.Event) quotaKey {
+	if id, _ := event.Fields.GetValue("tenant.id"); id != nil {
+		name, _ := id.(string)
+		return quotaKey{quotaKindTenant, name}
+	}
+	app, _ := event.Fields.GetValue("context.app.name")
+	name, _ := app.(string)
+	return quotaKey{quotaKindService, name}
+}
+
+// limit returns the quota for the key, 0 meaning unlimited.
+func (q *quotaTracker) limit(key quotaKey) int {
+	limits := q.config.Services
+	if key.kind == quotaKindTenant {
+		limits = q.config.Tenants
+	}
+	if limit, ok := limits[key.name]; ok {
+		return limit
+	}
+	return q.config.Events
+}
+
+// reserve counts the events against the quotas, unless that would exceed any
+// of them. In that case a retryAfterError telling when the next period starts
+// is returned. The returned function gives the reserved events back.
+func (q *quotaTracker) reserve(events []beat.Event) (func(), error) {
+	requested := map[quotaKey]int{}
+	for _, event := range events {
+		requested[q.key(event)]++
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.rotate()
+	for key, n := range requested {
+		if limit := q.limit(key); limit > 0 && q.counts[key]+n > limit {
+			next := q.start.Add(q.periodLength())
+			return nil, &retryAfterError{errQuotaExceeded, next.Sub(q.now())}
+		}
+	}
+	for key, n := range requested {
+		q.counts[key] += n
+	}
+	start := q.start
+	return func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		if !start.Equal(q.start) {
+			return
+		}
+		for key, n := range requested {
+			q.counts[key] -= n
+		}
+	}, nil
+}
+
+// reporter returns a reporter rejecting events that exceed the quota of the
+// tenant or service they belong to. It is applied to the events left after
+// sampling and deduplication, events that are not published, e.g. because
+// the queue is full, do not count against the quota either.
+func (q *quotaTracker) reporter(report reporter) reporter {
+	if q == nil {
+		return report
+	}
+	return func(events []beat.Event) error {
+		release, err := q.reserve(events)
+		if err != nil {
+			quotaExceeded.Inc()
+			return err
+		}
+		if err := report(events); err != nil {
+			release()
+			return err
+		}
+		return nil
+	}
+}
+
+// usage returns the used and available events for every tenant and service
+// that sent events in the current period. If tenant is given, only its own
+// usage is returned.
+func (q *quotaTracker) usage(tenant string) map[string]interface{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.rotate()
+
+	usage := map[string]map[string]interface{}{quotaKindTenant: {}, quotaKindService: {}}
+	for key, count := range q.counts {
+		if tenant != "" && key != (quotaKey{quotaKindTenant, tenant}) {
+			continue
+		}
+		entry := map[string]interface{}{"used": count}
+		if limit := q.limit(key); limit > 0 {
+			entry["limit"] = limit
+		}
+		usage[key.kind][key.name] = entry
+	}
+	return map[string]interface{}{
+		"period":         q.config.periodName(),
+		"reset":          q.start.Add(q.periodLength()).Format(time.RFC3339),
+		quotaKindTenant:  usage[quotaKindTenant],
+		quotaKindService: usage[quotaKindService],
+	}
+}
+
+// addQuotaRoutes registers the route reporting the quota usage, if quotas
+// are configured.
+func addQuotaRoutes(mux *http.ServeMux, config Config, quotas *quotaTracker) {
+	if quotas == nil {
+		return
+	}
+	logp.Info("Path %s added to request handler", QuotaUsageURL)
+	mux.Handle(QuotaUsageURL, routeMetricsHandler(QuotaUsageURL, quotaUsageHandler(config, quotas)))
+}
+
+func quotaUsageHandler(config Config, quotas *quotaTracker) http.Handler {
+	return logHandler(
+		tenantAuthHandler(config.SecretToken, config.Tenants,
+			gzipResponseHandler(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if r.Method != "GET" {
+						sendStatus(w, r, http.StatusMethodNotAllowed, errGETRequestOnly)
+						return
+					}
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusOK)
+					responseValid.Inc()
+					sendJSON(w, quotas.usage(tenantID(r)))
+				}))))
+}
@@ -0,0 +1,161 @@
+package beater
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/apm-server/tests"
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func quotaEvents(app, tenant string, n int) []beat.Event {
+	events := make([]beat.Event, n)
+	for i := range events {
+		fields := common.MapStr{"context": common.MapStr{"app": common.MapStr{"name": app}}}
+		if tenant != "" {
+			fields.Put("tenant.id", tenant)
+		}
+		events[i] = beat.Event{Fields: fields}
+	}
+	return events
+}
+
+func TestQuotaReporter(t *testing.T) {
+	now := time.Date(2018, 1, 1, 10, 30, 0, 0, time.UTC)
+	quotas := newQuotaTracker(QuotaConfig{
+		Period:   quotaPeriodHourly,
+		Events:   3,
+		Tenants:  map[string]int{"team-a": 5},
+		Services: map[string]int{"unlimited": 0},
+	})
+	quotas.now = func() time.Time { return now }
+
+	var published int
+	var fail error
+	report := quotas.reporter(func(events []beat.Event) error {
+		if fail != nil {
+			return fail
+		}
+		published += len(events)
+		return nil
+	})
+
+	assert.NoError(t, report(quotaEvents("app", "", 3)))
+	err := report(quotaEvents("app", "", 1))
+	if assert.IsType(t, &retryAfterError{}, err) {
+		assert.Equal(t, errQuotaExceeded, err.(*retryAfterError).error)
+		assert.Equal(t, 30*time.Minute, err.(*retryAfterError).after)
+	}
+	assert.NoError(t, report(quotaEvents("other", "", 3)))
+	assert.NoError(t, report(quotaEvents("unlimited", "", 10)))
+
+	// tenant quotas apply regardless of the app
+	assert.NoError(t, report(quotaEvents("app", "team-a", 5)))
+	assert.Error(t, report(quotaEvents("other", "team-a", 1)))
+
+	// events that are not published are not counted
+	fail = errFull
+	assert.Equal(t, errFull, report(quotaEvents("new", "", 3)))
+	fail = nil
+	assert.NoError(t, report(quotaEvents("new", "", 3)))
+
+	// counts are reset in the next period
+	now = now.Add(time.Hour)
+	assert.NoError(t, report(quotaEvents("app", "", 3)))
+
+	assert.Equal(t, 27, published)
+}
+
+func TestQuotaUsage(t *testing.T) {
+	quotas := newQuotaTracker(QuotaConfig{Events: 10, Tenants: map[string]int{"team-a": 0}})
+	quotas.now = func() time.Time { return time.Date(2018, 1, 1, 10, 30, 0, 0, time.UTC) }
+	_, err := quotas.reserve(append(quotaEvents("app", "", 2), quotaEvents("app", "team-a", 4)...))
+	assert.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{
+		"period":   "daily",
+		"reset":    "2018-01-02T00:00:00Z",
+		"tenants":  map[string]interface{}{"team-a": map[string]interface{}{"used": 4}},
+		"services": map[string]interface{}{"app": map[string]interface{}{"used": 2, "limit": 10}},
+	}, quotas.usage(""))
+
+	assert.Equal(t, map[string]interface{}{
+		"period":   "daily",
+		"reset":    "2018-01-02T00:00:00Z",
+		"tenants":  map[string]interface{}{"team-a": map[string]interface{}{"used": 4}},
+		"services": map[string]interface{}{},
+	}, quotas.usage("team-a"))
+}
+
+func TestQuotaExceededResponse(t *testing.T) {
+	payload, err := tests.LoadValidData("transaction")
+	assert.NoError(t, err)
+
+	config := defaultConfig
+	config.Quotas = QuotaConfig{Events: 1}
+	quotas := newQuotaTracker(config.Quotas)
+	mux := newListeners(config, quotas, transformReporter(config, nil, quotas, nopReporter))[0].server.Handler
+
+	req, err := http.NewRequest("POST", BackendTransactionsURL, bytes.NewReader(payload))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	var body map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "ERR_QUOTA_EXCEEDED", body["code"])
+
+	req, err = http.NewRequest("GET", QuotaUsageURL, nil)
+	assert.NoError(t, err)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestQuotaCountedAfterSamplingAndDedup(t *testing.T) {
+	config := defaultConfig
+	config.Sampling = SamplingConfig{Rate: 0}
+	config.Quotas = QuotaConfig{Events: 2}
+	quotas := newQuotaTracker(config.Quotas)
+	dedup := newDeduplicator(DedupConfig{Window: time.Minute, CacheSize: 10})
+	defer dedup.close()
+	var published int
+	report := transformReporter(config, dedup, quotas, func(events []beat.Event) error {
+		published += len(events)
+		return nil
+	})
+
+	// the transaction and trace are sampled out, only the error counts
+	events := samplingEvents("app", "tx", nil)
+	events[2].Fields.Put("processor.event", "error")
+	assert.NoError(t, report(events))
+	assert.Equal(t, 1, published)
+
+	// the duplicate error doesn't count either
+	events = samplingEvents("app", "tx", nil)
+	events[2].Fields.Put("processor.event", "error")
+	assert.NoError(t, report(events))
+	assert.Equal(t, 1, published)
+
+	events = quotaEvents("app", "", 1)
+	events[0].Fields.Put("processor.event", "log")
+	assert.NoError(t, report(events))
+	assert.Error(t, report(events))
+	assert.Equal(t, 2, published)
+}
+
+func TestQuotaConfigValidate(t *testing.T) {
+	assert.NoError(t, (&QuotaConfig{Period: quotaPeriodHourly, Events: 10}).Validate())
+	assert.Error(t, (&QuotaConfig{Period: "weekly"}).Validate())
+	assert.Error(t, (&QuotaConfig{Events: -1}).Validate())
+	assert.Error(t, (&QuotaConfig{Services: map[string]int{"app": -1}}).Validate())
+}
@@ -0,0 +1,186 @@
+package beater
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/hashicorp/golang-lru"
+	"golang.org/x/time/rate"
+
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+const (
+	rateLimitEvictionLRU      = "lru"
+	rateLimitEvictionOverflow = "overflow"
+)
+
+var rateLimitStoreErrors = monitoring.NewInt(serverMetrics, "rate_limit.store_errors")
+
+// RateLimiterConfig configures how the frontend rate limit is kept track of.
+// Limiters are kept per IP address for at most cache_size addresses. With the
+// lru eviction the least recently seen address is forgotten when the cache is
+// full, so that clients cycling through many addresses can reset their limits.
+// With the overflow eviction addresses are never forgotten, all addresses not
+// fitting in the cache share a single limiter instead.
+type RateLimiterConfig struct {
+	CacheSize       int         `config:"cache_size" validate:"min=1"`
+	BurstMultiplier int         `config:"burst_multiplier" validate:"min=1"`
+	Eviction        string      `config:"eviction"`
+	Redis           RedisConfig `config:"redis"`
+}
+
+func (c *RateLimiterConfig) Validate() error {
+	switch c.Eviction {
+	case "", rateLimitEvictionLRU, rateLimitEvictionOverflow:
+		return nil
+	}
+	return fmt.Errorf("invalid rate_limiter.eviction '%s', must be one of %s, %s",
+		c.Eviction, rateLimitEvictionLRU, rateLimitEvictionOverflow)
+}
+
+// rateLimiter decides whether a request from an IP address is allowed, and
+// otherwise how long until the next request would be.
+type rateLimiter interface {
+	allow(ip string) (bool, time.Duration)
+}
+
+func newRateLimiter(rateLimit int, config RateLimiterConfig) rateLimiter {
+	if config.Redis.Host != "" {
+		return newRedisRateLimiter(rateLimit, config)
+	}
+	return newMemoryRateLimiter(rateLimit, config)
+}
+
+func ipRateLimitHandler(rateLimit int, config RateLimiterConfig, h http.Handler) http.Handler {
+	limiter := newRateLimiter(rateLimit, config)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ok, delay := limiter.allow(extractIP(r)); !ok {
+			sendStatus(w, r, http.StatusTooManyRequests, &retryAfterError{errTooManyRequests, delay})
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// memoryRateLimiter keeps a token bucket per IP address, allowing bursts of
+// burst_multiplier times the rate limit.
+type memoryRateLimiter struct {
+	limit    rate.Limit
+	burst    int
+	eviction string
+	size     int
+	cache    *lru.Cache
+
+	mu       sync.Mutex
+	overflow *rate.Limiter
+}
+
+func newMemoryRateLimiter(rateLimit int, config RateLimiterConfig) *memoryRateLimiter {
+	cache, _ := lru.New(config.CacheSize)
+	burst := rateLimit * config.BurstMultiplier
+	return &memoryRateLimiter{
+		limit:    rate.Limit(rateLimit),
+		burst:    burst,
+		eviction: config.Eviction,
+		size:     config.CacheSize,
+		cache:    cache,
+		overflow: rate.NewLimiter(rate.Limit(rateLimit), burst),
+	}
+}
+
+func (l *memoryRateLimiter) allow(ip string) (bool, time.Duration) {
+	reservation := l.limiter(ip).Reserve()
+	if !reservation.OK() {
+		return false, time.Second
+	}
+	if delay := reservation.Delay(); delay > 0 {
+		reservation.Cancel()
+		return false, delay
+	}
+	return true, 0
+}
+
+func (l *memoryRateLimiter) limiter(ip string) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if limiter, ok := l.cache.Get(ip); ok {
+		return limiter.(*rate.Limiter)
+	}
+	if l.eviction == rateLimitEvictionOverflow && l.cache.Len() >= l.size {
+		return l.overflow
+	}
+	limiter := rate.NewLimiter(l.limit, l.burst)
+	l.cache.Add(ip, limiter)
+	return limiter
+}
+
+// redisRateLimiter counts the requests per IP address in Redis, so that all
+// servers using the same Redis share the limits. Requests are counted in
+// fixed windows of burst_multiplier seconds, allowing as many requests per
+// window as the in memory limiter allows in a burst. Requests are allowed
+// while Redis can't be reached, as rejecting all frontend requests would
+// hurt more than not limiting them for a while.
+type redisRateLimiter struct {
+	limit  int
+	window time.Duration
+	prefix string
+	pool   *redis.Pool
+}
+
+func newRedisRateLimiter(rateLimit int, config RateLimiterConfig) *redisRateLimiter {
+	prefix := config.Redis.KeyPrefix
+	if prefix == "" {
+		prefix = "apm-server:rate_limit:"
+	}
+	return &redisRateLimiter{
+		limit:  rateLimit * config.BurstMultiplier,
+		window: time.Duration(config.BurstMultiplier) * time.Second,
+		prefix: prefix,
+		pool:   newRedisPool(config.Redis),
+	}
+}
+
+func (l *redisRateLimiter) allow(ip string) (bool, time.Duration) {
+	ok, delay, err := l.count(l.prefix + ip)
+	if err != nil {
+		rateLimitStoreErrors.Inc()
+		logp.Err("Failed to count request for rate limit: %s", err)
+		return true, 0
+	}
+	return ok, delay
+}
+
+func (l *redisRateLimiter) count(key string) (bool, time.Duration, error) {
+	conn := l.pool.Get()
+	defer conn.Close()
+
+	n, err := redis.Int(conn.Do("INCR", key))
+	if err != nil {
+		return false, 0, err
+	}
+	if n == 1 {
+		if _, err := conn.Do("PEXPIRE", key, int64(l.window/time.Millisecond)); err != nil {
+			return false, 0, err
+		}
+	}
+	if n <= l.limit {
+		return true, 0, nil
+	}
+	ttl, err := redis.Int64(conn.Do("PTTL", key))
+	if err != nil {
+		return false, 0, err
+	}
+	if ttl < 0 {
+		// the key was created without expiry, e.g. when setting it failed
+		if _, err := conn.Do("PEXPIRE", key, int64(l.window/time.Millisecond)); err != nil {
+			return false, 0, err
+		}
+		ttl = int64(l.window / time.Millisecond)
+	}
+	return false, time.Duration(ttl) * time.Millisecond, nil
+}
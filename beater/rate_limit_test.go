@@ -0,0 +1,86 @@
+package beater
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiterConfigValidate(t *testing.T) {
+	for _, eviction := range []string{"", "lru", "overflow"} {
+		c := RateLimiterConfig{CacheSize: 1, BurstMultiplier: 1, Eviction: eviction}
+		assert.NoError(t, c.Validate(), eviction)
+	}
+	c := RateLimiterConfig{CacheSize: 1, BurstMultiplier: 1, Eviction: "random"}
+	assert.Error(t, c.Validate())
+}
+
+func TestMemoryRateLimiterBurst(t *testing.T) {
+	l := newMemoryRateLimiter(2, RateLimiterConfig{CacheSize: 10, BurstMultiplier: 3})
+	for i := 0; i < 6; i++ {
+		ok, _ := l.allow("a")
+		assert.True(t, ok, "request %d", i)
+	}
+	ok, delay := l.allow("a")
+	assert.False(t, ok)
+	assert.True(t, delay > 0 && delay <= 500*time.Millisecond, delay.String())
+
+	ok, _ = l.allow("b")
+	assert.True(t, ok)
+}
+
+func TestMemoryRateLimiterEviction(t *testing.T) {
+	exhaust := func(l *memoryRateLimiter, ip string) {
+		for {
+			if ok, _ := l.allow(ip); !ok {
+				return
+			}
+		}
+	}
+
+	// cycling through addresses resets the limit of the least recent one
+	l := newMemoryRateLimiter(1, RateLimiterConfig{CacheSize: 2, BurstMultiplier: 1, Eviction: "lru"})
+	exhaust(l, "a")
+	l.allow("b")
+	l.allow("c")
+	ok, _ := l.allow("a")
+	assert.True(t, ok)
+
+	// addresses are kept, new ones share a limiter once the cache is full
+	l = newMemoryRateLimiter(1, RateLimiterConfig{CacheSize: 2, BurstMultiplier: 1, Eviction: "overflow"})
+	exhaust(l, "a")
+	l.allow("b")
+	ok, _ = l.allow("c")
+	assert.True(t, ok)
+	ok, _ = l.allow("d")
+	assert.False(t, ok)
+	ok, _ = l.allow("a")
+	assert.False(t, ok)
+}
+
+func TestRedisRateLimiter(t *testing.T) {
+	r := newFakeRedis(t)
+	defer r.listener.Close()
+
+	config := RateLimiterConfig{CacheSize: 1, BurstMultiplier: 2, Redis: RedisConfig{Host: r.listener.Addr().String()}}
+	l := newRateLimiter(1, config)
+	for i := 0; i < 2; i++ {
+		ok, _ := l.allow("10.0.0.1")
+		assert.True(t, ok, "request %d", i)
+	}
+	ok, delay := l.allow("10.0.0.1")
+	assert.False(t, ok)
+	assert.Equal(t, 2*time.Second, delay)
+	assert.Equal(t, "3", r.keys["apm-server:rate_limit:10.0.0.1"])
+	assert.Equal(t, "PX 2000", r.ttls["apm-server:rate_limit:10.0.0.1"])
+
+	// limits are shared by all servers using the same redis
+	ok, _ = newRateLimiter(1, config).allow("10.0.0.1")
+	assert.False(t, ok)
+
+	// requests are allowed while redis is unavailable
+	r.listener.Close()
+	ok, _ = newRateLimiter(1, config).allow("10.0.0.1")
+	assert.True(t, ok)
+}
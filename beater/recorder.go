@@ -0,0 +1,226 @@
+package beater
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/monitoring"
+	"github.com/elastic/beats/libbeat/paths"
+)
+
+var (
+	recordedRequests = monitoring.NewInt(serverMetrics, "requests.recorded")
+	recordSkipped    = monitoring.NewInt(serverMetrics, "requests.record_skipped")
+)
+
+// recordedRequest is the metadata written along with a recorded request
+// body. The body is stored as received, i.e. still compressed. As the secret
+// token is not recorded, the tenant the request was authorized for is.
+type recordedRequest struct {
+	Path      string            `json:"path"`
+	Method    string            `json:"method"`
+	Timestamp time.Time         `json:"timestamp"`
+	Headers   map[string]string `json:"headers"`
+	Tenant    string            `json:"tenant,omitempty"`
+	Body      string            `json:"body"`
+}
+
+// requestRecorder writes the raw bodies of incoming requests to a directory,
+// so that problematic payloads can be replayed against a test server. It stops
+// recording once the files in the directory exceed the configured size.
+// The app fields not to be kept according to the process config are removed
+// from recorded payloads.
+type requestRecorder struct {
+	dir         string
+	routes      map[string]bool
+	maxSize     int64
+	droppedKeys []string
+	tenants     []TenantConfig
+
+	mu   sync.Mutex
+	used int64
+	seq  uint64
+}
+
+func newRequestRecorder(config *RecordConfig, process ProcessConfig, tenants []TenantConfig) (*requestRecorder, error) {
+	if !config.isEnabled() {
+		return nil, nil
+	}
+	dir := paths.Resolve(paths.Data, config.Path)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, err
+	}
+
+	var used int64
+	err := filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			used += info.Size()
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	routes := map[string]bool{}
+	for _, route := range config.Routes {
+		routes[route] = true
+	}
+	return &requestRecorder{
+		dir:         dir,
+		routes:      routes,
+		maxSize:     config.MaxSize,
+		droppedKeys: process.droppedAppKeys(),
+		tenants:     tenants,
+		used:        used,
+	}, nil
+}
+
+// records returns true if requests to the given path are recorded. All
+// routes are recorded if none are configured.
+func (rec *requestRecorder) records(path string) bool {
+	return rec != nil && (len(rec.routes) == 0 || rec.routes[path])
+}
+
+func (rec *requestRecorder) handler(path string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received := time.Now()
+		var body bytes.Buffer
+		if r.Body != nil {
+			r.Body = &teeReadCloser{Reader: io.TeeReader(r.Body, &body), Closer: r.Body}
+		}
+		h.ServeHTTP(w, r)
+
+		header, data, err := rec.redact(r.Header, body.Bytes())
+		if err != nil {
+			recordSkipped.Inc()
+			logp.Debug("recorder", "Not recording request, payload can't be checked for process fields: %s", err)
+			return
+		}
+		tenant, _ := authorizedTenant(r, rec.tenants)
+		if err := rec.write(path, r.Method, header, tenant, received, data); err != nil {
+			logp.Err("Failed to record request: %s", err)
+		}
+	})
+}
+
+// redact removes the app fields not to be kept from the payload. Payloads
+// are only changed if they contain any of them, they are then recorded as
+// uncompressed JSON. Payloads that can't be decoded are not recorded, as they
+// might contain the fields.
+func (rec *requestRecorder) redact(header http.Header, body []byte) (http.Header, []byte, error) {
+	if len(rec.droppedKeys) == 0 || len(body) == 0 {
+		return header, body, nil
+	}
+	reader, err := decodeData(&http.Request{Header: header, Body: ioutil.NopCloser(bytes.NewReader(body))})
+	if err != nil {
+		return nil, nil, err
+	}
+	defer reader.Close()
+	buf, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	if header.Get("Content-Type") == "application/msgpack" {
+		if buf, err = msgpackToJSON(buf); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var payload map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(buf))
+	decoder.UseNumber()
+	if err := decoder.Decode(&payload); err != nil {
+		return nil, nil, err
+	}
+	app, _ := payload["app"].(map[string]interface{})
+	redacted := false
+	for _, key := range rec.droppedKeys {
+		if _, ok := app[key]; ok {
+			delete(app, key)
+			redacted = true
+		}
+	}
+	if !redacted {
+		return header, body, nil
+	}
+	if buf, err = json.Marshal(payload); err != nil {
+		return nil, nil, err
+	}
+
+	redactedHeader := http.Header{}
+	for key, values := range header {
+		redactedHeader[key] = values
+	}
+	redactedHeader.Set("Content-Type", "application/json")
+	redactedHeader.Del("Content-Encoding")
+	redactedHeader.Del("Content-Length")
+	return redactedHeader, buf, nil
+}
+
+func (rec *requestRecorder) write(path, method string, header http.Header, tenant string, received time.Time, body []byte) error {
+	name := fmt.Sprintf("%d-%d", received.UnixNano(), atomic.AddUint64(&rec.seq, 1))
+	meta, err := json.MarshalIndent(recordedRequest{
+		Path:      path,
+		Method:    method,
+		Timestamp: received.UTC(),
+		Headers:   recordedHeaders(header),
+		Tenant:    tenant,
+		Body:      name + ".body",
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if !rec.reserve(int64(len(meta) + len(body))) {
+		recordSkipped.Inc()
+		return nil
+	}
+	if err := ioutil.WriteFile(filepath.Join(rec.dir, name+".body"), body, 0640); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(rec.dir, name+".json"), meta, 0640); err != nil {
+		return err
+	}
+	recordedRequests.Inc()
+	return nil
+}
+
+// reserve accounts for size bytes to be written, returning false if that
+// would exceed the configured maximum size.
+func (rec *requestRecorder) reserve(size int64) bool {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.maxSize > 0 && rec.used+size > rec.maxSize {
+		return false
+	}
+	rec.used += size
+	return true
+}
+
+// recordedHeaders returns the request headers, leaving out the secret token.
+func recordedHeaders(header http.Header) map[string]string {
+	headers := map[string]string{}
+	for key := range header {
+		if key == "Authorization" {
+			continue
+		}
+		headers[key] = header.Get(key)
+	}
+	return headers
+}
+
+type teeReadCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,125 @@
+package beater
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func recordedFiles(t *testing.T, dir, pattern string) []string {
+	files, err := filepath.Glob(filepath.Join(dir, pattern))
+	assert.NoError(t, err)
+	return files
+}
+
+func TestRequestRecorder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recorded")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	enabled := true
+	rec, err := newRequestRecorder(&RecordConfig{Enabled: &enabled, Path: dir}, defaultConfig.Process, nil)
+	assert.NoError(t, err)
+	assert.True(t, rec.records(BackendErrorsURL))
+
+	h := rec.handler(BackendErrorsURL, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	req, err := http.NewRequest("POST", BackendErrorsURL, bytes.NewReader([]byte(`{"errors":[]}`)))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	metaFiles := recordedFiles(t, dir, "*.json")
+	if !assert.Len(t, metaFiles, 1) {
+		return
+	}
+	data, err := ioutil.ReadFile(metaFiles[0])
+	assert.NoError(t, err)
+	var meta recordedRequest
+	assert.NoError(t, json.Unmarshal(data, &meta))
+	assert.Equal(t, BackendErrorsURL, meta.Path)
+	assert.Equal(t, "POST", meta.Method)
+	assert.Equal(t, "application/json", meta.Headers["Content-Type"])
+	assert.NotContains(t, meta.Headers, "Authorization")
+
+	body, err := ioutil.ReadFile(filepath.Join(dir, meta.Body))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"errors":[]}`, string(body))
+}
+
+func TestRequestRecorderProcessFields(t *testing.T) {
+	rec := &requestRecorder{droppedKeys: ProcessConfig{CaptureTitle: true}.droppedAppKeys()}
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(`{"app":{"name":"a","argv":["-p","secret"],"process_title":"node"},"errors":[{"id":123456789012345678}]}`))
+	zw.Close()
+	header := http.Header{"Content-Type": {"application/json"}, "Content-Encoding": {"gzip"}, "User-Agent": {"agent"}}
+	redactedHeader, body, err := rec.redact(header, gz.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, `{"app":{"name":"a","process_title":"node"},"errors":[{"id":123456789012345678}]}`, string(body))
+	assert.Equal(t, http.Header{"Content-Type": {"application/json"}, "User-Agent": {"agent"}}, redactedHeader)
+	assert.Equal(t, "gzip", header.Get("Content-Encoding"))
+
+	// payloads without the fields are recorded as received
+	plain := []byte(`{"app":{"name":"a"},"errors":[]}`)
+	redactedHeader, body, err = rec.redact(http.Header{"Content-Type": {"application/json"}}, plain)
+	assert.NoError(t, err)
+	assert.Equal(t, plain, body)
+
+	// payloads that can't be checked are not recorded
+	_, _, err = rec.redact(http.Header{"Content-Type": {"application/json"}}, []byte(`{"app":{"argv":`))
+	assert.Error(t, err)
+
+	rec = &requestRecorder{droppedKeys: defaultConfig.Process.droppedAppKeys()}
+	_, body, err = rec.redact(http.Header{}, []byte(`{"app":{"argv":`))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"app":{"argv":`, string(body))
+}
+
+func TestRequestRecorderMaxSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recorded")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	enabled := true
+	rec, err := newRequestRecorder(&RecordConfig{Enabled: &enabled, Path: dir, MaxSize: 10}, defaultConfig.Process, nil)
+	assert.NoError(t, err)
+
+	before := recordSkipped.Get()
+	h := rec.handler(BackendErrorsURL, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+	}))
+	req, err := http.NewRequest("POST", BackendErrorsURL, bytes.NewReader([]byte(`{"errors":[]}`)))
+	assert.NoError(t, err)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Empty(t, recordedFiles(t, dir, "*"))
+	assert.Equal(t, before+1, recordSkipped.Get())
+}
+
+func TestRequestRecorderRoutes(t *testing.T) {
+	var rec *requestRecorder
+	assert.False(t, rec.records(BackendErrorsURL))
+
+	disabled, err := newRequestRecorder(defaultConfig.RecordRequests, defaultConfig.Process, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, disabled)
+
+	rec = &requestRecorder{routes: map[string]bool{BackendErrorsURL: true}}
+	assert.True(t, rec.records(BackendErrorsURL))
+	assert.False(t, rec.records(BackendTransactionsURL))
+}
@@ -0,0 +1,36 @@
+package beater
+
+import (
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// RedisConfig configures the connection to Redis, for state shared by all
+// servers using the same Redis.
+type RedisConfig struct {
+	Host      string        `config:"host"`
+	Password  string        `config:"password"`
+	DB        int           `config:"db"`
+	Timeout   time.Duration `config:"timeout"`
+	KeyPrefix string        `config:"key_prefix"`
+}
+
+func newRedisPool(config RedisConfig) *redis.Pool {
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+	return &redis.Pool{
+		MaxIdle:     10,
+		IdleTimeout: time.Minute,
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", config.Host,
+				redis.DialPassword(config.Password),
+				redis.DialDatabase(config.DB),
+				redis.DialConnectTimeout(timeout),
+				redis.DialReadTimeout(timeout),
+				redis.DialWriteTimeout(timeout))
+		},
+	}
+}
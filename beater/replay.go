@@ -0,0 +1,199 @@
+package beater
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+// replayPayload is a single request to be replayed.
+type replayPayload struct {
+	path   string
+	header http.Header
+	tenant string
+	body   []byte
+}
+
+type replayer struct {
+	config   Config
+	payloads []replayPayload
+	interval time.Duration
+	done     chan struct{}
+}
+
+// NewReplayer returns a beat.Creator, which pushes the payloads found at source
+// through the same pipeline as requests received by the server, and stops
+// afterwards. Source is either a directory of recorded requests or a file
+// containing one JSON payload per line, which are sent to the given route.
+// At most rate payloads are replayed per second, 0 means no limit.
+func NewReplayer(source, route string, rate float64) beat.Creator {
+	return func(_ *beat.Beat, ucfg *common.Config) (beat.Beater, error) {
+		config := defaultConfig
+		if err := ucfg.Unpack(&config); err != nil {
+			return nil, fmt.Errorf("Error reading config file: %v", err)
+		}
+		if err := config.setupEnrichers(); err != nil {
+			return nil, err
+		}
+
+		payloads, err := loadReplayPayloads(source, route)
+		if err != nil {
+			return nil, err
+		}
+
+		var interval time.Duration
+		if rate > 0 {
+			interval = time.Duration(float64(time.Second) / rate)
+		}
+		return &replayer{config: config, payloads: payloads, interval: interval, done: make(chan struct{})}, nil
+	}
+}
+
+func (rp *replayer) Run(b *beat.Beat) error {
+	// Events are published synchronously, and Close waits for them to be
+	// acknowledged by the outputs before the replay exits.
+	client, err := b.Publisher.ConnectWith(beat.ClientConfig{
+		PublishMode: beat.GuaranteedSend,
+		WaitClose:   rp.config.ShutdownTimeout,
+	})
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	dedup := newDeduplicator(rp.config.Dedup)
+	defer dedup.close()
+	report := processReporter(rp.config.Process, decorateReporter(b.Info, rp.config, dedup, nil, func(events []beat.Event) error {
+		client.PublishAll(events)
+		return nil
+	}))
+
+	failed := 0
+	for i, payload := range rp.payloads {
+		if i > 0 && rp.interval > 0 {
+			select {
+			case <-rp.done:
+				return nil
+			case <-time.After(rp.interval):
+			}
+		}
+		code, err := rp.replay(payload, report)
+		if err != nil {
+			failed++
+			logp.Warn("Replaying payload %d to %s failed with status %d: %s", i, payload.path, code, err)
+		}
+	}
+	logp.Info("Replayed %d payloads, %d failed", len(rp.payloads), failed)
+	return nil
+}
+
+func (rp *replayer) replay(payload replayPayload, report reporter) (int, error) {
+	mapping, ok := Routes[payload.path]
+	if !ok || payload.path == HealthCheckURL {
+		return http.StatusNotFound, fmt.Errorf("unknown route %s", payload.path)
+	}
+	maxSize := rp.config.MaxUnzippedSize
+	if frontendRoute(payload.path) {
+		maxSize = rp.config.Frontend.MaxUnzippedSize
+	}
+
+	r, err := http.NewRequest("POST", payload.path, bytes.NewReader(payload.body))
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	r.Header = payload.header
+	if payload.tenant != "" {
+		r = r.WithContext(context.WithValue(r.Context(), tenantIDKey, payload.tenant))
+	}
+	return processRequest(r, intakeFactory(rp.config, mapping.ProcessorFactory), rp.config.processorConfig(), maxSize, requestReporter(r, rp.config, report), nil)
+}
+
+func (rp *replayer) Stop() {
+	close(rp.done)
+}
+
+func frontendRoute(path string) bool {
+	return path == FrontendErrorsURL || path == FrontendTransactionsURL
+}
+
+// loadReplayPayloads reads the payloads to replay from a directory written by
+// the request recorder, or from a file with one JSON payload per line.
+func loadReplayPayloads(source, route string) ([]replayPayload, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return loadRecordedPayloads(source)
+	}
+	if route == "" {
+		return nil, fmt.Errorf("a route is required to replay %s", source)
+	}
+	return loadNDJSONPayloads(source, route)
+}
+
+func loadRecordedPayloads(dir string) ([]replayPayload, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	var payloads []replayPayload
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var meta recordedRequest
+		if err := json.Unmarshal(data, &meta); err != nil {
+			return nil, fmt.Errorf("invalid recorded request %s: %s", file, err)
+		}
+		body, err := ioutil.ReadFile(filepath.Join(dir, meta.Body))
+		if err != nil {
+			return nil, err
+		}
+		header := http.Header{}
+		for k, v := range meta.Headers {
+			header.Set(k, v)
+		}
+		payloads = append(payloads, replayPayload{path: meta.Path, header: header, tenant: meta.Tenant, body: body})
+	}
+	return payloads, nil
+}
+
+func loadNDJSONPayloads(file, route string) ([]replayPayload, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var payloads []replayPayload
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, int(defaultConfig.MaxUnzippedSize))
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		header := http.Header{}
+		header.Set("Content-Type", "application/json")
+		body := make([]byte, len(line))
+		copy(body, line)
+		payloads = append(payloads, replayPayload{path: route, header: header, body: body})
+	}
+	return payloads, scanner.Err()
+}
@@ -0,0 +1,90 @@
+package beater
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/apm-server/tests"
+	"github.com/elastic/beats/libbeat/beat"
+)
+
+func TestReplayRecordedPayloads(t *testing.T) {
+	dir, err := ioutil.TempDir("", "replay")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	data, err := tests.LoadValidData("error")
+	assert.NoError(t, err)
+
+	enabled := true
+	tenants := []TenantConfig{{ID: "team-a", SecretToken: "token-a"}}
+	rec, err := newRequestRecorder(&RecordConfig{Enabled: &enabled, Path: dir}, defaultConfig.Process, tenants)
+	assert.NoError(t, err)
+	h := rec.handler(BackendErrorsURL, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+	}))
+	req, err := http.NewRequest("POST", BackendErrorsURL, bytes.NewReader(data))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer token-a")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	payloads, err := loadReplayPayloads(dir, "")
+	assert.NoError(t, err)
+	if !assert.Len(t, payloads, 1) {
+		return
+	}
+	assert.Equal(t, BackendErrorsURL, payloads[0].path)
+	assert.Equal(t, data, payloads[0].body)
+	assert.Equal(t, "", payloads[0].header.Get("Authorization"))
+	assert.Equal(t, "team-a", payloads[0].tenant)
+
+	var published []beat.Event
+	rp := &replayer{config: defaultConfig}
+	code, err := rp.replay(payloads[0], func(events []beat.Event) error {
+		published = append(published, events...)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, code)
+	assert.NotEmpty(t, published)
+	for _, event := range published {
+		tenant, _ := event.Fields.GetValue("tenant.id")
+		assert.Equal(t, "team-a", tenant)
+	}
+}
+
+func TestLoadNDJSONPayloads(t *testing.T) {
+	dir, err := ioutil.TempDir("", "replay")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "payloads.ndjson")
+	assert.NoError(t, ioutil.WriteFile(file, []byte("{\"a\":1}\n\n{\"b\":2}\n"), 0644))
+
+	_, err = loadReplayPayloads(file, "")
+	assert.Error(t, err)
+
+	payloads, err := loadReplayPayloads(file, BackendTransactionsURL)
+	assert.NoError(t, err)
+	if assert.Len(t, payloads, 2) {
+		assert.Equal(t, `{"a":1}`, string(payloads[0].body))
+		assert.Equal(t, `{"b":2}`, string(payloads[1].body))
+		assert.Equal(t, BackendTransactionsURL, payloads[1].path)
+		assert.Equal(t, "application/json", payloads[1].header.Get("Content-Type"))
+	}
+}
+
+func TestReplayUnknownRoute(t *testing.T) {
+	rp := &replayer{config: defaultConfig}
+	code, err := rp.replay(replayPayload{path: "/unknown"}, nil)
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusNotFound, code)
+}
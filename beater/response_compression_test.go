@@ -0,0 +1,57 @@
+package beater
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcceptsGzip(t *testing.T) {
+	for header, accepted := range map[string]bool{
+		"":                    false,
+		"gzip":                true,
+		"deflate, gzip;q=1.0": true,
+		"br;q=1.0, gzip;q=0":  false,
+		"*":                   true,
+		"identity":            false,
+		"gzipx":               false,
+	} {
+		req, _ := http.NewRequest("GET", "_", nil)
+		req.Header.Set("Accept-Encoding", header)
+		assert.Equal(t, accepted, acceptsGzip(req), "Failed for '%s'", header)
+	}
+}
+
+func TestGzipResponseHandler(t *testing.T) {
+	body := strings.Repeat(`{"key": "value"}`, 100)
+	h := gzipResponseHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1600")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(body))
+	}))
+
+	req, _ := http.NewRequest("GET", "_", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, "", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	assert.Equal(t, body, w.Body.String())
+
+	req.Header.Set("Accept-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "", w.Header().Get("Content-Length"))
+	assert.True(t, w.Body.Len() < len(body))
+	r, err := gzip.NewReader(w.Body)
+	assert.NoError(t, err)
+	decompressed, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, body, string(decompressed))
+}
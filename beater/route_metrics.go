@@ -0,0 +1,97 @@
+package beater
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+// routeNames are the stable names the metrics of a route are reported
+// under, as apm-server.server.routes.<name>.*, independent of the path the
+// route is served at. Intake routes are named by intake version, whether
+// they are meant for backend or frontend (RUM) agents and the event type.
+var routeNames = map[string]string{
+	BackendTransactionsURL:  "v1.backend.transactions",
+	FrontendTransactionsURL: "v1.frontend.transactions",
+	BackendErrorsURL:        "v1.backend.errors",
+	FrontendErrorsURL:       "v1.frontend.errors",
+	BackendLogsURL:          "v1.backend.logs",
+	FrontendWebSocketURL:    "v1.frontend.websocket",
+	OTLPLogsURL:             "otlp.v1.logs",
+	HealthCheckURL:          "healthcheck",
+	QuotaUsageURL:           "quota",
+	AgentConfigURL:          "agent_config",
+}
+
+var routeMetrics = newRouteCounters(routeNames)
+
+type routeCounters struct {
+	requests       *monitoring.Int
+	responseValid  *monitoring.Int
+	responseErrors *monitoring.Int
+	disabled       *monitoring.Int
+}
+
+func newRouteCounters(names map[string]string) map[string]*routeCounters {
+	counters := make(map[string]*routeCounters, len(names))
+	for path, name := range names {
+		prefix := "routes." + name + "."
+		counters[path] = &routeCounters{
+			requests:       monitoring.NewInt(serverMetrics, prefix+"requests"),
+			responseValid:  monitoring.NewInt(serverMetrics, prefix+"response.valid"),
+			responseErrors: monitoring.NewInt(serverMetrics, prefix+"response.errors"),
+			disabled:       monitoring.NewInt(serverMetrics, prefix+"disabled"),
+		}
+	}
+	return counters
+}
+
+// routeMetricsHandler counts the requests to the route served at path and
+// their responses, which are considered errors for status codes from 400.
+func routeMetricsHandler(path string, h http.Handler) http.Handler {
+	counters, ok := routeMetrics[path]
+	if !ok {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		counters.requests.Inc()
+		rec := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+		h.ServeHTTP(rec, r)
+		if rec.code >= http.StatusBadRequest {
+			counters.responseErrors.Inc()
+		} else {
+			counters.responseValid.Inc()
+		}
+	})
+}
+
+// routeDisabled counts a request to the route served at path, turned off
+// either by disabled_routes or by disabling the frontend.
+func routeDisabled(path string) {
+	if counters, ok := routeMetrics[path]; ok {
+		counters.disabled.Inc()
+	}
+}
+
+// statusRecorder keeps the status code of a response. Connections can still
+// be hijacked through it, e.g. for WebSockets.
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.code = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("connection can't be hijacked")
+	}
+	return hijacker.Hijack()
+}
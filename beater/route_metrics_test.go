@@ -0,0 +1,68 @@
+package beater
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/apm-server/tests"
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+func TestRouteMetrics(t *testing.T) {
+	mux := newMuxer(defaultConfig, func([]beat.Event) error { return nil })
+	counter := func(name string) int64 {
+		return serverMetrics.Get("routes." + name).(*monitoring.Int).Get()
+	}
+	requests := counter("v1.backend.errors.requests")
+	valid := counter("v1.backend.errors.response.valid")
+	errs := counter("v1.backend.errors.response.errors")
+	frontendRequests := counter("v1.frontend.errors.requests")
+
+	payload, err := tests.LoadValidData("error")
+	assert.NoError(t, err)
+	for _, body := range [][]byte{payload, []byte("{}")} {
+		req := httptest.NewRequest("POST", BackendErrorsURL, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+	}
+
+	assert.Equal(t, requests+2, counter("v1.backend.errors.requests"))
+	assert.Equal(t, valid+1, counter("v1.backend.errors.response.valid"))
+	assert.Equal(t, errs+1, counter("v1.backend.errors.response.errors"))
+	assert.Equal(t, frontendRequests, counter("v1.frontend.errors.requests"))
+}
+
+func TestRouteNames(t *testing.T) {
+	for path := range Routes {
+		assert.Contains(t, routeNames, path)
+	}
+	names := map[string]bool{}
+	for _, name := range routeNames {
+		assert.False(t, names[name], name)
+		names[name] = true
+	}
+}
+
+func TestRouteMetricsDisabled(t *testing.T) {
+	counter := func(name string) int64 {
+		return serverMetrics.Get("routes." + name).(*monitoring.Int).Get()
+	}
+	frontend := counter("v1.frontend.errors.disabled")
+	backend := counter("v1.backend.logs.disabled")
+
+	config := defaultConfig
+	config.DisabledRoutes = []string{BackendLogsURL}
+	mux := newMuxer(config, func([]beat.Event) error { return nil })
+	for _, path := range []string{FrontendErrorsURL, BackendLogsURL, BackendErrorsURL} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("POST", path, nil))
+	}
+
+	assert.Equal(t, frontend+1, counter("v1.frontend.errors.disabled"))
+	assert.Equal(t, backend+1, counter("v1.backend.logs.disabled"))
+}
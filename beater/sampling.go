@@ -0,0 +1,110 @@
+package beater
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"math"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+var sampledOut = monitoring.NewInt(serverMetrics, "events.sampled_out")
+
+// samplingReporter returns a reporter sampling transactions with the rate
+// configured for the app they belong to. Traces of transactions that are
+// sampled out are dropped. The transactions themselves are kept with
+// transaction.sampled set to false, so durations and results are still
+// complete, unless keep_unsampled is disabled. Transactions not sampled by
+// the agent are treated the same way.
+//
+// The decision is derived from the transaction id, so a transaction and its
+// traces are always kept or dropped together. Traces are sent along with
+// their transaction, so the traces of transactions not sampled by the agent
+// are found in the same batch.
+func samplingReporter(config SamplingConfig, report reporter) reporter {
+	if config.KeepUnsampled && config.Rate >= 1 && len(config.Services) == 0 {
+		return report
+	}
+	return func(events []beat.Event) error {
+		unsampled := agentUnsampled(events)
+		kept := events[:0]
+		for _, event := range events {
+			if keepSampledEvent(config, event.Fields, unsampled) {
+				kept = append(kept, event)
+			} else {
+				sampledOut.Inc()
+			}
+		}
+		if len(kept) == 0 {
+			return nil
+		}
+		return report(kept)
+	}
+}
+
+// agentUnsampled returns the ids of the transactions the agent didn't sample.
+func agentUnsampled(events []beat.Event) map[string]bool {
+	unsampled := map[string]bool{}
+	for _, event := range events {
+		if s, err := event.Fields.GetValue("transaction.sampled"); err == nil && s == false {
+			id, _ := event.Fields.GetValue("transaction.id")
+			if idStr, ok := id.(string); ok {
+				unsampled[idStr] = true
+			}
+		}
+	}
+	return unsampled
+}
+
+func keepSampledEvent(config SamplingConfig, fields common.MapStr, unsampled map[string]bool) bool {
+	var key string
+	switch {
+	case hasKey(fields, "transaction.id"):
+		key = "transaction.id"
+	case hasKey(fields, "trace.transaction_id"):
+		key = "trace.transaction_id"
+	default:
+		return true
+	}
+
+	id, _ := fields.GetValue(key)
+	idStr, _ := id.(string)
+	sampled := !unsampled[idStr]
+	if s, err := fields.GetValue("transaction.sampled"); err == nil {
+		sampled, _ = s.(bool)
+	}
+	if sampled {
+		sampled = sampleTransaction(idStr, config.rate(appName(fields)))
+	}
+
+	switch {
+	case sampled:
+		return true
+	case key == "transaction.id" && config.KeepUnsampled:
+		fields.Put("transaction.sampled", false)
+		return true
+	}
+	return false
+}
+
+// sampleTransaction maps the id to a value in [0, 1) and compares it to rate.
+func sampleTransaction(id string, rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+	sum := sha1.Sum([]byte(id))
+	return float64(binary.BigEndian.Uint32(sum[:]))/(math.MaxUint32+1) < rate
+}
+
+func appName(fields common.MapStr) string {
+	name, _ := fields.GetValue("context.app.name")
+	s, _ := name.(string)
+	return s
+}
+
+func hasKey(fields common.MapStr, key string) bool {
+	ok, _ := fields.HasKey(key)
+	return ok
+}
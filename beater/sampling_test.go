@@ -0,0 +1,114 @@
+package beater
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func samplingEvents(app, txId string, sampled *bool) []beat.Event {
+	tx := common.MapStr{"id": txId}
+	if sampled != nil {
+		tx["sampled"] = *sampled
+	}
+	ctx := common.MapStr{"app": common.MapStr{"name": app}}
+	return []beat.Event{
+		{Fields: common.MapStr{"context": ctx, "transaction": tx}},
+		{Fields: common.MapStr{"context": ctx, "trace": common.MapStr{"transaction_id": txId}}},
+		{Fields: common.MapStr{"context": ctx, "error": common.MapStr{"id": "1"}}},
+	}
+}
+
+func TestSamplingReporter(t *testing.T) {
+	var reported []beat.Event
+	report := func(events []beat.Event) error {
+		reported = events
+		return nil
+	}
+	unsampled := false
+
+	for idx, test := range []struct {
+		config        SamplingConfig
+		events        []beat.Event
+		expectedKinds []string
+		sampled       *bool
+	}{
+		{
+			config:        SamplingConfig{KeepUnsampled: true, Rate: 1},
+			events:        samplingEvents("app", "tx", nil),
+			expectedKinds: []string{"transaction", "trace", "error"},
+		},
+		{
+			config:        SamplingConfig{KeepUnsampled: true, Rate: 0},
+			events:        samplingEvents("app", "tx", nil),
+			expectedKinds: []string{"transaction", "error"},
+			sampled:       &unsampled,
+		},
+		{
+			config:        SamplingConfig{KeepUnsampled: false, Rate: 0},
+			events:        samplingEvents("app", "tx", nil),
+			expectedKinds: []string{"error"},
+		},
+		{
+			config:        SamplingConfig{KeepUnsampled: false, Rate: 1},
+			events:        samplingEvents("app", "tx", &unsampled),
+			expectedKinds: []string{"error"},
+		},
+		{
+			config:        SamplingConfig{KeepUnsampled: true, Rate: 1, Services: map[string]float64{"other": 0}},
+			events:        samplingEvents("app", "tx", &unsampled),
+			expectedKinds: []string{"transaction", "error"},
+			sampled:       &unsampled,
+		},
+		{
+			config:        SamplingConfig{KeepUnsampled: false, Rate: 1, Services: map[string]float64{"app": 0}},
+			events:        samplingEvents("app", "tx", nil),
+			expectedKinds: []string{"error"},
+		},
+		{
+			config:        SamplingConfig{KeepUnsampled: false, Rate: 0, Services: map[string]float64{"app": 1}},
+			events:        samplingEvents("app", "tx", nil),
+			expectedKinds: []string{"transaction", "trace", "error"},
+		},
+	} {
+		reported = nil
+		assert.NoError(t, samplingReporter(test.config, report)(test.events))
+		var kinds []string
+		for _, event := range reported {
+			for _, kind := range []string{"transaction", "trace", "error"} {
+				if _, ok := event.Fields[kind]; ok {
+					kinds = append(kinds, kind)
+				}
+			}
+		}
+		msg := fmt.Sprintf("Failed at idx %v", idx)
+		assert.Equal(t, test.expectedKinds, kinds, msg)
+		if test.sampled != nil {
+			sampled, err := reported[0].Fields.GetValue("transaction.sampled")
+			assert.NoError(t, err, msg)
+			assert.Equal(t, *test.sampled, sampled, msg)
+		}
+	}
+}
+
+func TestSampleTransactionRate(t *testing.T) {
+	sampled := 0
+	for i := 0; i < 10000; i++ {
+		id := fmt.Sprintf("945254c5-67a5-417e-8a4e-%012d", i)
+		if sampleTransaction(id, 0.25) {
+			sampled++
+		}
+		assert.Equal(t, sampleTransaction(id, 0.25), sampleTransaction(id, 0.25))
+	}
+	assert.InDelta(t, 2500, sampled, 250)
+}
+
+func TestSamplingConfigValidate(t *testing.T) {
+	assert.NoError(t, (&SamplingConfig{Rate: 0.5, Services: map[string]float64{"app": 1}}).Validate())
+	assert.Error(t, (&SamplingConfig{Rate: 1.5}).Validate())
+	assert.Error(t, (&SamplingConfig{Rate: 1, Services: map[string]float64{"app": -1}}).Validate())
+}
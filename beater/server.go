@@ -2,6 +2,11 @@ package beater
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
 	"net/http"
 	"time"
 
@@ -11,26 +16,103 @@ import (
 
 type reporter func([]beat.Event) error
 
+// frontendRoutes are the routes served to RUM agents, on the separate
+// listener if rum.host is configured.
+var frontendRoutes = map[string]bool{
+	FrontendTransactionsURL: true,
+	FrontendErrorsURL:       true,
+	FrontendWebSocketURL:    true,
+}
+
+// listener is a server along with the config it is run with, and the muxer
+// serving its routes.
+type listener struct {
+	server *http.Server
+	config Config
+	mux    *muxer
+}
+
 func newServer(config Config, report reporter) *http.Server {
+	return newHTTPServer(config, newMuxer(config, report))
+}
+
+// newListeners returns the listener for the intake routes, or, if rum.host
+// is configured, one for the backend routes and one for the frontend routes.
+// If enabled, the operational routes are served on a separate management
+// listener. All listeners share the handlers, so limits apply to the
+// requests of all of them. The usage of the quotas counted by report is
+// served, if they are set.
+func newListeners(config Config, quotas *quotaTracker, report reporter) []listener {
 	mux := newMuxer(config, report)
+	addQuotaRoutes(mux.ServeMux, config, quotas)
+	management := config.Management.isEnabled()
+	intake := func(path string) bool {
+		return !management || !managementRoute(path)
+	}
 
+	var listeners []listener
+	if config.RUM.Host == "" {
+		listeners = append(listeners, listener{newHTTPServer(config, routeFilterHandler(mux, intake)), config, mux})
+	} else {
+		if !config.Frontend.isEnabled() {
+			logp.Warn("rum.host is set, but the frontend is not enabled.")
+		}
+		rum := config
+		rum.Host = config.RUM.Host
+		if config.RUM.SSL != nil {
+			rum.SSL = config.RUM.SSL
+		}
+		listeners = append(listeners,
+			listener{newHTTPServer(config, routeFilterHandler(mux, func(path string) bool {
+				return intake(path) && !frontendRoutes[path]
+			})), config, mux},
+			listener{newHTTPServer(rum, routeFilterHandler(mux, func(path string) bool {
+				return intake(path) && (frontendRoutes[path] || path == HealthCheckURL)
+			})), rum, mux})
+	}
+	if management {
+		admin := config
+		admin.Host = config.Management.Host
+		admin.SSL = config.Management.SSL
+		listeners = append(listeners, listener{newHTTPServer(admin, routeFilterHandler(mux, managementRoute)), admin, mux})
+	}
+	return listeners
+}
+
+func newHTTPServer(config Config, handler http.Handler) *http.Server {
 	return &http.Server{
 		Addr:           config.Host,
-		Handler:        mux,
+		Handler:        handler,
 		ReadTimeout:    config.ReadTimeout,
 		WriteTimeout:   config.WriteTimeout,
 		MaxHeaderBytes: config.MaxHeaderBytes,
 	}
 }
 
+// routeFilterHandler responds to requests to paths not served by a listener
+// as to unknown paths.
+func routeFilterHandler(h http.Handler, serves func(path string) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !serves(r.URL.Path) {
+			http.NotFound(w, r)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
 func run(server *http.Server, config Config) error {
-	logp.Info("Starting apm-server! Hit CTRL-C to stop it.")
 	logp.Info("Listening on: %s", server.Addr)
 	ssl := config.SSL
 	if ssl.isEnabled() {
-		return server.ListenAndServeTLS(ssl.Cert, ssl.PrivateKey)
+		cert, err := loadKeyPair(ssl)
+		if err != nil {
+			return err
+		}
+		server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+		return server.ListenAndServeTLS("", "")
 	}
-	if config.SecretToken != "" {
+	if config.SecretToken != "" || len(config.Tenants) > 0 {
 		logp.Warn("Secret token is set, but SSL is not enabled.")
 	}
 	return server.ListenAndServe()
@@ -49,3 +131,27 @@ func stop(server *http.Server, timeout time.Duration) {
 		}
 	}
 }
+
+// loadKeyPair loads the certificate and key, decrypting the key with the
+// passphrase if it is encrypted.
+func loadKeyPair(ssl *SSLConfig) (tls.Certificate, error) {
+	certPEM, err := ioutil.ReadFile(ssl.Cert)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	keyPEM, err := ioutil.ReadFile(ssl.PrivateKey)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	if block, _ := pem.Decode(keyPEM); block != nil && x509.IsEncryptedPEMBlock(block) {
+		if ssl.KeyPassphrase == "" {
+			return tls.Certificate{}, fmt.Errorf("key %s is encrypted, but no key_passphrase is set", ssl.PrivateKey)
+		}
+		der, err := x509.DecryptPEMBlock(block, []byte(ssl.KeyPassphrase))
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("decrypting key %s: %v", ssl.PrivateKey, err)
+		}
+		keyPEM = pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: der})
+	}
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
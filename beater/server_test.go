@@ -2,7 +2,11 @@ package beater
 
 import (
 	"bytes"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/http/httptest"
@@ -81,7 +85,7 @@ func TestServerFrontendSwitch(t *testing.T) {
 	true := true
 	apm.Handler = newMuxer(
 		Config{
-			Frontend: &FrontendConfig{Enabled: &true, AllowOrigins: []string{"*"}}},
+			Frontend: &FrontendConfig{Enabled: &true, RateLimiter: defaultConfig.Frontend.RateLimiter, AllowOrigins: []string{"*"}}},
 		nil)
 	rec = httptest.NewRecorder()
 	apm.Handler.ServeHTTP(rec, req)
@@ -100,6 +104,7 @@ func TestServerCORS(t *testing.T) {
 			Frontend: &FrontendConfig{
 				Enabled:      &true,
 				RateLimit:    10,
+				RateLimiter:  defaultConfig.Frontend.RateLimiter,
 				AllowOrigins: []string{"http://notmydomain.com", "http://neitherthisone.com"}}},
 		nil)
 
@@ -109,13 +114,83 @@ func TestServerCORS(t *testing.T) {
 	assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
 }
 
+func TestServerFrontendSwitchNotFound(t *testing.T) {
+	cfg := defaultConfig
+	cfg.Frontend = &FrontendConfig{Enabled: new(bool), DisabledStatus: http.StatusNotFound}
+	mux := newMuxer(cfg, nopReporter)
+
+	req, _ := http.NewRequest("POST", FrontendTransactionsURL, bytes.NewReader(testData))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	disabled := rec.Body.String()
+	assert.Equal(t, http.StatusNotFound, rec.Code, disabled)
+
+	// the response is the same as for unknown paths
+	req, _ = http.NewRequest("POST", "/v1/client-side/unknown", bytes.NewReader(testData))
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, rec.Body.String(), disabled)
+}
+
+func TestServerRUMListener(t *testing.T) {
+	true := true
+	cfg := defaultConfig
+	cfg.Host = "localhost:8200"
+	cfg.SecretToken = "secret"
+	cfg.Frontend = &FrontendConfig{Enabled: &true, RateLimit: 10, RateLimiter: defaultConfig.Frontend.RateLimiter, AllowOrigins: []string{"*"}}
+	assert.Len(t, newListeners(cfg, newQuotaTracker(cfg.Quotas), nopReporter), 1)
+
+	cfg.RUM = RUMConfig{Host: "localhost:8201"}
+	listeners := newListeners(cfg, newQuotaTracker(cfg.Quotas), nopReporter)
+	assert.Len(t, listeners, 2)
+	backend, rum := listeners[0], listeners[1]
+	assert.Equal(t, "localhost:8200", backend.server.Addr)
+	assert.Equal(t, "localhost:8201", rum.server.Addr)
+
+	status := func(l listener, path string) int {
+		req, _ := http.NewRequest("POST", path, bytes.NewReader(testData))
+		req.Header.Add("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		l.server.Handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusNotFound, status(backend, FrontendTransactionsURL))
+	assert.Equal(t, http.StatusUnauthorized, status(backend, BackendTransactionsURL))
+	// frontend requests are not authorized with the secret token
+	code := status(rum, FrontendTransactionsURL)
+	assert.NotEqual(t, http.StatusNotFound, code)
+	assert.NotEqual(t, http.StatusUnauthorized, code)
+	assert.Equal(t, http.StatusNotFound, status(rum, BackendTransactionsURL))
+	assert.Equal(t, http.StatusOK, status(rum, HealthCheckURL))
+}
+
+func TestServerSizeLimitPerRoute(t *testing.T) {
+	true := true
+	cfg := defaultConfig
+	cfg.Frontend = &FrontendConfig{Enabled: &true, RateLimit: 10, RateLimiter: defaultConfig.Frontend.RateLimiter, AllowOrigins: []string{"*"}, MaxUnzippedSize: 10}
+	mux := newMuxer(cfg, nopReporter)
+
+	req, _ := http.NewRequest("POST", FrontendTransactionsURL, bytes.NewReader(testData))
+	req.Header.Add("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+
+	req = makeTestRequest(t)
+	req.Header.Add("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+}
+
 func TestServerNoContentType(t *testing.T) {
 	apm, teardown := setupServer(t, noSSL)
 	defer teardown()
 
 	rr := httptest.NewRecorder()
 	apm.Handler.ServeHTTP(rr, makeTestRequest(t))
-	assert.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+	assert.Equal(t, http.StatusUnsupportedMediaType, rr.Code, rr.Body.String())
 }
 
 func TestServerSecureUnknownCA(t *testing.T) {
@@ -170,6 +245,29 @@ func TestServerBadProtocol(t *testing.T) {
 	assert.Contains(t, err.Error(), "malformed HTTP response")
 }
 
+func TestLoadKeyPairPassphrase(t *testing.T) {
+	ssl := withSSL(t, "localhost")
+	_, err := loadKeyPair(ssl)
+	assert.NoError(t, err)
+
+	keyPEM, err := ioutil.ReadFile(ssl.PrivateKey)
+	assert.NoError(t, err)
+	block, _ := pem.Decode(keyPEM)
+	encrypted, err := x509.EncryptPEMBlock(rand.Reader, block.Type, block.Bytes, []byte("secret"), x509.PEMCipherAES256)
+	assert.NoError(t, err)
+	ssl.PrivateKey = path.Join(tmpCertPath, t.Name()+".encrypted.key")
+	assert.NoError(t, ioutil.WriteFile(ssl.PrivateKey, pem.EncodeToMemory(encrypted), 0600))
+
+	_, err = loadKeyPair(ssl)
+	assert.Error(t, err)
+	ssl.KeyPassphrase = "wrong"
+	_, err = loadKeyPair(ssl)
+	assert.Error(t, err)
+	ssl.KeyPassphrase = "secret"
+	_, err = loadKeyPair(ssl)
+	assert.NoError(t, err)
+}
+
 func setupServer(t *testing.T, ssl *SSLConfig) (*http.Server, func()) {
 	if testing.Short() {
 		t.Skip("skipping server test")
@@ -0,0 +1,117 @@
+package beater
+
+import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+
+	"github.com/hashicorp/golang-lru"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+const serviceStatsCacheSize = 100
+
+// intakeStats counts the events received per service. It is reported as
+// apm-server.server.services.<service>, and thereby exposed via the stats
+// endpoint and expvar, allowing to find the services flooding the server.
+var intakeStats = newServiceStats(serviceStatsCacheSize)
+
+func init() {
+	monitoring.NewFunc(serverMetrics, "services", intakeStats.visit)
+}
+
+// serviceStats holds the counters of the most recently active services. Only
+// the given number of services is tracked, the least recently active ones are
+// evicted, so that arbitrary service names can not exhaust memory.
+type serviceStats struct {
+	mu    sync.Mutex
+	cache *lru.Cache
+}
+
+type serviceCounters struct {
+	accepted int64
+	dropped  int64
+	invalid  int64
+}
+
+func newServiceStats(size int) *serviceStats {
+	cache, _ := lru.New(size)
+	return &serviceStats{cache: cache}
+}
+
+func (s *serviceStats) counters(service string) *serviceCounters {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.cache.Get(service); ok {
+		return c.(*serviceCounters)
+	}
+	c := &serviceCounters{}
+	s.cache.Add(service, c)
+	return c
+}
+
+// accepted counts events which were successfully queued for publishing.
+func (s *serviceStats) accepted(events []beat.Event) {
+	for service, n := range countPerService(events) {
+		atomic.AddInt64(&s.counters(service).accepted, n)
+	}
+}
+
+// dropped counts events which were rejected after they were transformed,
+// e.g. because the queue is full.
+func (s *serviceStats) dropped(events []beat.Event) {
+	for service, n := range countPerService(events) {
+		atomic.AddInt64(&s.counters(service).dropped, n)
+	}
+}
+
+// invalid counts payloads which failed validation or transformation. The
+// service name is read from the app of the raw payload, if possible.
+func (s *serviceStats) invalid(buf []byte) {
+	var payload struct {
+		App struct {
+			Name string `json:"name"`
+		} `json:"app"`
+	}
+	json.Unmarshal(buf, &payload)
+	atomic.AddInt64(&s.counters(serviceName(payload.App.Name)).invalid, 1)
+}
+
+func (s *serviceStats) visit(m monitoring.Mode, vs monitoring.Visitor) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	vs.OnRegistryStart()
+	defer vs.OnRegistryFinished()
+	for _, key := range s.cache.Keys() {
+		v, ok := s.cache.Peek(key)
+		if !ok {
+			continue
+		}
+		c := v.(*serviceCounters)
+		monitoring.ReportNamespace(vs, key.(string), func() {
+			monitoring.ReportInt(vs, "accepted", atomic.LoadInt64(&c.accepted))
+			monitoring.ReportInt(vs, "dropped", atomic.LoadInt64(&c.dropped))
+			monitoring.ReportInt(vs, "invalid", atomic.LoadInt64(&c.invalid))
+		})
+	}
+}
+
+func countPerService(events []beat.Event) map[string]int64 {
+	counts := map[string]int64{}
+	for _, event := range events {
+		name, _ := event.Fields.GetValue("context.app.name")
+		s, _ := name.(string)
+		counts[serviceName(s)]++
+	}
+	return counts
+}
+
+func serviceName(name string) string {
+	if name == "" {
+		return "unknown"
+	}
+	return metricsKey(name)
+}
@@ -0,0 +1,63 @@
+package beater
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+func serviceEvent(name string) beat.Event {
+	return beat.Event{Fields: common.MapStr{"context": common.MapStr{"app": common.MapStr{"name": name}}}}
+}
+
+func TestServiceStats(t *testing.T) {
+	stats := newServiceStats(10)
+	stats.accepted([]beat.Event{serviceEvent("a"), serviceEvent("a"), serviceEvent("b")})
+	stats.dropped([]beat.Event{serviceEvent("b")})
+	stats.invalid([]byte(`{"app": {"name": "a"}}`))
+	stats.invalid([]byte(`not json`))
+
+	r := monitoring.NewRegistry()
+	monitoring.NewFunc(r, "services", stats.visit)
+	snapshot := monitoring.CollectFlatSnapshot(r, monitoring.Full, false)
+
+	assert.Equal(t, map[string]int64{
+		"services.a.accepted":       2,
+		"services.a.dropped":        0,
+		"services.a.invalid":        1,
+		"services.b.accepted":       1,
+		"services.b.dropped":        1,
+		"services.b.invalid":        0,
+		"services.unknown.accepted": 0,
+		"services.unknown.dropped":  0,
+		"services.unknown.invalid":  1,
+	}, snapshot.Ints)
+}
+
+func TestServiceStatsKeys(t *testing.T) {
+	stats := newServiceStats(10)
+	stats.accepted([]beat.Event{serviceEvent("shop.api/v2")})
+
+	r := monitoring.NewRegistry()
+	monitoring.NewFunc(r, "services", stats.visit)
+	snapshot := monitoring.CollectFlatSnapshot(r, monitoring.Full, false)
+
+	assert.Equal(t, int64(1), snapshot.Ints["services.shop_api_v2.accepted"])
+}
+
+func TestServiceStatsEvictsLeastRecentlyActive(t *testing.T) {
+	stats := newServiceStats(2)
+	stats.accepted([]beat.Event{serviceEvent("a")})
+	stats.accepted([]beat.Event{serviceEvent("b")})
+	stats.accepted([]beat.Event{serviceEvent("a")})
+	stats.accepted([]beat.Event{serviceEvent("c")})
+
+	keys := stats.cache.Keys()
+	assert.Len(t, keys, 2)
+	assert.Contains(t, keys, "a")
+	assert.Contains(t, keys, "c")
+}
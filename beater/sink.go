@@ -0,0 +1,159 @@
+package beater
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+const (
+	sinkPipeline = "pipeline"
+	sinkStdout   = "stdout"
+	sinkFile     = "file"
+	sinkNull     = "null"
+)
+
+// Publisher forwards the events reported by the server to an output.
+type Publisher interface {
+	// Send forwards a batch of events, returning an error if they can't be
+	// accepted.
+	Send([]beat.Event) error
+	Stop()
+}
+
+// SinkConfig selects where events are published to. Besides the libbeat
+// pipeline, sending events to the configured output, events can be written to
+// stdout or a rotating file as newline delimited JSON, e.g. when developing
+// agents or to feed custom systems, or discarded, allowing to test the
+// throughput of the server without depending on Elasticsearch.
+type SinkConfig struct {
+	Type          string `config:"type"`
+	Path          string `config:"path"`
+	Filename      string `config:"filename"`
+	RotateEveryKb int    `config:"rotate_every_kb"`
+	NumberOfFiles int    `config:"number_of_files"`
+}
+
+func (c *SinkConfig) Validate() error {
+	switch c.Type {
+	case "", sinkPipeline, sinkStdout, sinkNull:
+		return nil
+	case sinkFile:
+		if c.Path == "" {
+			return errors.New("sink.path is required for the file sink")
+		}
+		if c.RotateEveryKb < 1 {
+			return errors.New("sink.rotate_every_kb must be at least 1")
+		}
+		if c.NumberOfFiles < 2 || c.NumberOfFiles >= logp.RotatorMaxFiles {
+			return fmt.Errorf("sink.number_of_files must be between 2 and %d", logp.RotatorMaxFiles-1)
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid sink.type '%s', must be one of %s, %s, %s, %s",
+		c.Type, sinkPipeline, sinkStdout, sinkFile, sinkNull)
+}
+
+// newSink creates the publisher for the configured sink type.
+func newSink(config Config, pipeline beat.Pipeline) (Publisher, error) {
+	switch config.Sink.Type {
+	case sinkStdout:
+		return newNDJSONPublisher(newlineWriter{os.Stdout}), nil
+	case sinkFile:
+		rotator, err := newSinkRotator(config.Sink)
+		if err != nil {
+			return nil, err
+		}
+		return newNDJSONPublisher(rotator), nil
+	case sinkNull:
+		return nullPublisher{}, nil
+	default:
+		return newPublisher(pipeline, config.ConcurrentRequests, config.PublishTimeout)
+	}
+}
+
+// ndjsonPublisher writes every event as a JSON document on its own line, in
+// the format it would be indexed in. Events are written synchronously, so
+// requests waiting for their events to be published or acknowledged are
+// answered as soon as Send returns.
+type ndjsonPublisher struct {
+	mu  sync.Mutex
+	out lineWriter
+}
+
+// lineWriter is implemented by the libbeat file rotator.
+type lineWriter interface {
+	WriteLine([]byte) error
+}
+
+func newNDJSONPublisher(out lineWriter) *ndjsonPublisher {
+	return &ndjsonPublisher{out: out}
+}
+
+func (p *ndjsonPublisher) Send(events []beat.Event) error {
+	lines := make([][]byte, len(events))
+	for i, event := range events {
+		doc := common.MapStr{"@timestamp": common.Time(event.Timestamp)}
+		doc.Update(event.Fields)
+		line, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		lines[i] = line
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, line := range lines {
+		if err := p.out.WriteLine(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *ndjsonPublisher) Stop() {}
+
+// newlineWriter terminates every line written to w with a newline.
+type newlineWriter struct {
+	w io.Writer
+}
+
+func (nw newlineWriter) WriteLine(line []byte) error {
+	_, err := nw.w.Write(append(line, '\n'))
+	return err
+}
+
+// newSinkRotator sets up the files written by the file sink, the current one
+// is rotated once it exceeds the configured size.
+func newSinkRotator(config SinkConfig) (*logp.FileRotator, error) {
+	rotateEveryBytes := uint64(config.RotateEveryKb) * 1024
+	permissions := uint32(0600)
+	rotator := &logp.FileRotator{
+		Path:             config.Path,
+		Name:             config.Filename,
+		RotateEveryBytes: &rotateEveryBytes,
+		KeepFiles:        &config.NumberOfFiles,
+		Permissions:      &permissions,
+	}
+	if err := rotator.CreateDirectory(); err != nil {
+		return nil, err
+	}
+	if err := rotator.CheckIfConfigSane(); err != nil {
+		return nil, err
+	}
+	logp.Info("Writing events to %s", rotator.FilePath(0))
+	return rotator, nil
+}
+
+// nullPublisher discards all events.
+type nullPublisher struct{}
+
+func (nullPublisher) Send([]beat.Event) error { return nil }
+func (nullPublisher) Stop()                   {}
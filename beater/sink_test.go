@@ -0,0 +1,87 @@
+package beater
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestSinkConfigValidate(t *testing.T) {
+	for _, typ := range []string{"", "pipeline", "stdout", "null"} {
+		assert.NoError(t, (&SinkConfig{Type: typ}).Validate(), typ)
+	}
+	assert.Error(t, (&SinkConfig{Type: "kafka"}).Validate())
+
+	file := defaultConfig.Sink
+	file.Type = "file"
+	assert.Error(t, file.Validate())
+	file.Path = "data"
+	assert.NoError(t, file.Validate())
+	file.NumberOfFiles = 1
+	assert.Error(t, file.Validate())
+}
+
+func TestNDJSONPublisher(t *testing.T) {
+	var buf bytes.Buffer
+	pub := newNDJSONPublisher(newlineWriter{&buf})
+	ts := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	err := pub.Send([]beat.Event{
+		{Timestamp: ts, Fields: common.MapStr{"processor": common.MapStr{"event": "error"}}},
+		{Timestamp: ts, Fields: common.MapStr{"processor": common.MapStr{"event": "transaction"}}},
+	})
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	assert.Len(t, lines, 2)
+	var doc map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &doc))
+	assert.Equal(t, "2018-01-02T03:04:05.000Z", doc["@timestamp"])
+	assert.Equal(t, map[string]interface{}{"event": "transaction"}, doc["processor"])
+}
+
+func TestFileSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apm-server-sink")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	config := defaultConfig
+	config.Sink.Type = "file"
+	config.Sink.Path = filepath.Join(dir, "events")
+	config.Sink.RotateEveryKb = 1
+	config.Sink.NumberOfFiles = 2
+	pub, err := newSink(config, nil)
+	assert.NoError(t, err)
+
+	message := strings.Repeat("x", 600)
+	for i := 0; i < 3; i++ {
+		event := beat.Event{Fields: common.MapStr{"message": message}}
+		assert.NoError(t, pub.Send([]beat.Event{event}))
+	}
+
+	// the second event exceeds the size limit, the third one starts a new file
+	files, err := ioutil.ReadDir(config.Sink.Path)
+	assert.NoError(t, err)
+	assert.Len(t, files, 2)
+	current, err := ioutil.ReadFile(filepath.Join(config.Sink.Path, "apm-server.ndjson"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, bytes.Count(current, []byte("\n")))
+}
+
+func TestNullSink(t *testing.T) {
+	config := defaultConfig
+	config.Sink.Type = "null"
+	pub, err := newSink(config, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, pub.Send([]beat.Event{{Fields: common.MapStr{}}}))
+	pub.Stop()
+}
@@ -0,0 +1,63 @@
+package beater
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+// requestPhases measures how long the single phases of processing a request
+// take. Methods may be called on a nil *requestPhases, which records nothing.
+type requestPhases struct {
+	start  time.Time
+	last   time.Time
+	phases []requestPhase
+}
+
+type requestPhase struct {
+	name     string
+	duration time.Duration
+}
+
+func newRequestPhases() *requestPhases {
+	now := time.Now()
+	return &requestPhases{start: now, last: now}
+}
+
+// done records the time since the previous phase ended as duration of the
+// given phase.
+func (p *requestPhases) done(name string) {
+	if p == nil {
+		return
+	}
+	now := time.Now()
+	p.phases = append(p.phases, requestPhase{name, now.Sub(p.last)})
+	p.last = now
+}
+
+func (p *requestPhases) total() time.Duration {
+	return time.Since(p.start)
+}
+
+func (p *requestPhases) String() string {
+	parts := make([]string, len(p.phases))
+	for i, phase := range p.phases {
+		parts[i] = fmt.Sprintf("%s=%s", phase.name, phase.duration)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// logSlowRequest logs requests which took longer than the threshold at warn
+// level, along with the duration of each phase. A threshold of 0 disables it.
+func logSlowRequest(r *http.Request, threshold time.Duration, code int, phases *requestPhases) {
+	if threshold <= 0 {
+		return
+	}
+	if total := phases.total(); total > threshold {
+		logp.Warn("Slow request: ID=%s, URI=%s, status=%d, duration=%s, phases: %s",
+			requestID(r), r.RequestURI, code, total, phases)
+	}
+}
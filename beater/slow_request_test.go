@@ -0,0 +1,30 @@
+package beater
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestPhases(t *testing.T) {
+	phases := newRequestPhases()
+	phases.done("read")
+	time.Sleep(5 * time.Millisecond)
+	phases.done("validate")
+
+	if assert.Len(t, phases.phases, 2) {
+		assert.Equal(t, "read", phases.phases[0].name)
+		assert.Equal(t, "validate", phases.phases[1].name)
+		assert.True(t, phases.phases[1].duration >= 5*time.Millisecond)
+	}
+	assert.True(t, phases.total() >= 5*time.Millisecond)
+
+	phases.phases = []requestPhase{{"read", time.Millisecond}, {"enqueue", 2 * time.Second}}
+	assert.Equal(t, "read=1ms, enqueue=2s", phases.String())
+}
+
+func TestRequestPhasesNil(t *testing.T) {
+	var phases *requestPhases
+	assert.NotPanics(t, func() { phases.done("read") })
+}
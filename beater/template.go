@@ -0,0 +1,156 @@
+package beater
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/fmtstr"
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/outputs/elasticsearch"
+)
+
+const (
+	templateCheckOff  = "off"
+	templateCheckWarn = "warn"
+	templateCheckFail = "fail"
+)
+
+// TemplateCheckConfig sets what happens when the index template installed in
+// Elasticsearch was not created for the version of the server. Fields added
+// in newer versions are not mapped by an older template, so they end up
+// unsearchable or with the wrong type.
+type TemplateCheckConfig struct {
+	Mode string `config:"mode"`
+}
+
+func (c *TemplateCheckConfig) Validate() error {
+	switch c.Mode {
+	case templateCheckOff, templateCheckWarn, templateCheckFail:
+		return nil
+	}
+	return fmt.Errorf("invalid template_check.mode '%s', must be one of %s, %s, %s",
+		c.Mode, templateCheckOff, templateCheckWarn, templateCheckFail)
+}
+
+// templateClient is the part of the Elasticsearch client needed to look up
+// the installed templates.
+type templateClient interface {
+	Request(method, path string, pipeline string, params map[string]string, body interface{}) (int, []byte, error)
+}
+
+// registerTemplateCheck checks the index template whenever the Elasticsearch
+// output connects, after libbeat installed the template if enabled. In fail
+// mode a mismatch keeps the output from connecting, so that no events are
+// indexed with missing mappings until the template is upgraded. The check is
+// skipped if the index name depends on the events, e.g. on tenant.id, as the
+// index can't be known before events are published.
+func registerTemplateCheck(info beat.Info, output common.ConfigNamespace, config TemplateCheckConfig) {
+	if config.Mode == templateCheckOff || output.Name() != "elasticsearch" {
+		return
+	}
+	index, err := sampleIndex(info, output.Config())
+	if err != nil {
+		logp.Info("Skipping index template check, index name can't be determined: %v", err)
+		return
+	}
+	elasticsearch.RegisterConnectCallback(func(client *elasticsearch.Client) error {
+		err := checkTemplate(client, index, info.Version)
+		if err == nil {
+			return nil
+		}
+		if config.Mode == templateCheckFail {
+			logp.Err("Index template check failed, not publishing events: %v", err)
+			return err
+		}
+		logp.Warn("Index template check failed: %v", err)
+		return nil
+	})
+}
+
+// sampleIndex returns the name of the index events published today are
+// written to. It fails for index names referencing event fields other than
+// beat.name and beat.version.
+func sampleIndex(info beat.Info, cfg *common.Config) (string, error) {
+	var output struct {
+		Index string `config:"index"`
+	}
+	if err := cfg.Unpack(&output); err != nil {
+		return "", err
+	}
+	if output.Index == "" {
+		output.Index = fmt.Sprintf("%s-%s-%%{+yyyy.MM.dd}", info.IndexPrefix, info.Version)
+	}
+	format, err := fmtstr.CompileEvent(output.Index)
+	if err != nil {
+		return "", err
+	}
+	return format.Run(&beat.Event{
+		Timestamp: time.Now(),
+		Fields: common.MapStr{
+			"beat": common.MapStr{"name": info.Name, "version": info.Version},
+		},
+	})
+}
+
+// checkTemplate verifies that a template matching index was created for the
+// given version.
+func checkTemplate(client templateClient, index, version string) error {
+	status, body, err := client.Request("GET", "/_template", "", nil, nil)
+	if err != nil && status != 404 {
+		return fmt.Errorf("fetching index templates: %v", err)
+	}
+	var templates map[string]struct {
+		IndexPatterns []string `json:"index_patterns"`
+		Template      string   `json:"template"`
+		Mappings      map[string]struct {
+			Meta struct {
+				Version string `json:"version"`
+			} `json:"_meta"`
+		} `json:"mappings"`
+	}
+	if status != 404 {
+		if err := json.Unmarshal(body, &templates); err != nil {
+			return fmt.Errorf("parsing index templates: %v", err)
+		}
+	}
+
+	var versions []string
+	for name, t := range templates {
+		patterns := t.IndexPatterns
+		if t.Template != "" {
+			patterns = append(patterns, t.Template)
+		}
+		if !matchesAny(patterns, index) {
+			continue
+		}
+		for _, mapping := range t.Mappings {
+			if v := mapping.Meta.Version; v != "" {
+				if v == version {
+					return nil
+				}
+				versions = append(versions, fmt.Sprintf("%s (%s)", name, v))
+			}
+		}
+	}
+	sort.Strings(versions)
+	if len(versions) == 0 {
+		return fmt.Errorf("no index template for index %s found, run `apm-server setup --template`", index)
+	}
+	return fmt.Errorf("index template for version %s not installed, found %s, run `apm-server setup --template -E setup.template.overwrite=true`",
+		version, strings.Join(versions, ", "))
+}
+
+func matchesAny(patterns []string, index string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, index); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package beater
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+type fakeTemplateClient struct {
+	status int
+	body   string
+}
+
+func (c fakeTemplateClient) Request(method, path string, pipeline string, params map[string]string, body interface{}) (int, []byte, error) {
+	if c.status >= 300 {
+		return c.status, []byte(c.body), errors.New("request failed")
+	}
+	return c.status, []byte(c.body), nil
+}
+
+func TestCheckTemplate(t *testing.T) {
+	index := "apm-7.0.0-2018.01.02"
+	for name, test := range map[string]struct {
+		client fakeTemplateClient
+		valid  bool
+	}{
+		"matching version": {
+			client: fakeTemplateClient{200, `{
+				"apm-6.3.0": {"index_patterns": ["apm-6.3.0-*"], "mappings": {"doc": {"_meta": {"version": "6.3.0"}}}},
+				"apm-7.0.0": {"index_patterns": ["apm-7.0.0-*"], "mappings": {"doc": {"_meta": {"version": "7.0.0"}}}}}`},
+			valid: true,
+		},
+		"es5 template": {
+			client: fakeTemplateClient{200, `{"apm": {"template": "apm-*", "mappings": {"_default_": {"_meta": {"version": "7.0.0"}}}}}`},
+			valid:  true,
+		},
+		"outdated version": {
+			client: fakeTemplateClient{200, `{"apm": {"index_patterns": ["apm-*"], "mappings": {"doc": {"_meta": {"version": "6.3.0"}}}}}`},
+		},
+		"other templates": {
+			client: fakeTemplateClient{200, `{"filebeat-7.0.0": {"index_patterns": ["filebeat-*"], "mappings": {"doc": {"_meta": {"version": "7.0.0"}}}}}`},
+		},
+		"no templates": {
+			client: fakeTemplateClient{404, `{}`},
+		},
+		"error": {
+			client: fakeTemplateClient{500, `{"error": "boom"}`},
+		},
+	} {
+		err := checkTemplate(test.client, index, "7.0.0")
+		if test.valid {
+			assert.NoError(t, err, name)
+		} else {
+			assert.Error(t, err, name)
+		}
+	}
+}
+
+func TestSampleIndex(t *testing.T) {
+	info := beat.Info{IndexPrefix: "apm", Version: "7.0.0"}
+	index, err := sampleIndex(info, common.NewConfig())
+	assert.NoError(t, err)
+	assert.Regexp(t, `^apm-7\.0\.0-\d{4}\.\d{2}\.\d{2}$`, index)
+
+	cfg, err := common.NewConfigFrom(map[string]interface{}{"index": "apm-%{[beat.version]}"})
+	assert.NoError(t, err)
+	index, err = sampleIndex(info, cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, "apm-7.0.0", index)
+
+	cfg, err = common.NewConfigFrom(map[string]interface{}{"index": "apm-%{[tenant.id]}-%{[beat.version]}-%{+yyyy.MM.dd}"})
+	assert.NoError(t, err)
+	_, err = sampleIndex(info, cfg)
+	assert.Error(t, err)
+}
+
+func TestRegisterTemplateCheckEventIndex(t *testing.T) {
+	output := common.ConfigNamespace{}
+	cfg, err := common.NewConfigFrom(map[string]interface{}{
+		"elasticsearch": map[string]interface{}{"index": "apm-%{[tenant.id]}-%{[beat.version]}-%{+yyyy.MM.dd}"},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, cfg.Unpack(&output))
+
+	// the check is skipped instead of keeping the server from starting
+	registerTemplateCheck(beat.Info{IndexPrefix: "apm", Version: "7.0.0"}, output, TemplateCheckConfig{Mode: templateCheckFail})
+}
+
+func TestTemplateCheckConfigValidate(t *testing.T) {
+	for _, mode := range []string{"off", "warn", "fail"} {
+		assert.NoError(t, (&TemplateCheckConfig{Mode: mode}).Validate())
+	}
+	assert.Error(t, (&TemplateCheckConfig{Mode: "upgrade"}).Validate())
+}
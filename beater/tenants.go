@@ -0,0 +1,96 @@
+package beater
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/elastic/beats/libbeat/beat"
+)
+
+const tenantIDKey contextKey = "tenantID"
+
+// tenant ids end up in index names, so they are limited to characters
+// allowed there
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+type TenantConfig struct {
+	ID          string `config:"id" validate:"required"`
+	SecretToken string `config:"secret_token" validate:"required"`
+}
+
+func validateTenants(tenants []TenantConfig) error {
+	ids := map[string]bool{}
+	tokens := map[string]bool{}
+	for _, t := range tenants {
+		if !tenantIDPattern.MatchString(t.ID) {
+			return fmt.Errorf("invalid tenant id '%s', must only contain lowercase letters, digits, '_' and '-'", t.ID)
+		}
+		if ids[t.ID] {
+			return fmt.Errorf("duplicate tenant id '%s'", t.ID)
+		}
+		if tokens[t.SecretToken] {
+			return fmt.Errorf("duplicate secret_token for tenant '%s'", t.ID)
+		}
+		ids[t.ID] = true
+		tokens[t.SecretToken] = true
+	}
+	return nil
+}
+
+// tenantAuthHandler authorizes requests with the secret token of one of the
+// configured tenants and passes the id of the tenant on with the request.
+// Requests authorized with the general secret token, if set, are accepted
+// without a tenant.
+func tenantAuthHandler(secretToken string, tenants []TenantConfig, h http.Handler) http.Handler {
+	if len(tenants) == 0 {
+		return authHandler(secretToken, h)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id, ok := authorizedTenant(r, tenants); ok {
+			h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tenantIDKey, id)))
+			return
+		}
+		if secretToken != "" && isAuthorized(r, secretToken) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		sendStatus(w, r, http.StatusUnauthorized, errInvalidToken)
+	})
+}
+
+// authorizedTenant returns the id of the tenant whose secret token was sent
+// with the request. All tokens are compared to not leak which one matched
+// through the response time.
+func authorizedTenant(r *http.Request, tenants []TenantConfig) (string, bool) {
+	var id string
+	for _, t := range tenants {
+		if isAuthorized(r, t.SecretToken) {
+			id = t.ID
+		}
+	}
+	return id, id != ""
+}
+
+// tenantReporter returns a reporter stamping the id of the tenant the request
+// was authorized for on all its events. The id can be used in the index name
+// of the Elasticsearch output to keep the data of tenants apart.
+func tenantReporter(r *http.Request, report reporter) reporter {
+	id := tenantID(r)
+	if id == "" {
+		return report
+	}
+	return func(events []beat.Event) error {
+		for _, event := range events {
+			event.Fields.Put("tenant.id", id)
+		}
+		return report(events)
+	}
+}
+
+// tenantID returns the id of the tenant the request was authorized for.
+func tenantID(r *http.Request) string {
+	id, _ := r.Context().Value(tenantIDKey).(string)
+	return id
+}
@@ -0,0 +1,77 @@
+package beater
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/apm-server/tests"
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestTenantAuthHandler(t *testing.T) {
+	tenants := []TenantConfig{{ID: "team-a", SecretToken: "token-a"}, {ID: "team-b", SecretToken: "token-b"}}
+
+	for idx, test := range []struct {
+		secretToken string
+		auth        string
+		code        int
+		tenant      string
+	}{
+		{auth: "Bearer token-a", code: http.StatusOK, tenant: "team-a"},
+		{auth: "Bearer token-b", code: http.StatusOK, tenant: "team-b"},
+		{auth: "Bearer token-c", code: http.StatusUnauthorized},
+		{auth: "", code: http.StatusUnauthorized},
+		{secretToken: "general", auth: "Bearer general", code: http.StatusOK},
+		{secretToken: "general", auth: "Bearer token-a", code: http.StatusOK, tenant: "team-a"},
+	} {
+		var tenant string
+		h := tenantAuthHandler(test.secretToken, tenants, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenant = tenantID(r)
+		}))
+		req, err := http.NewRequest("POST", "_", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Authorization", test.auth)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		assert.Equal(t, test.code, w.Code, "Failed at idx %v", idx)
+		assert.Equal(t, test.tenant, tenant, "Failed at idx %v", idx)
+	}
+}
+
+func TestTenantStampedOnEvents(t *testing.T) {
+	payload, err := tests.LoadValidData("transaction")
+	assert.NoError(t, err)
+
+	var reported []beat.Event
+	config := defaultConfig
+	config.Tenants = []TenantConfig{{ID: "team-a", SecretToken: "token-a"}}
+	mux := newMuxer(config, func(events []beat.Event) error {
+		reported = events
+		return nil
+	})
+
+	req, err := http.NewRequest("POST", BackendTransactionsURL, bytes.NewReader(payload))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer token-a")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	assert.NotEmpty(t, reported)
+	for _, event := range reported {
+		assert.Equal(t, common.MapStr{"id": "team-a"}, event.Fields["tenant"])
+	}
+}
+
+func TestValidateTenants(t *testing.T) {
+	assert.NoError(t, validateTenants([]TenantConfig{{ID: "team-a", SecretToken: "a"}, {ID: "team_b-2", SecretToken: "b"}}))
+	assert.Error(t, validateTenants([]TenantConfig{{ID: "Team A", SecretToken: "a"}}))
+	assert.Error(t, validateTenants([]TenantConfig{{ID: "a", SecretToken: "x"}, {ID: "a", SecretToken: "y"}}))
+	assert.Error(t, validateTenants([]TenantConfig{{ID: "a", SecretToken: "x"}, {ID: "b", SecretToken: "x"}}))
+}
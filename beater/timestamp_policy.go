@@ -0,0 +1,40 @@
+package beater
+
+import (
+	"errors"
+	"time"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+var (
+	timestampOutOfRange = monitoring.NewInt(serverMetrics, "events.timestamp_out_of_range")
+
+	errTimestampOutOfRange = errors.New("event timestamp out of accepted range")
+)
+
+// applyTimestampPolicy checks that all event timestamps lie within the
+// accepted range around the time the request was received. Depending on the
+// configured action, an error is returned or the timestamps are set to the
+// closest accepted time. A zero duration disables the respective check.
+func applyTimestampPolicy(events []beat.Event, received time.Time, policy TimestampPolicyConfig) error {
+	for i := range events {
+		event := &events[i]
+		var bound time.Time
+		if policy.MaxFuture > 0 && event.Timestamp.After(received.Add(policy.MaxFuture)) {
+			bound = received.Add(policy.MaxFuture)
+		} else if policy.MaxPast > 0 && event.Timestamp.Before(received.Add(-policy.MaxPast)) {
+			bound = received.Add(-policy.MaxPast)
+		} else {
+			continue
+		}
+
+		timestampOutOfRange.Inc()
+		if policy.Action != timestampActionClamp {
+			return errTimestampOutOfRange
+		}
+		event.Timestamp = bound
+	}
+	return nil
+}
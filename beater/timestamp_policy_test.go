@@ -0,0 +1,54 @@
+package beater
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+)
+
+func TestApplyTimestampPolicy(t *testing.T) {
+	received := time.Now()
+	events := func() []beat.Event {
+		return []beat.Event{
+			{Timestamp: received.Add(-48 * time.Hour)},
+			{Timestamp: received},
+			{Timestamp: received.Add(2 * time.Hour)},
+		}
+	}
+
+	// disabled
+	evs := events()
+	assert.NoError(t, applyTimestampPolicy(evs, received, TimestampPolicyConfig{}))
+	assert.Equal(t, events(), evs)
+
+	// within range
+	evs = events()
+	policy := TimestampPolicyConfig{MaxFuture: 3 * time.Hour, MaxPast: 72 * time.Hour, Action: timestampActionReject}
+	assert.NoError(t, applyTimestampPolicy(evs, received, policy))
+	assert.Equal(t, events(), evs)
+
+	// reject
+	before := timestampOutOfRange.Get()
+	policy = TimestampPolicyConfig{MaxFuture: time.Hour, Action: timestampActionReject}
+	assert.Equal(t, errTimestampOutOfRange, applyTimestampPolicy(events(), received, policy))
+	assert.Equal(t, before+1, timestampOutOfRange.Get())
+
+	// clamp
+	evs = events()
+	policy = TimestampPolicyConfig{MaxFuture: time.Hour, MaxPast: 24 * time.Hour, Action: timestampActionClamp}
+	assert.NoError(t, applyTimestampPolicy(evs, received, policy))
+	assert.Equal(t, received.Add(-24*time.Hour), evs[0].Timestamp)
+	assert.Equal(t, received, evs[1].Timestamp)
+	assert.Equal(t, received.Add(time.Hour), evs[2].Timestamp)
+	assert.Equal(t, before+3, timestampOutOfRange.Get())
+}
+
+func TestTimestampPolicyConfigValidate(t *testing.T) {
+	for _, action := range []string{"", "reject", "clamp"} {
+		assert.NoError(t, (&TimestampPolicyConfig{Action: action}).Validate())
+	}
+	assert.Error(t, (&TimestampPolicyConfig{Action: "drop"}).Validate())
+}
@@ -0,0 +1,41 @@
+package beater
+
+import (
+	"time"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+var traceStacktraceDropped = monitoring.NewInt(serverMetrics, "events.trace_stacktrace_dropped")
+
+// traceStacktraceReporter returns a reporter removing the stacktrace from
+// trace events with a duration below minDuration before forwarding the
+// events. Many agents collect a stacktrace for every trace, and for short
+// traces these usually make up most of the indexed data without being looked
+// at. A minDuration of 0 keeps all stacktraces.
+func traceStacktraceReporter(minDuration time.Duration, report reporter) reporter {
+	if minDuration <= 0 {
+		return report
+	}
+	minMicros := minDuration.Nanoseconds() / int64(time.Microsecond)
+	return func(events []beat.Event) error {
+		for _, event := range events {
+			if event.Fields == nil {
+				continue
+			}
+			if ok, _ := event.Fields.HasKey("trace.stacktrace"); !ok {
+				continue
+			}
+			duration, err := event.Fields.GetValue("trace.duration.us")
+			if err != nil {
+				continue
+			}
+			if us, ok := duration.(int); ok && int64(us) < minMicros {
+				event.Fields.Delete("trace.stacktrace")
+				traceStacktraceDropped.Inc()
+			}
+		}
+		return report(events)
+	}
+}
@@ -0,0 +1,54 @@
+package beater
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestTraceStacktraceReporter(t *testing.T) {
+	var reported []beat.Event
+	report := func(events []beat.Event) error {
+		reported = events
+		return nil
+	}
+	trace := func(us int) beat.Event {
+		return beat.Event{Fields: common.MapStr{"trace": common.MapStr{
+			"duration":   common.MapStr{"us": us},
+			"stacktrace": []common.MapStr{{"filename": "file.go"}},
+		}}}
+	}
+
+	events := []beat.Event{
+		trace(999),
+		trace(1000),
+		{Fields: common.MapStr{"transaction": common.MapStr{"duration": common.MapStr{"us": 10}}}},
+		{Fields: common.MapStr{"error": common.MapStr{"exception": common.MapStr{"stacktrace": []common.MapStr{}}}}},
+	}
+	before := traceStacktraceDropped.Get()
+	assert.NoError(t, traceStacktraceReporter(time.Millisecond, report)(events))
+
+	assert.Equal(t, common.MapStr{"duration": common.MapStr{"us": 999}}, reported[0].Fields["trace"])
+	assert.Contains(t, reported[1].Fields["trace"], "stacktrace")
+	assert.Equal(t, events[2].Fields, reported[2].Fields)
+	assert.Equal(t, events[3].Fields, reported[3].Fields)
+	assert.Equal(t, before+1, traceStacktraceDropped.Get())
+}
+
+func TestTraceStacktraceReporterDisabled(t *testing.T) {
+	var reported []beat.Event
+	report := func(events []beat.Event) error {
+		reported = events
+		return nil
+	}
+	event := beat.Event{Fields: common.MapStr{"trace": common.MapStr{
+		"duration":   common.MapStr{"us": 1},
+		"stacktrace": []common.MapStr{{"filename": "file.go"}},
+	}}}
+	assert.NoError(t, traceStacktraceReporter(0, report)([]beat.Event{event}))
+	assert.Contains(t, reported[0].Fields["trace"], "stacktrace")
+}
@@ -0,0 +1,120 @@
+package beater
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/elastic/apm-server/processor"
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+const (
+	validationModeStrict  = "strict"
+	validationModeLenient = "lenient"
+)
+
+var (
+	coercedFields = monitoring.NewInt(serverMetrics, "validation.coerced_fields")
+	droppedFields = monitoring.NewInt(serverMetrics, "validation.dropped_fields")
+)
+
+type ValidationConfig struct {
+	Mode string `config:"mode"`
+}
+
+func (c *ValidationConfig) Validate() error {
+	switch c.Mode {
+	case "", validationModeStrict, validationModeLenient:
+		return nil
+	}
+	return fmt.Errorf("invalid validation.mode '%s', must be one of %s, %s",
+		c.Mode, validationModeStrict, validationModeLenient)
+}
+
+// validationFactory returns a factory for processors validating payloads
+// according to the configured mode.
+func validationFactory(config ValidationConfig, pf ProcessorFactory) ProcessorFactory {
+	return func(pc processor.Config) processor.Processor {
+		return &validatingProcessor{Processor: pf(pc), lenient: config.Mode == validationModeLenient}
+	}
+}
+
+// validatingProcessor counts the fields not allowed by the schema per agent.
+// In lenient mode, it accepts payloads only failing validation because of
+// such fields or numbers sent as strings, by dropping the fields and
+// converting the values. This eases rolling out agent upgrades before the
+// server is upgraded. As a new processor is created per request, the relaxed
+// payload is kept for Transform, which is called with the same payload as
+// Validate.
+type validatingProcessor struct {
+	processor.Processor
+	lenient bool
+	relaxed []byte
+}
+
+func (p *validatingProcessor) Validate(buf []byte) error {
+	var unknown []string
+	validate := func(b []byte) error {
+		err := p.Processor.Validate(b)
+		unknown = append(unknown, processor.UnknownFields(err)...)
+		return err
+	}
+	if !p.lenient {
+		err := validate(buf)
+		recordUnknownFields(buf, unknown)
+		return err
+	}
+	// Relax validates once per relaxed field, the unknown fields found are
+	// counted once for the payload afterwards
+	relaxed, stats, err := processor.Relax(buf, validate)
+	recordUnknownFields(buf, unknown)
+	if err != nil {
+		return err
+	}
+	coercedFields.Add(int64(stats.Coerced))
+	droppedFields.Add(int64(stats.Dropped))
+	p.relaxed = relaxed
+	return nil
+}
+
+func (p *validatingProcessor) Transform(buf []byte) ([]beat.Event, error) {
+	if p.relaxed != nil {
+		buf = p.relaxed
+	}
+	return p.Processor.Transform(buf)
+}
+
+// recordUnknownFields counts the fields not allowed by the schema for the
+// agent that sent the payload, once per payload even if a field is sent in
+// several events or reported by several validations.
+func recordUnknownFields(payload []byte, fields []string) {
+	if len(fields) == 0 {
+		return
+	}
+	var agent struct {
+		App struct {
+			Agent struct {
+				Name    string `json:"name"`
+				Version string `json:"version"`
+			} `json:"agent"`
+		} `json:"app"`
+	}
+	json.Unmarshal(payload, &agent)
+	key := agentKey{name: agent.App.Agent.Name, version: agent.App.Agent.Version}
+	if key.name == "" {
+		key.name = "unknown"
+	}
+	if key.version == "" {
+		key.version = "unknown"
+	}
+	seen := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		if seen[field] {
+			continue
+		}
+		seen[field] = true
+		key.scope = field
+		unknownFieldStats.count(key)
+	}
+}
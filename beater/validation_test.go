@@ -0,0 +1,135 @@
+package beater
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/beat"
+)
+
+func TestValidationModes(t *testing.T) {
+	payload := `{
+		"app": {"name": "app", "agent": {"name": "go", "version": "1.0"}},
+		"transactions": [{
+			"id": "945254c5-67a5-417e-8a4e-aa29efcbfb79",
+			"name": "GET /", "type": "request", "duration": %s,
+			"timestamp": "2017-05-30T18:53:27.154Z",
+			"context": {"custom": {"ok": 1, "not.ok": 2}}
+		}]
+	}`
+
+	send := func(config Config, duration string) (*httptest.ResponseRecorder, []beat.Event) {
+		var reported []beat.Event
+		mux := newMuxer(config, func(events []beat.Event) error {
+			reported = events
+			return nil
+		})
+		body := fmt.Sprintf(payload, duration)
+		req, err := http.NewRequest("POST", BackendTransactionsURL, strings.NewReader(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w, reported
+	}
+
+	key := agentKey{scope: "transactions_context_custom_not_ok", name: "go", version: "1_0"}
+	unknownFields := func() int64 {
+		if count, ok := unknownFieldStats.cache.Get(key); ok {
+			return *count.(*int64)
+		}
+		return 0
+	}
+
+	before := unknownFields()
+	w, _ := send(defaultConfig, "32.5")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, before+1, unknownFields())
+
+	coerced, dropped := coercedFields.Get(), droppedFields.Get()
+	config := defaultConfig
+	config.Validation.Mode = validationModeLenient
+	w, events := send(config, `"32.5"`)
+	assert.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	assert.Len(t, events, 1)
+	duration, _ := events[0].Fields.GetValue("transaction.duration.us")
+	assert.Equal(t, 32500, duration)
+	custom, _ := events[0].Fields.GetValue("context.custom")
+	assert.Equal(t, map[string]interface{}{"ok": 1.0}, custom)
+	assert.Equal(t, coerced+1, coercedFields.Get())
+	assert.Equal(t, dropped+1, droppedFields.Get())
+	assert.Equal(t, before+2, unknownFields())
+}
+
+func TestValidationModeUnknownFieldsCountedOnce(t *testing.T) {
+	tx := `{"id": "945254c5-67a5-417e-8a4e-aa29efcbfb79", "name": "GET /", "type": "request",
+		"duration": 1, "timestamp": "2017-05-30T18:53:27.154Z", "context": {"custom": {"a.b": 1}}}`
+	payload := `{"app": {"name": "app", "agent": {"name": "go", "version": "1.1"}},
+		"transactions": [` + tx + `,` + tx + `,` + tx + `]}`
+
+	key := agentKey{scope: "transactions_context_custom_a_b", name: "go", version: "1_1"}
+	unknownFields := func() int64 {
+		if count, ok := unknownFieldStats.cache.Get(key); ok {
+			return *count.(*int64)
+		}
+		return 0
+	}
+
+	config := defaultConfig
+	config.Validation.Mode = validationModeLenient
+	var reported []beat.Event
+	mux := newMuxer(config, func(events []beat.Event) error {
+		reported = events
+		return nil
+	})
+	before := unknownFields()
+	req, err := http.NewRequest("POST", BackendTransactionsURL, strings.NewReader(payload))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	assert.Len(t, reported, 3)
+	assert.Equal(t, before+1, unknownFields())
+}
+
+func TestValidationModeDebugAndReplay(t *testing.T) {
+	payload := []byte(`{
+		"app": {"name": "app", "agent": {"name": "go", "version": "1.0"}},
+		"transactions": [{
+			"id": "945254c5-67a5-417e-8a4e-aa29efcbfb79",
+			"name": "GET /", "type": "request", "duration": "32.5",
+			"timestamp": "2017-05-30T18:53:27.154Z"
+		}]
+	}`)
+
+	enabled := true
+	config := defaultConfig
+	config.DebugEndpoint = &DebugEndpointConfig{Enabled: &enabled}
+	config.Validation.Mode = validationModeLenient
+
+	mux := newMuxer(config, nil)
+	req, err := http.NewRequest("POST", DebugTransformURL+BackendTransactionsURL, bytes.NewReader(payload))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var published []beat.Event
+	rp := &replayer{config: config}
+	header := http.Header{"Content-Type": []string{"application/json"}}
+	code, err := rp.replay(replayPayload{path: BackendTransactionsURL, header: header, body: payload}, func(events []beat.Event) error {
+		published = append(published, events...)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, code)
+	assert.Len(t, published, 1)
+}
@@ -0,0 +1,415 @@
+package beater
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	err "github.com/elastic/apm-server/processor/error"
+	"github.com/elastic/apm-server/processor/transaction"
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+// FrontendWebSocketURL accepts frontend payloads over a WebSocket connection,
+// saving browser agents a request per payload.
+const FrontendWebSocketURL = "/v1/client-side/ws"
+
+const (
+	webSocketGUID        = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	webSocketIdleTimeout = time.Minute
+
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+
+	wsCloseNormal        = 1000
+	wsCloseProtocolError = 1002
+	wsCloseTooLarge      = 1009
+)
+
+var (
+	webSocketConnections = monitoring.NewInt(serverMetrics, "websocket.connections")
+	webSocketMessages    = monitoring.NewInt(serverMetrics, "websocket.messages")
+
+	errWebSocketHandshake = errors.New("invalid websocket handshake")
+	errWebSocketProtocol  = errors.New("websocket protocol error")
+	errUnknownPayload     = errors.New("payload contains neither transactions nor errors")
+)
+
+// webSocketHandler upgrades requests to WebSocket connections. Every text or
+// binary message holds one or more frontend payloads, separated by newlines.
+// Payloads containing `transactions` are processed like requests to the
+// frontend transactions route, payloads containing `errors` like requests to
+// the frontend errors route. The rate limit per IP address applies per
+// payload, as does the concurrency limit, and messages are charged to the
+// memory budget while they are processed. Payloads that cannot be processed
+// are answered with a message containing the error, accepted payloads are
+// not acknowledged. Connections are registered with conns, so that they are
+// closed when the server stops.
+func webSocketHandler(config Config, conns *webSocketConns, budget *memoryBudget, limiter *adaptiveLimiter, routeReporter func(path string) reporter) http.Handler {
+	rateLimiter := newRateLimiter(config.Frontend.RateLimit, config.Frontend.RateLimiter)
+	return logHandler(
+		frontendSwitchHandler(config.Frontend.isEnabled(), config.Frontend.DisabledStatus,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !originAllowed(config.Frontend.AllowOrigins, r.Header.Get("Origin")) {
+					sendStatus(w, r, http.StatusForbidden, errForbidden)
+					return
+				}
+				conn, rw, err := upgradeWebSocket(w, r)
+				if err != nil {
+					sendStatus(w, r, http.StatusBadRequest, err)
+					return
+				}
+				defer conn.Close()
+				if !conns.add(conn) {
+					return
+				}
+				defer conns.remove(conn)
+				webSocketConnections.Inc()
+				defer webSocketConnections.Dec()
+
+				ws := &webSocketConn{conn: conn, rw: rw, maxSize: config.Frontend.MaxUnzippedSize}
+				ip := extractIP(r)
+				ws.serve(func(buf []byte) {
+					if !budget.reserve(int64(len(buf))) {
+						inFlightBytesRejected.Inc()
+						ws.sendError(r, http.StatusServiceUnavailable, errInFlightBytes)
+						return
+					}
+					defer budget.release(int64(len(buf)))
+
+					for _, line := range bytes.Split(buf, []byte("\n")) {
+						if len(bytes.TrimSpace(line)) == 0 {
+							continue
+						}
+						if ok, _ := rateLimiter.allow(ip); !ok {
+							ws.sendError(r, http.StatusTooManyRequests, errTooManyRequests)
+							continue
+						}
+						if !limiter.acquire() {
+							concurrencyRejected.Inc()
+							ws.sendError(r, http.StatusServiceUnavailable, errOverloaded)
+							continue
+						}
+						code, err := processWebSocketPayload(r, config, line, routeReporter)
+						limiter.release()
+						if err != nil {
+							ws.sendError(r, code, err)
+						}
+					}
+				})
+			})))
+}
+
+// processWebSocketPayload processes a single payload received over a
+// WebSocket connection.
+func processWebSocketPayload(r *http.Request, config Config, payload []byte, routeReporter func(path string) reporter) (int, error) {
+	path, pf, err := webSocketRoute(payload)
+	if err != nil {
+		return http.StatusBadRequest, newCodedError("ERR_VALIDATION", err)
+	}
+	report := requestReporter(r, config, routeReporter(path))
+	processor := intakeFactory(config, pf)(config.processorConfig())
+	return processPayload(path, processor, payload, report, nil)
+}
+
+// webSocketRoute returns the route a payload sent over a WebSocket is
+// processed as.
+func webSocketRoute(payload []byte) (string, ProcessorFactory, error) {
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &keys); err != nil {
+		return "", nil, err
+	}
+	if _, ok := keys["transactions"]; ok {
+		return FrontendTransactionsURL, transaction.NewProcessor, nil
+	}
+	if _, ok := keys["errors"]; ok {
+		return FrontendErrorsURL, err.NewProcessor, nil
+	}
+	return "", nil, errUnknownPayload
+}
+
+func originAllowed(allowedOrigins []string, origin string) bool {
+	for _, allowed := range allowedOrigins {
+		if origin == allowed || allowed == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// upgradeWebSocket performs the opening handshake defined in RFC 6455 and
+// takes over the connection.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (net.Conn, *bufio.ReadWriter, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != "GET" || key == "" ||
+		!headerContains(r.Header.Get("Connection"), "upgrade") ||
+		!strings.EqualFold(r.Header.Get("Upgrade"), "websocket") ||
+		r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return nil, nil, errWebSocketHandshake
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, nil, errWebSocketHandshake
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	// deadlines set by the server for reading the request would otherwise
+	// apply to the whole connection
+	conn.SetDeadline(time.Time{})
+
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + webSocketAccept(key) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, rw, nil
+}
+
+func webSocketAccept(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + webSocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func headerContains(header, token string) bool {
+	for _, part := range strings.Split(header, ",") {
+		if strings.EqualFold(strings.TrimSpace(part), token) {
+			return true
+		}
+	}
+	return false
+}
+
+// webSocketConns keeps track of the open WebSocket connections. They are
+// taken over from the HTTP server, which doesn't close them when it is shut
+// down.
+type webSocketConns struct {
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+}
+
+func newWebSocketConns() *webSocketConns {
+	return &webSocketConns{conns: map[net.Conn]struct{}{}}
+}
+
+// add registers the connection, unless the connections were already closed.
+func (c *webSocketConns) add(conn net.Conn) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return false
+	}
+	c.conns[conn] = struct{}{}
+	return true
+}
+
+func (c *webSocketConns) remove(conn net.Conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.conns, conn)
+}
+
+// closeAll closes all open connections, and any connection added later.
+func (c *webSocketConns) closeAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	for conn := range c.conns {
+		conn.Close()
+	}
+}
+
+// webSocketConn reads messages from and writes frames to a WebSocket
+// connection, as far as needed to receive data from clients.
+type webSocketConn struct {
+	conn    net.Conn
+	rw      *bufio.ReadWriter
+	maxSize int64
+}
+
+// serve calls handle with every message received, until the connection is
+// closed by the client, is idle for too long or a protocol error occurs.
+func (c *webSocketConn) serve(handle func([]byte)) {
+	var message []byte
+	var inMessage bool
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(webSocketIdleTimeout))
+		frame, err := readWebSocketFrame(c.rw.Reader, c.maxSize-int64(len(message)))
+		if err == errRequestTooLarge {
+			c.close(wsCloseTooLarge)
+			return
+		}
+		if err != nil {
+			if err != io.EOF {
+				logp.Debug("websocket", "Closing websocket connection: %s", err)
+				c.close(wsCloseProtocolError)
+			}
+			return
+		}
+		if !frame.masked {
+			c.close(wsCloseProtocolError)
+			return
+		}
+
+		switch frame.opcode {
+		case wsOpPing:
+			c.writeFrame(wsOpPong, frame.payload)
+		case wsOpPong:
+		case wsOpClose:
+			c.close(wsCloseNormal)
+			return
+		case wsOpText, wsOpBinary, wsOpContinuation:
+			if (frame.opcode == wsOpContinuation) != inMessage {
+				c.close(wsCloseProtocolError)
+				return
+			}
+			message = append(message, frame.payload...)
+			inMessage = !frame.fin
+			if frame.fin {
+				webSocketMessages.Inc()
+				handle(message)
+				message = nil
+			}
+		default:
+			c.close(wsCloseProtocolError)
+			return
+		}
+	}
+}
+
+// sendError sends the error as a JSON message, in the same format as errors
+// are sent in HTTP responses.
+func (c *webSocketConn) sendError(r *http.Request, code int, err error) {
+	responseErrors.Inc()
+	logp.Err("%s, code=%d, request_id=%s", err.Error(), code, requestID(r))
+	buf, _ := json.Marshal(map[string]interface{}{
+		"error":      err.Error(),
+		"code":       errorCode(err, code),
+		"request_id": requestID(r),
+	})
+	c.writeFrame(wsOpText, buf)
+}
+
+func (c *webSocketConn) close(status uint16) {
+	payload := make([]byte, 2)
+	binary.BigEndian.PutUint16(payload, status)
+	c.writeFrame(wsOpClose, payload)
+}
+
+func (c *webSocketConn) writeFrame(opcode byte, payload []byte) error {
+	if _, err := c.rw.Write(encodeWebSocketFrame(opcode, payload, nil)); err != nil {
+		return err
+	}
+	return c.rw.Flush()
+}
+
+type webSocketFrame struct {
+	fin     bool
+	opcode  byte
+	masked  bool
+	payload []byte
+}
+
+// readWebSocketFrame reads a single frame, unmasking its payload. Frames with
+// a payload bigger than maxSize are not read, but errRequestTooLarge is
+// returned.
+func readWebSocketFrame(r io.Reader, maxSize int64) (*webSocketFrame, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	if header[0]&0x70 != 0 {
+		// no extensions are negotiated, so reserved bits must not be set
+		return nil, errWebSocketProtocol
+	}
+	frame := &webSocketFrame{
+		fin:    header[0]&0x80 != 0,
+		opcode: header[0] & 0x0F,
+		masked: header[1]&0x80 != 0,
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > uint64(maxSize) {
+		return nil, errRequestTooLarge
+	}
+
+	var mask [4]byte
+	if frame.masked {
+		if _, err := io.ReadFull(r, mask[:]); err != nil {
+			return nil, err
+		}
+	}
+	frame.payload = make([]byte, length)
+	if _, err := io.ReadFull(r, frame.payload); err != nil {
+		return nil, err
+	}
+	if frame.masked {
+		for i := range frame.payload {
+			frame.payload[i] ^= mask[i%4]
+		}
+	}
+	return frame, nil
+}
+
+// encodeWebSocketFrame encodes a final frame. Frames sent by the server are
+// not masked, mask must only be given for frames sent by clients.
+func encodeWebSocketFrame(opcode byte, payload []byte, mask []byte) []byte {
+	buf := []byte{0x80 | opcode}
+	var maskBit byte
+	if mask != nil {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		buf = append(buf, maskBit|byte(n))
+	case n <= 0xFFFF:
+		buf = append(buf, maskBit|126, byte(n>>8), byte(n))
+	default:
+		ext := make([]byte, 8)
+		binary.BigEndian.PutUint64(ext, uint64(n))
+		buf = append(append(buf, maskBit|127), ext...)
+	}
+	if mask == nil {
+		return append(buf, payload...)
+	}
+	buf = append(buf, mask...)
+	for i, b := range payload {
+		buf = append(buf, b^mask[i%4])
+	}
+	return buf
+}
@@ -0,0 +1,175 @@
+package beater
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/apm-server/tests"
+	"github.com/elastic/beats/libbeat/beat"
+)
+
+var testMask = []byte{1, 2, 3, 4}
+
+func dialWebSocket(t *testing.T, url string) (net.Conn, *bufio.Reader) {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	assert.NoError(t, err)
+	req, err := http.NewRequest("GET", url+FrontendWebSocketURL, nil)
+	assert.NoError(t, err)
+	req.Header.Set("Connection", "keep-alive, Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Origin", "http://example.com")
+	assert.NoError(t, req.Write(conn))
+
+	r := bufio.NewReader(conn)
+	res, err := http.ReadResponse(r, req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusSwitchingProtocols, res.StatusCode)
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", res.Header.Get("Sec-WebSocket-Accept"))
+	return conn, r
+}
+
+func TestWebSocketIntake(t *testing.T) {
+	payload, err := tests.LoadValidData("transaction")
+	assert.NoError(t, err)
+	var line bytes.Buffer
+	assert.NoError(t, json.Compact(&line, payload))
+
+	var mu sync.Mutex
+	var reported []beat.Event
+	config := defaultConfig
+	config.Frontend = &FrontendConfig{Enabled: new(bool), RateLimit: 10, RateLimiter: defaultConfig.Frontend.RateLimiter, AllowOrigins: []string{"*"}, MaxUnzippedSize: 1024 * 1024, WebSocket: true}
+	*config.Frontend.Enabled = true
+	server := httptest.NewServer(newMuxer(config, func(events []beat.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		reported = append(reported, events...)
+		return nil
+	}))
+	defer server.Close()
+
+	conn, r := dialWebSocket(t, server.URL)
+	defer conn.Close()
+
+	// a valid and an invalid payload in one message, the message is split
+	// into two frames
+	message := append(line.Bytes(), []byte("\n{\"foo\": 1}\n")...)
+	half := len(message) / 2
+	first := encodeWebSocketFrame(wsOpText, message[:half], testMask)
+	first[0] &^= 0x80
+	_, err = conn.Write(first)
+	assert.NoError(t, err)
+	_, err = conn.Write(encodeWebSocketFrame(wsOpContinuation, message[half:], testMask))
+	assert.NoError(t, err)
+
+	frame, err := readWebSocketFrame(r, 1024)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(wsOpText), frame.opcode)
+	var body map[string]interface{}
+	assert.NoError(t, json.Unmarshal(frame.payload, &body))
+	assert.Equal(t, "ERR_VALIDATION", body["code"])
+
+	mu.Lock()
+	assert.NotEmpty(t, reported)
+	for _, event := range reported {
+		assert.Contains(t, event.Fields, "processor")
+	}
+	mu.Unlock()
+
+	_, err = conn.Write(encodeWebSocketFrame(wsOpPing, []byte("hi"), testMask))
+	assert.NoError(t, err)
+	frame, err = readWebSocketFrame(r, 1024)
+	assert.NoError(t, err)
+	assert.Equal(t, &webSocketFrame{fin: true, opcode: wsOpPong, payload: []byte("hi")}, frame)
+
+	_, err = conn.Write(encodeWebSocketFrame(wsOpClose, nil, testMask))
+	assert.NoError(t, err)
+	frame, err = readWebSocketFrame(r, 1024)
+	assert.NoError(t, err)
+	assert.Equal(t, &webSocketFrame{fin: true, opcode: wsOpClose, payload: []byte{0x03, 0xE8}}, frame)
+}
+
+func TestWebSocketLimits(t *testing.T) {
+	payload, err := tests.LoadValidData("error")
+	assert.NoError(t, err)
+	var line bytes.Buffer
+	assert.NoError(t, json.Compact(&line, payload))
+
+	config := defaultConfig
+	config.Frontend = &FrontendConfig{Enabled: new(bool), RateLimit: 1, RateLimiter: defaultConfig.Frontend.RateLimiter, AllowOrigins: []string{"*"}, MaxUnzippedSize: 1024 * 1024, WebSocket: true}
+	*config.Frontend.Enabled = true
+	config.Frontend.RateLimiter.BurstMultiplier = 1
+	mux := newMuxer(config, func(events []beat.Event) error { return nil })
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// the rate limit applies per IP address, not per connection
+	conn, r := dialWebSocket(t, server.URL)
+	defer conn.Close()
+	_, err = conn.Write(encodeWebSocketFrame(wsOpText, line.Bytes(), testMask))
+	assert.NoError(t, err)
+	// the pong is sent once the payload is processed
+	_, err = conn.Write(encodeWebSocketFrame(wsOpPing, nil, testMask))
+	assert.NoError(t, err)
+	frame, err := readWebSocketFrame(r, 1024)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(wsOpPong), frame.opcode)
+
+	other, otherR := dialWebSocket(t, server.URL)
+	defer other.Close()
+	_, err = other.Write(encodeWebSocketFrame(wsOpText, line.Bytes(), testMask))
+	assert.NoError(t, err)
+	frame, err = readWebSocketFrame(otherR, 1024)
+	assert.NoError(t, err)
+	var body map[string]interface{}
+	assert.NoError(t, json.Unmarshal(frame.payload, &body))
+	assert.Equal(t, "ERR_RATE_LIMITED", body["code"])
+
+	// open connections are closed once the server stops
+	mux.webSockets.closeAll()
+	_, err = readWebSocketFrame(r, 1024)
+	assert.Error(t, err)
+	_, err = readWebSocketFrame(otherR, 1024)
+	assert.Error(t, err)
+}
+
+func TestWebSocketHandshake(t *testing.T) {
+	config := defaultConfig
+	config.Frontend = &FrontendConfig{Enabled: new(bool), AllowOrigins: []string{"http://example.com"}, WebSocket: true}
+	*config.Frontend.Enabled = true
+	h := webSocketHandler(config, newWebSocketConns(), nil, nil, func(string) reporter { return nil })
+
+	req, err := http.NewRequest("GET", FrontendWebSocketURL, nil)
+	assert.NoError(t, err)
+	req.Header.Set("Origin", "http://example.com")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	req.Header.Set("Origin", "http://evil.com")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestWebSocketFrames(t *testing.T) {
+	for _, size := range []int{0, 125, 126, 65535, 65536} {
+		payload := bytes.Repeat([]byte("a"), size)
+		frame, err := readWebSocketFrame(bytes.NewReader(encodeWebSocketFrame(wsOpBinary, payload, testMask)), 1<<20)
+		assert.NoError(t, err)
+		assert.Equal(t, &webSocketFrame{fin: true, opcode: wsOpBinary, masked: true, payload: payload}, frame)
+	}
+
+	_, err := readWebSocketFrame(bytes.NewReader(encodeWebSocketFrame(wsOpText, []byte("too large"), nil)), 4)
+	assert.Equal(t, errRequestTooLarge, err)
+}
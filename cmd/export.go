@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+
+	"github.com/elastic/apm-server/beater"
+	"github.com/elastic/beats/libbeat/cmd/instance"
+)
+
+// genExportConfigCmd replaces the libbeat `export config` command, which
+// prints the config as loaded. The apm-server settings are printed with
+// their defaults resolved, and secrets are redacted.
+func genExportConfigCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "config",
+		Short: "Export current config, with defaults resolved and secrets redacted, to stdout",
+		Run: func(cmd *cobra.Command, args []string) {
+			b, err := instance.NewBeat(Name, IdxPattern, "")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error initializing beat: %s\n", err)
+				os.Exit(1)
+			}
+			if err := b.Init(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error initializing beat: %s\n", err)
+				os.Exit(1)
+			}
+			settings, err := beater.EffectiveConfig(b.RawConfig)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading config: %s\n", err)
+				os.Exit(1)
+			}
+			res, err := yaml.Marshal(settings)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error converting config to YAML format: %s\n", err)
+				os.Exit(1)
+			}
+			os.Stdout.Write(res)
+		},
+	}
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/elastic/apm-server/beater"
+	"github.com/elastic/beats/libbeat/cfgfile"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func genKeystoreCmd() *cobra.Command {
+	keystoreCmd := &cobra.Command{
+		Use:   "keystore",
+		Short: "Manage secrets referenced in the config",
+	}
+
+	var force bool
+	addCmd := &cobra.Command{
+		Use:   "add KEY",
+		Short: "Add a secret, read from stdin, to reference as ${KEY} in the config",
+		Args:  cobra.ExactArgs(1),
+		Run: runKeystoreCmd(func(k *beater.Keystore, args []string) error {
+			key := args[0]
+			if k.Has(key) && !force {
+				return fmt.Errorf("key %s already exists, use --force to overwrite it", key)
+			}
+			value, err := readSecret(key)
+			if err != nil {
+				return err
+			}
+			if err := k.Set(key, value); err != nil {
+				return err
+			}
+			return k.Save()
+		}),
+	}
+	addCmd.Flags().BoolVar(&force, "force", false, "Overwrite an existing key")
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the keys of the stored secrets",
+		Args:  cobra.NoArgs,
+		Run: runKeystoreCmd(func(k *beater.Keystore, _ []string) error {
+			for _, key := range k.Keys() {
+				fmt.Println(key)
+			}
+			return nil
+		}),
+	}
+
+	removeCmd := &cobra.Command{
+		Use:   "remove KEY",
+		Short: "Remove a secret",
+		Args:  cobra.ExactArgs(1),
+		Run: runKeystoreCmd(func(k *beater.Keystore, args []string) error {
+			if !k.Has(args[0]) {
+				return fmt.Errorf("key %s not found", args[0])
+			}
+			k.Remove(args[0])
+			return k.Save()
+		}),
+	}
+
+	keystoreCmd.AddCommand(addCmd, listCmd, removeCmd)
+	return keystoreCmd
+}
+
+func runKeystoreCmd(f func(*beater.Keystore, []string) error) func(*cobra.Command, []string) {
+	return func(_ *cobra.Command, args []string) {
+		cfg, err := cfgfile.Load("")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading config: %s\n", err)
+			os.Exit(1)
+		}
+		path, err := keystorePath(cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error locating keystore: %s\n", err)
+			os.Exit(1)
+		}
+		k, err := beater.OpenKeystore(path)
+		if err == nil {
+			err = f(k, args)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// keystorePath returns the location of the keystore in the data path,
+// creating the data path if needed.
+func keystorePath(cfg *common.Config) (string, error) {
+	data, err := cfg.String("path.data", -1)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(data, 0750); err != nil {
+		return "", err
+	}
+	return filepath.Join(data, beater.KeystoreFile), nil
+}
+
+// readSecret reads the value of a secret from stdin, prompting for it if
+// stdin is a terminal.
+func readSecret(key string) (string, error) {
+	var value string
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		fmt.Fprintf(os.Stderr, "Enter value for %s: ", key)
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		value = line
+	} else {
+		buf, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return "", err
+		}
+		value = string(buf)
+	}
+	value = strings.TrimSuffix(strings.TrimSuffix(value, "\n"), "\r")
+	if value == "" {
+		return "", errors.New("empty value")
+	}
+	return value, nil
+}
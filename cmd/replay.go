@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/elastic/apm-server/beater"
+	"github.com/elastic/beats/libbeat/cmd/instance"
+)
+
+func genReplayCmd() *cobra.Command {
+	var route string
+	var rate float64
+
+	replayCmd := &cobra.Command{
+		Use:   "replay <dir|file>",
+		Short: "Replay recorded requests or ndjson payloads",
+		Long: "Push recorded requests, or a file with one JSON payload per line, through\n" +
+			"the processing pipeline and publish the events to the configured output.",
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := instance.Run(Name, IdxPattern, "", unsetKeystoreEnv(beater.NewReplayer(args[0], route, rate)))
+			if err != nil {
+				os.Exit(1)
+			}
+		},
+	}
+	replayCmd.Flags().StringVar(&route, "route", "", "Route the payloads of a file are sent to, e.g. "+beater.BackendTransactionsURL)
+	replayCmd.Flags().Float64Var(&rate, "rate", 0, "Maximum number of payloads replayed per second, 0 for no limit")
+	return replayCmd
+}
@@ -19,5 +19,15 @@ var RootCmd *cmd.BeatsRootCmd
 
 func init() {
 	var runFlags = pflag.NewFlagSet(Name, pflag.ExitOnError)
-	RootCmd = cmd.GenRootCmdWithIndexPrefixWithRunFlags(Name, IdxPattern, "", beater.New, runFlags)
+	RootCmd = cmd.GenRootCmdWithIndexPrefixWithRunFlags(Name, IdxPattern, "", unsetKeystoreEnv(beater.New), runFlags)
+	RootCmd.AddCommand(genReplayCmd())
+	RootCmd.AddCommand(genKeystoreCmd())
+	RootCmd.TestCmd.AddCommand(genTestKibanaCmd())
+	for _, c := range RootCmd.ExportCmd.Commands() {
+		if c.Name() == "config" {
+			RootCmd.ExportCmd.RemoveCommand(c)
+		}
+	}
+	RootCmd.ExportCmd.AddCommand(genExportConfigCmd())
+	RootCmd.PersistentPreRunE = applyLibbeatSettings
 }
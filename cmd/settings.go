@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"github.com/elastic/apm-server/beater"
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/cfgfile"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// keystore holds the secrets made available to the config, until the beater
+// is run.
+var keystore *beater.Keystore
+
+// applyLibbeatSettings passes the libbeat settings derived from the
+// apm-server config to libbeat as -E flags: output and queue settings from
+// apm-server.pipeline, and setup.kibana from apm-server.kibana. libbeat
+// creates the publisher pipeline before the beater is created, so the
+// settings need to be in place before the configuration is loaded. The
+// secrets in the keystore are made available to the config first.
+func applyLibbeatSettings(_ *cobra.Command, _ []string) error {
+	if err := cfgfile.ChangeDefaultCfgfileFlag(Name); err != nil {
+		return err
+	}
+	if err := cfgfile.HandleFlags(); err != nil {
+		return err
+	}
+	cfg, err := cfgfile.Load("")
+	if err != nil {
+		// reported by libbeat for the commands needing the config
+		return nil
+	}
+	// secrets in the keystore are referenced like environment variables,
+	// they need to be available before any setting is read
+	if path, err := keystorePath(cfg); err == nil {
+		if keystore, err = beater.OpenKeystore(path); err != nil {
+			return err
+		}
+		if err := keystore.SetEnv(); err != nil {
+			return err
+		}
+	}
+	settings := map[string]interface{}{}
+	for _, derive := range []func(*common.Config) (map[string]interface{}, error){
+		beater.PipelineSettings,
+		beater.KibanaSettings,
+	} {
+		derived, err := derive(cfg)
+		if err != nil {
+			return err
+		}
+		for name, value := range derived {
+			settings[name] = value
+		}
+	}
+
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		// JSON encoded values are parsed back as the same type, e.g. for
+		// strings containing colons or lists of certificates
+		value, err := json.Marshal(settings[name])
+		if err != nil {
+			return err
+		}
+		if err := flag.Set("E", fmt.Sprintf("%s=%s", name, value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unsetKeystoreEnv wraps a beat.Creator, removing the secrets of the
+// keystore from the environment once the beat is run. libbeat reads the
+// config until then, e.g. for the monitoring output.
+func unsetKeystoreEnv(creator beat.Creator) beat.Creator {
+	return func(b *beat.Beat, cfg *common.Config) (beat.Beater, error) {
+		bt, err := creator(b, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return &keystoreBeater{bt}, nil
+	}
+}
+
+type keystoreBeater struct {
+	beat.Beater
+}
+
+func (bt *keystoreBeater) Run(b *beat.Beat) error {
+	if keystore != nil {
+		keystore.UnsetEnv()
+	}
+	return bt.Beater.Run(b)
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/elastic/apm-server/beater"
+	"github.com/elastic/beats/libbeat/cfgfile"
+)
+
+// genTestKibanaCmd tests the connection to Kibana, next to the libbeat
+// `test config` and `test output` commands.
+func genTestKibanaCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "kibana",
+		Short: "Test " + Name + " can connect to Kibana by using the current settings",
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg, err := cfgfile.Load("")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error loading config: %s\n", err)
+				os.Exit(1)
+			}
+			version, err := beater.TestKibana(cfg)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error connecting to Kibana: %s\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Kibana OK, version %s\n", version)
+		},
+	}
+}
@@ -0,0 +1,80 @@
+package processor
+
+import (
+	"fmt"
+
+	m "github.com/elastic/apm-server/processor/model"
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// Metadata is the information sent once per payload, shared by all events
+// of the payload.
+type Metadata struct {
+	App    *m.App
+	System *m.System
+}
+
+// Enricher adds fields to events. It is called with every decoded event, e.g.
+// a *transaction.Event, before the event is transformed into a document, and
+// returns the fields to put into that document. Keys can contain dots to
+// address nested fields. Enrichers allow to add organization specific fields
+// without changing the models.
+type Enricher interface {
+	Enrich(event interface{}, meta Metadata) common.MapStr
+}
+
+// EnricherConstructor creates an enricher from its config.
+type EnricherConstructor func(*common.Config) (Enricher, error)
+
+var enricherRegistry = map[string]EnricherConstructor{}
+
+// RegisterEnricher makes an enricher available under the given name. It is
+// meant to be called from init functions.
+func RegisterEnricher(name string, c EnricherConstructor) {
+	if _, exists := enricherRegistry[name]; exists {
+		panic(fmt.Sprintf("enricher '%s' is already registered", name))
+	}
+	enricherRegistry[name] = c
+}
+
+// NewEnrichers creates the enrichers configured by name, in order.
+func NewEnrichers(config []map[string]*common.Config) ([]Enricher, error) {
+	var list []Enricher
+	for _, entry := range config {
+		for name, cfg := range entry {
+			constructor, ok := enricherRegistry[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown enricher '%s'", name)
+			}
+			e, err := constructor(cfg)
+			if err != nil {
+				return nil, fmt.Errorf("error creating enricher '%s': %v", name, err)
+			}
+			list = append(list, e)
+		}
+	}
+	return list, nil
+}
+
+// Enrich returns the fields the enrichers add for the decoded event. It is
+// called before the event is transformed, so enrichers see the event as sent
+// by the agent.
+func Enrich(enrichers []Enricher, event interface{}, meta Metadata) common.MapStr {
+	fields := common.MapStr{}
+	for _, e := range enrichers {
+		for key, value := range e.Enrich(event, meta) {
+			fields[key] = value
+		}
+	}
+	return fields
+}
+
+// AddFields puts the fields returned by Enrich into the document created for
+// the event.
+func AddFields(doc beat.Event, fields common.MapStr) beat.Event {
+	for key, value := range fields {
+		doc.Fields.Put(key, value)
+	}
+	return doc
+}
@@ -0,0 +1,55 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	m "github.com/elastic/apm-server/processor/model"
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+type eventNameEnricher struct {
+	key string
+}
+
+func (e *eventNameEnricher) Enrich(event interface{}, meta Metadata) common.MapStr {
+	return common.MapStr{e.key: event, "labels.app": meta.App.Name}
+}
+
+func TestNewEnrichers(t *testing.T) {
+	RegisterEnricher("test_event_name", func(c *common.Config) (Enricher, error) {
+		config := struct {
+			Key string `config:"key"`
+		}{}
+		if err := c.Unpack(&config); err != nil {
+			return nil, err
+		}
+		return &eventNameEnricher{key: config.Key}, nil
+	})
+	assert.Panics(t, func() { RegisterEnricher("test_event_name", nil) })
+
+	cfg, err := common.NewConfigFrom(map[string]interface{}{"key": "labels.event"})
+	assert.NoError(t, err)
+	list, err := NewEnrichers([]map[string]*common.Config{{"test_event_name": cfg}})
+	assert.NoError(t, err)
+	assert.Equal(t, []Enricher{&eventNameEnricher{key: "labels.event"}}, list)
+
+	_, err = NewEnrichers([]map[string]*common.Config{{"unknown": cfg}})
+	assert.EqualError(t, err, "unknown enricher 'unknown'")
+}
+
+func TestEnrich(t *testing.T) {
+	meta := Metadata{App: &m.App{Name: "app"}}
+	doc := beat.Event{Fields: common.MapStr{"labels": common.MapStr{"a": "b"}}}
+
+	fields := Enrich(nil, "event", meta)
+	assert.Equal(t, common.MapStr{}, fields)
+	assert.Equal(t, common.MapStr{"labels": common.MapStr{"a": "b"}}, AddFields(doc, fields).Fields)
+
+	fields = Enrich([]Enricher{&eventNameEnricher{key: "labels.event"}}, "event", meta)
+	assert.Equal(t, common.MapStr{"labels.event": "event", "labels.app": "app"}, fields)
+	assert.Equal(t, common.MapStr{"labels": common.MapStr{"a": "b", "event": "event", "app": "app"}},
+		AddFields(doc, fields).Fields)
+}
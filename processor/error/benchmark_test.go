@@ -3,11 +3,12 @@ package error
 import (
 	"testing"
 
+	pr "github.com/elastic/apm-server/processor"
 	"github.com/elastic/apm-server/tests"
 )
 
 func BenchmarkEventWithFileLoading(b *testing.B) {
-	processor := NewProcessor()
+	processor := NewProcessor(pr.DefaultConfig())
 	for i := 0; i < b.N; i++ {
 		data, _ := tests.LoadValidData("error")
 		err := processor.Validate(data)
@@ -20,7 +21,7 @@ func BenchmarkEventWithFileLoading(b *testing.B) {
 }
 
 func BenchmarkEventFileLoadingOnce(b *testing.B) {
-	processor := NewProcessor()
+	processor := NewProcessor(pr.DefaultConfig())
 	data, _ := tests.LoadValidData("error")
 	for i := 0; i < b.N; i++ {
 		err := processor.Validate(data)
@@ -31,3 +32,7 @@ func BenchmarkEventFileLoadingOnce(b *testing.B) {
 		processor.Transform(data)
 	}
 }
+
+func BenchmarkProcessorAllocs(b *testing.B) {
+	tests.BenchmarkProcessor(b, NewProcessor, "error", tests.AllocLimits{Validate: 2000, Transform: 2500})
+}
@@ -3,7 +3,7 @@ package error
 import (
 	"crypto/md5"
 	"encoding/hex"
-	"fmt"
+	"encoding/json"
 	"io"
 	"strconv"
 
@@ -25,16 +25,22 @@ type Event struct {
 	enhancer            utility.MapStrEnhancer
 	data                common.MapStr
 	TransformStacktrace m.TransformStacktrace
+
+	// maxCauseDepth limits how many levels of causes of an exception are
+	// kept, it is set from the processor config.
+	maxCauseDepth int
 }
 
 type Exception struct {
 	Code             interface{}        `json:"code"`
 	Message          string             `json:"message"`
 	Module           *string            `json:"module"`
-	Attributes       interface{}        `json:"attributes"`
+	Attributes       common.MapStr      `json:"attributes"`
 	StacktraceFrames m.StacktraceFrames `json:"stacktrace"`
 	Type             *string            `json:"type"`
 	Uncaught         *bool              `json:"uncaught"`
+	Handled          *bool              `json:"handled"`
+	Cause            []Exception        `json:"cause"`
 }
 
 type Log struct {
@@ -56,7 +62,7 @@ func (e *Event) Mappings(pa *payload) (time.Time, []m.DocMapping) {
 				return common.MapStr{"name": processorName, "event": e.DocType()}
 			}},
 			{Key: e.DocType(), Apply: e.Transform},
-			{Key: "context", Apply: func() common.MapStr { return e.Context }},
+			{Key: "context", Apply: func() common.MapStr { return m.TransformContext(e.Context) }},
 			{Key: "context.app", Apply: pa.App.Transform},
 			{Key: "context.system", Apply: pa.System.Transform},
 		}
@@ -80,25 +86,79 @@ func (e *Event) addException() {
 	if e.Exception == nil {
 		return
 	}
+	e.add("exception", e.transformException(e.Exception, 0))
+	if handled := e.Exception.handled(); handled != nil {
+		if *handled {
+			e.add("outcome", "handled")
+		} else {
+			e.add("outcome", "unhandled")
+		}
+	}
+}
+
+func (e *Event) transformException(exception *Exception, depth int) common.MapStr {
 	ex := common.MapStr{}
-	e.enhancer.Add(ex, "message", e.Exception.Message)
-	e.enhancer.Add(ex, "module", e.Exception.Module)
-	e.enhancer.Add(ex, "attributes", e.Exception.Attributes)
-	e.enhancer.Add(ex, "type", e.Exception.Type)
-	e.enhancer.Add(ex, "uncaught", e.Exception.Uncaught)
+	e.enhancer.Add(ex, "message", exception.Message)
+	e.enhancer.Add(ex, "module", exception.Module)
+	e.enhancer.Add(ex, "attributes", exception.Attributes)
+	e.enhancer.Add(ex, "type", exception.Type)
+	if handled := exception.handled(); handled != nil {
+		ex["handled"] = *handled
+		ex["uncaught"] = !*handled
+	}
+	e.enhancer.Add(ex, "code", exception.code())
 
-	switch e.Exception.Code.(type) {
+	e.addStacktrace(ex, exception.StacktraceFrames)
+
+	if causes := e.causes(exception, depth); len(causes) > 0 {
+		cause := make([]common.MapStr, len(causes))
+		for i := range causes {
+			cause[i] = e.transformException(&causes[i], depth+1)
+		}
+		e.enhancer.Add(ex, "cause", cause)
+	}
+	return ex
+}
+
+// code returns the exception code as string, agents send either strings or
+// numbers.
+func (ex *Exception) code() *string {
+	var code string
+	switch c := ex.Code.(type) {
 	case int:
-		e.enhancer.Add(ex, "code", strconv.Itoa(e.Exception.Code.(int)))
+		code = strconv.Itoa(c)
 	case float64:
-		e.enhancer.Add(ex, "code", fmt.Sprintf("%.0f", e.Exception.Code))
+		code = strconv.FormatFloat(c, 'f', -1, 64)
+	case json.Number:
+		code = c.String()
 	case string:
-		e.enhancer.Add(ex, "code", e.Exception.Code.(string))
+		code = c
+	default:
+		return nil
 	}
+	return &code
+}
 
-	e.addStacktrace(ex, e.Exception.StacktraceFrames)
+// handled returns whether the exception was handled by the app. Agents send
+// either handled or uncaught, handled takes precedence if both are sent.
+func (ex *Exception) handled() *bool {
+	if ex.Handled != nil {
+		return ex.Handled
+	}
+	if ex.Uncaught != nil {
+		handled := !*ex.Uncaught
+		return &handled
+	}
+	return nil
+}
 
-	e.add("exception", ex)
+// causes returns the causes of an exception at the given depth, none if
+// they would exceed the max cause depth.
+func (e *Event) causes(ex *Exception, depth int) []Exception {
+	if depth >= e.maxCauseDepth {
+		return nil
+	}
+	return ex.Cause
 }
 
 func (e *Event) addLog() {
@@ -162,9 +222,28 @@ func (e *Event) calcGroupingKey() string {
 		}
 	}
 
-	for _, st := range frames {
-		addEither(st.Module, st.Filename)
-		addEither(st.Function, string(st.Lineno))
+	addFrames := func(frames m.StacktraceFrames) {
+		for _, st := range frames {
+			addEither(st.Module, st.Filename)
+			addEither(st.Function, string(st.Lineno))
+		}
+	}
+
+	// causes are grouped on like the exception itself, so that the same
+	// exception wrapping different causes ends up in different groups
+	var addCauses func(ex *Exception, depth int)
+	addCauses = func(ex *Exception, depth int) {
+		causes := e.causes(ex, depth)
+		for i := range causes {
+			add(causes[i].Type)
+			addFrames(causes[i].StacktraceFrames)
+			addCauses(&causes[i], depth+1)
+		}
+	}
+
+	addFrames(frames)
+	if e.Exception != nil {
+		addCauses(e.Exception, 0)
 	}
 
 	return hex.EncodeToString(hash.Sum(nil))
@@ -3,6 +3,7 @@ package error
 import (
 	"crypto/md5"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"testing"
 
@@ -33,6 +34,13 @@ func (e *Exception) withFrames(frames []m.StacktraceFrame) *Exception {
 	return e
 }
 
+func (e *Exception) withCause(causes ...*Exception) *Exception {
+	for _, c := range causes {
+		e.Cause = append(e.Cause, *c)
+	}
+	return e
+}
+
 func baseLog() *Log {
 	return &Log{Message: "error log message"}
 }
@@ -156,6 +164,7 @@ func TestEventTransform(t *testing.T) {
 					"attributes": common.MapStr{"k1": "val1"},
 					"type":       "error type",
 					"uncaught":   true,
+					"handled":    false,
 				},
 				"log": common.MapStr{
 					"message":       "error log message",
@@ -164,6 +173,7 @@ func TestEventTransform(t *testing.T) {
 					"level":         "level",
 				},
 				"grouping_key": "d47ca09e1cfd512804f5d55cecd34262",
+				"outcome":      "unhandled",
 			},
 			Msg: "Full Event with frames",
 		},
@@ -361,6 +371,76 @@ func TestGroupableEvents(t *testing.T) {
 	}
 }
 
+func TestExceptionCode(t *testing.T) {
+	for code, expected := range map[interface{}]string{
+		"E42":             "E42",
+		13:                "13",
+		13.0:              "13",
+		1.5:               "1.5",
+		4294967296.0:      "4294967296",
+		json.Number("-2"): "-2",
+	} {
+		ex := baseException().withCode(code)
+		assert.Equal(t, expected, *ex.code(), fmt.Sprintf("%v", code))
+	}
+	assert.Nil(t, baseException().code())
+}
+
+func TestExceptionHandled(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		Exception *Exception
+		Handled   interface{}
+		Uncaught  interface{}
+		Outcome   interface{}
+	}{
+		{Exception: baseException()},
+		{Exception: &Exception{Handled: &yes}, Handled: true, Uncaught: false, Outcome: "handled"},
+		{Exception: &Exception{Handled: &no}, Handled: false, Uncaught: true, Outcome: "unhandled"},
+		{Exception: &Exception{Uncaught: &yes}, Handled: false, Uncaught: true, Outcome: "unhandled"},
+		{Exception: &Exception{Uncaught: &yes, Handled: &yes}, Handled: true, Uncaught: false, Outcome: "handled"},
+	}
+	for idx, test := range tests {
+		e := Event{Exception: test.Exception}
+		output := e.Transform()
+		ex := output["exception"].(common.MapStr)
+		assert.Equal(t, test.Handled, ex["handled"], fmt.Sprintf("Failed at idx %v", idx))
+		assert.Equal(t, test.Uncaught, ex["uncaught"], fmt.Sprintf("Failed at idx %v", idx))
+		assert.Equal(t, test.Outcome, output["outcome"], fmt.Sprintf("Failed at idx %v", idx))
+	}
+}
+
+func TestExceptionCauseTransform(t *testing.T) {
+	e := Event{maxCauseDepth: 1, Exception: baseException().withType("outer").withCause(
+		baseException().withType("inner").withCause(baseException().withType("root")),
+		baseException().withCode(42),
+	)}
+	ex := e.Transform()["exception"].(common.MapStr)
+	assert.Equal(t, []common.MapStr{
+		{"message": "exception message", "type": "inner"},
+		{"message": "exception message", "code": "42"},
+	}, ex["cause"])
+}
+
+func TestExceptionCauseGroupingKey(t *testing.T) {
+	function := "function"
+	e := Event{maxCauseDepth: 1, Exception: baseException().withType("outer").withCause(
+		baseException().withType("inner").withFrames([]m.StacktraceFrame{{Function: &function}}).withCause(
+			baseException().withType("root"),
+		),
+	)}
+	assert.Equal(t, hex.EncodeToString(md5With("outer", "inner", function)), e.calcGroupingKey())
+
+	// causes beyond the max depth don't affect grouping
+	e2 := Event{maxCauseDepth: 1, Exception: baseException().withType("outer").withCause(
+		baseException().withType("inner").withFrames([]m.StacktraceFrame{{Function: &function}}),
+	)}
+	assert.Equal(t, e.calcGroupingKey(), e2.calcGroupingKey())
+
+	e3 := Event{maxCauseDepth: 1, Exception: baseException().withType("outer").withCause(baseException().withType("other"))}
+	assert.NotEqual(t, e.calcGroupingKey(), e3.calcGroupingKey())
+}
+
 func md5With(args ...string) []byte {
 	md5 := md5.New()
 	for _, arg := range args {
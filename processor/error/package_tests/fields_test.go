@@ -23,6 +23,22 @@ func TestFields(t *testing.T) {
 		"context.db.type",
 		"context.db",
 		"listening",
+		"onboarding.first_seen",
+		"context.truncated",
+		"context.tags_flattened",
+		"context.custom_flattened",
+		"observer",
+		"observer.hostname",
+		"observer.version",
+		"observer.id",
+		"observer.type",
+		"observer.listening",
+		"observer.request_id",
+		"tenant",
+		"tenant.id",
+		"observer.ingest_timestamp",
+		"observer.clock_skew",
+		"observer.clock_skew.us",
 		"error id icon",
 		"view errors",
 	)
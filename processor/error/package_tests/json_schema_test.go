@@ -16,12 +16,17 @@ func TestPayloadAttributesInSchema(t *testing.T) {
 		"errors.log.stacktrace.vars.key",
 		"errors.exception.stacktrace.vars.key",
 		"errors.exception.attributes.foo",
+		"errors.exception.cause.stacktrace.vars.key",
+		"errors.exception.cause.attributes.foo",
+		"errors.exception.cause.cause.message",
+		"errors.exception.cause.cause.type",
 		"errors.context.custom.my_key",
 		"errors.context.custom.some_other_value",
 		"errors.context.custom.and_objects",
 		"errors.context.custom.and_objects.foo",
 		"errors.context.request.headers.some-other-header",
 		"errors.context.request.headers.array",
+		"errors.context.message.headers.content-type",
 		"errors.context.request.env.SERVER_SOFTWARE",
 		"errors.context.request.env.GATEWAY_INTERFACE",
 		"errors.context.request.cookies.c1",
@@ -42,7 +47,17 @@ func TestJsonSchemaKeywordLimitation(t *testing.T) {
 		"error.id",
 		"error.log.level",
 		"error.grouping_key",
+		"error.outcome",
 		"listening",
+		"context.truncated",
+		"context.tags_flattened",
+		"observer.hostname",
+		"observer.version",
+		"observer.id",
+		"observer.type",
+		"observer.listening",
+		"observer.request_id",
+		"tenant.id",
 		"error id icon",
 		"view errors",
 	)
@@ -4,6 +4,7 @@ import (
 	pr "github.com/elastic/apm-server/processor"
 	m "github.com/elastic/apm-server/processor/model"
 	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
 	"github.com/elastic/beats/libbeat/logp"
 	"github.com/elastic/beats/libbeat/monitoring"
 )
@@ -18,14 +19,25 @@ type payload struct {
 	Events []Event   `json:"errors"`
 }
 
-func (pa *payload) transform() []beat.Event {
+func (pa *payload) transform(config pr.Config) []beat.Event {
 	var events []beat.Event
 
 	logp.Debug("error", "Transform error events: events=%d, app=%s, agent=%s:%s", len(pa.Events), pa.App.Name, pa.App.Agent.Name, pa.App.Agent.Version)
 
 	errorCounter.Add(int64(len(pa.Events)))
-	for _, e := range pa.Events {
-		events = append(events, pr.CreateDoc(e.Mappings(pa)))
+	meta := pa.metadata()
+	fields := make([]common.MapStr, len(pa.Events))
+	for i := range pa.Events {
+		fields[i] = pr.Enrich(config.Enrichers, &pa.Events[i], meta)
+	}
+	for i := range pa.Events {
+		e := &pa.Events[i]
+		e.maxCauseDepth = config.MaxCauseDepth
+		events = append(events, pr.AddFields(pr.CreateDoc(e.Mappings(pa)), fields[i]))
 	}
 	return events
 }
+
+func (pa *payload) metadata() pr.Metadata {
+	return pr.Metadata{App: &pa.App, System: pa.System}
+}
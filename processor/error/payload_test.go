@@ -8,6 +8,7 @@ import (
 
 	"time"
 
+	pr "github.com/elastic/apm-server/processor"
 	m "github.com/elastic/apm-server/processor/model"
 	"github.com/elastic/beats/libbeat/common"
 )
@@ -76,7 +77,7 @@ func TestPayloadTransform(t *testing.T) {
 	}
 
 	for idx, test := range tests {
-		outputEvents := test.Payload.transform()
+		outputEvents := test.Payload.transform(pr.DefaultConfig())
 		for j, outputEvent := range outputEvents {
 			assert.Equal(t, test.Output[j], outputEvent.Fields, fmt.Sprintf("Failed at idx %v; %s", idx, test.Msg))
 			assert.Equal(t, timestamp, outputEvent.Timestamp, fmt.Sprintf("Bad timestamp at idx %v; %s", idx, test.Msg))
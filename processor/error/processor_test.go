@@ -6,12 +6,39 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	pr "github.com/elastic/apm-server/processor"
+	"github.com/elastic/beats/libbeat/common"
 )
 
 func TestImplementProcessorInterface(t *testing.T) {
-	p := NewProcessor()
+	p := NewProcessor(pr.DefaultConfig())
 	assert.NotNil(t, p)
 	_, ok := p.(pr.Processor)
 	assert.True(t, ok)
 	assert.IsType(t, &processor{}, p)
 }
+
+func TestProcessorConfig(t *testing.T) {
+	payload := []byte(`{"app": {"name": "app", "agent": {"name": "go", "version": "1.0"}},
+		"errors": [{"timestamp": "2017-05-30T18:53:27.154Z",
+		"exception": {"message": "outer", "cause": [{"message": "inner", "cause": [{"message": "root"}]}]}}]}`)
+
+	for depth, expected := range map[int]interface{}{
+		0: nil,
+		1: []common.MapStr{{"message": "inner"}},
+	} {
+		p := NewProcessor(pr.Config{MaxCauseDepth: depth, Enrichers: []pr.Enricher{causeEnricher{}}})
+		events, err := p.Transform(payload)
+		assert.NoError(t, err)
+		cause, _ := events[0].Fields.GetValue("error.exception.cause")
+		assert.Equal(t, expected, cause)
+		// the enricher is called with the decoded event
+		causes, _ := events[0].Fields.GetValue("labels.causes")
+		assert.Equal(t, 1, causes)
+	}
+}
+
+type causeEnricher struct{}
+
+func (causeEnricher) Enrich(event interface{}, _ pr.Metadata) common.MapStr {
+	return common.MapStr{"labels.causes": len(event.(*Event).Exception.Cause)}
+}
@@ -69,6 +69,16 @@ var errorSchema = `{
             "pattern": "^[a-zA-Z0-9 _-]+$",
             "maxLength": 1024
         },
+        "node": {
+            "type": ["object", "null"],
+            "properties": {
+                "configured_name": {
+                    "description": "Name of this instance of the app, set in the agent configuration. Used to distinguish multiple instances of the same app.",
+                    "type": ["string", "null"],
+                    "maxLength": 1024
+                }
+            }
+        },
         "pid": {
             "type": ["number", "null"]
         },
@@ -122,6 +132,95 @@ var errorSchema = `{
             },
             "additionalProperties": false
         },
+        "message": {
+            "description": "Details of the message the event was triggered by, if received from a messaging system like Kafka, RabbitMQ or SQS.",
+            "type": ["object", "null"],
+            "properties": {
+                "queue": {
+                    "type": ["object", "null"],
+                    "properties": {
+                        "name": {
+                            "description": "Name of the queue or topic the message was received from.",
+                            "type": ["string", "null"],
+                            "maxLength": 1024
+                        }
+                    }
+                },
+                "routing_key": {
+                    "description": "Routing key of the message, as used by RabbitMQ.",
+                    "type": ["string", "null"],
+                    "maxLength": 1024
+                },
+                "age": {
+                    "type": ["object", "null"],
+                    "properties": {
+                        "ms": {
+                            "description": "Time elapsed between sending the message and receiving it, in ms.",
+                            "type": ["integer", "null"]
+                        }
+                    }
+                },
+                "body": {
+                    "description": "The message body.",
+                    "type": ["string", "null"]
+                },
+                "headers": {
+                    "description": "The message headers.",
+                    "type": ["object", "null"]
+                }
+            }
+        },
+        "network": {
+            "description": "Network connection of the device the event was recorded on, sent by RUM and mobile agents.",
+            "type": ["object", "null"],
+            "properties": {
+                "connection": {
+                    "type": ["object", "null"],
+                    "properties": {
+                        "type": {
+                            "description": "Type of the connection.",
+                            "type": ["string", "null"],
+                            "enum": ["wifi", "cell", "wired", "unavailable", "unknown", null],
+                            "maxLength": 1024
+                        },
+                        "subtype": {
+                            "description": "Subtype of the connection, e.g. the radio technology like 'LTE' or '5G' for cell connections.",
+                            "type": ["string", "null"],
+                            "maxLength": 1024
+                        },
+                        "carrier": {
+                            "description": "Mobile network operator of cell connections.",
+                            "type": ["object", "null"],
+                            "properties": {
+                                "name": {
+                                    "description": "Name of the carrier.",
+                                    "type": ["string", "null"],
+                                    "maxLength": 1024
+                                },
+                                "mcc": {
+                                    "description": "Mobile country code.",
+                                    "type": ["string", "null"],
+                                    "pattern": "^[0-9]{3}$",
+                                    "maxLength": 1024
+                                },
+                                "mnc": {
+                                    "description": "Mobile network code.",
+                                    "type": ["string", "null"],
+                                    "pattern": "^[0-9]{2,3}$",
+                                    "maxLength": 1024
+                                },
+                                "icc": {
+                                    "description": "ISO 3166-1 alpha-2 country code of the carrier.",
+                                    "type": ["string", "null"],
+                                    "pattern": "^[a-zA-Z]{2}$",
+                                    "maxLength": 1024
+                                }
+                            }
+                        }
+                    }
+                }
+            }
+        },
         "response": {
             "type": ["object", "null"],
             "properties": {
@@ -141,6 +240,10 @@ var errorSchema = `{
                 },
                 "status_code": {
                     "type": ["number", "null"]
+                },
+                "transfer_size": {
+                    "description": "Size of the response in bytes, including headers.",
+                    "type": ["integer", "null"]
                 }
             }
         },
@@ -241,15 +344,12 @@ var errorSchema = `{
     "required": ["url", "method"]
         },
         "tags": {
+            "description": "A flat mapping of user-defined tags with string, boolean or number values. Dots, asterisks and double quotes in keys are replaced by underscores.",
             "type": ["object", "null"],
-            "regexProperties": true,
-            "patternProperties": {
-                "^[^.*\"]*$": {
-                    "type": "string",
-                    "maxLength": 1024
-                }
-            },
-            "additionalProperties": false
+            "additionalProperties": {
+                "type": ["string", "boolean", "number"],
+                "maxLength": 1024
+            }
         },
         "user": {
                 "$schema": "http://json-schema.org/draft-04/schema#",
@@ -363,7 +463,104 @@ var errorSchema = `{
                     "maxLength": 1024
                 },
                 "uncaught": {
+                    "description": "Indicator whether the error was caught somewhere in the code or not.",
+                    "type": ["boolean", "null"]
+                },
+                "handled": {
+                    "description": "Indicator whether the error was handled by the app. The inverse of uncaught, which it takes precedence over if both are sent.",
                     "type": ["boolean", "null"]
+                },
+                "cause": {
+                    "description": "Exceptions that caused this exception, e.g. the exceptions it wraps. Causes can have causes themselves.",
+                    "type": ["array", "null"],
+                    "items": {
+                        "type": "object",
+                        "properties": {
+                            "code": {
+                                "type": ["string", "number", "null"]
+                            },
+                            "message": {
+                                "type": "string"
+                            },
+                            "module": {
+                                "type": ["string", "null"]
+                            },
+                            "attributes": {
+                                "type": ["object", "null"]
+                            },
+                            "stacktrace": {
+                                "type": ["array", "null"],
+                                "items": {
+                                        "$schema": "http://json-schema.org/draft-04/schema#",
+    "$id": "docs/spec/stacktrace.json",
+    "title": "Stacktrace",
+    "type": "object",
+    "description": "A stacktrace frame, contains various bits (most optional) describing the context of the frame",
+    "properties": {
+        "abs_path": {
+            "description": "The absolute path of the file involved in the stack frame",
+            "type": ["string", "null"]
+        },
+        "colno": {
+            "description": "Column number",
+            "type": ["number", "null"]
+        },
+        "context_line": {
+            "description": "The line of code part of the stack frame",
+            "type": ["string", "null"]
+        },
+        "filename": {
+            "description": "The relative filename of the code involved in the stack frame, used e.g. to do error checksumming",
+            "type": "string"
+        },
+        "function": {
+            "description": "The function involved in the stack frame",
+            "type": ["string", "null"]
+        },
+        "in_app": {
+            "type": ["boolean", "null"]
+        },
+        "lineno": {
+            "description": "The line number of code part of the stack frame, used e.g. to do error checksumming",
+            "type": "number"
+        },
+        "module": {
+            "description": "The module to which frame belongs to",
+            "type": ["string", "null"]
+        },
+        "post_context": {
+            "description": "The lines of code after the stack frame",
+            "type": ["array", "null"],
+            "minItems": 0
+        },
+        "pre_context": {
+            "description": "The lines of code before the stack frame",
+             "type": ["array", "null"],
+            "minItems": 0
+        },
+        "vars": {
+            "description": "Local variables for this stack frame",
+            "type": ["object", "null"],
+            "properties": {}
+        }
+    },
+    "required": ["filename", "lineno"]
+                                },
+                                "minItems": 0
+                            },
+                            "type": {
+                                "type": ["string", "null"]
+                            },
+                            "cause": {
+                                "type": ["array", "null"],
+                                "items": {
+                                    "type": "object"
+                                }
+                            }
+                        },
+                        "required": ["message"]
+                    },
+                    "minItems": 0
                 }
             },
             "required": ["message"]
@@ -493,10 +690,27 @@ var errorSchema = `{
             "maxLength": 1024
         },
         "hostname": {
-            "description": "Hostname of the system the agent is running on.",
+            "description": "Hostname of the system the agent is running on. Deprecated, use detected_hostname and configured_hostname instead.",
+            "type": ["string", "null"],
+            "maxLength": 1024
+        },
+        "detected_hostname": {
+            "description": "Full hostname of the system the agent is running on, as detected by the agent.",
             "type": ["string", "null"],
             "maxLength": 1024
         },
+        "configured_hostname": {
+            "description": "Hostname of the system the agent is running on, as configured by the user.",
+            "type": ["string", "null"],
+            "maxLength": 1024
+        },
+        "detected_ip": {
+            "description": "IP addresses of the system the agent is running on, as detected by the agent. Invalid addresses are dropped.",
+            "type": ["array", "null"],
+            "items": {
+                "type": "string"
+            }
+        },
         "platform": {
             "description": "Name of the system platform the agent is running on.",
             "type": ["string", "null"],
@@ -0,0 +1,11 @@
+package log
+
+import (
+	"testing"
+
+	"github.com/elastic/apm-server/tests"
+)
+
+func BenchmarkProcessorAllocs(b *testing.B) {
+	tests.BenchmarkProcessor(b, NewProcessor, "log", tests.AllocLimits{Validate: 1200, Transform: 1800})
+}
@@ -0,0 +1,61 @@
+package log
+
+import (
+	"time"
+
+	m "github.com/elastic/apm-server/processor/model"
+	"github.com/elastic/apm-server/utility"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+type Event struct {
+	Message       string        `json:"message"`
+	Level         *string       `json:"level"`
+	LoggerName    *string       `json:"logger_name"`
+	Origin        *Origin       `json:"origin"`
+	TransactionId *string       `json:"transaction_id"`
+	TraceId       *int          `json:"trace_id"`
+	Context       common.MapStr `json:"context"`
+	Timestamp     time.Time     `json:"timestamp"`
+}
+
+type Origin struct {
+	File     *string `json:"file"`
+	Function *string `json:"function"`
+	Line     *int    `json:"line"`
+}
+
+func (e *Event) DocType() string {
+	return "log"
+}
+
+func (e *Event) Transform() common.MapStr {
+	enh := utility.NewMapStrEnhancer()
+	log := common.MapStr{"message": e.Message}
+	enh.Add(log, "level", e.Level)
+	enh.Add(log, "logger_name", e.LoggerName)
+	enh.Add(log, "transaction_id", e.TransactionId)
+	enh.Add(log, "trace_id", e.TraceId)
+
+	if e.Origin != nil {
+		origin := common.MapStr{}
+		enh.Add(origin, "file", e.Origin.File)
+		enh.Add(origin, "function", e.Origin.Function)
+		enh.Add(origin, "line", e.Origin.Line)
+		enh.Add(log, "origin", origin)
+	}
+	return log
+}
+
+func (e *Event) Mappings(pa *payload) (time.Time, []m.DocMapping) {
+	return e.Timestamp,
+		[]m.DocMapping{
+			{Key: "processor", Apply: func() common.MapStr {
+				return common.MapStr{"name": processorName, "event": e.DocType()}
+			}},
+			{Key: e.DocType(), Apply: e.Transform},
+			{Key: "context", Apply: func() common.MapStr { return m.TransformContext(e.Context) }},
+			{Key: "context.app", Apply: pa.App.Transform},
+			{Key: "context.system", Apply: pa.System.Transform},
+		}
+}
@@ -0,0 +1,59 @@
+package log
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestEventTransform(t *testing.T) {
+	level := "warning"
+	loggerName := "orders"
+	file := "lib/orders.js"
+	line := 12
+	transactionId := "945254c5-67a5-417e-8a4e-aa29efcbfb79"
+	traceId := 3
+
+	tests := []struct {
+		Event  Event
+		Output common.MapStr
+		Msg    string
+	}{
+		{
+			Event:  Event{Message: "message"},
+			Output: common.MapStr{"message": "message"},
+			Msg:    "Minimal event",
+		},
+		{
+			Event:  Event{Message: "message", Origin: &Origin{}},
+			Output: common.MapStr{"message": "message"},
+			Msg:    "Empty origin",
+		},
+		{
+			Event: Event{
+				Message:       "message",
+				Level:         &level,
+				LoggerName:    &loggerName,
+				Origin:        &Origin{File: &file, Line: &line},
+				TransactionId: &transactionId,
+				TraceId:       &traceId,
+			},
+			Output: common.MapStr{
+				"message":        "message",
+				"level":          "warning",
+				"logger_name":    "orders",
+				"origin":         common.MapStr{"file": "lib/orders.js", "line": 12},
+				"transaction_id": transactionId,
+				"trace_id":       3,
+			},
+			Msg: "Full event",
+		},
+	}
+
+	for idx, test := range tests {
+		output := test.Event.Transform()
+		assert.Equal(t, test.Output, output, "Failed at idx %v; %s", idx, test.Msg)
+	}
+}
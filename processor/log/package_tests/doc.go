@@ -0,0 +1 @@
+package package_tests
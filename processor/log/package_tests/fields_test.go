@@ -0,0 +1,44 @@
+package package_tests
+
+import (
+	"testing"
+
+	"github.com/fatih/set"
+
+	"github.com/elastic/apm-server/processor/log"
+	"github.com/elastic/apm-server/tests"
+)
+
+func TestFields(t *testing.T) {
+	fieldsPaths := []string{
+		"./../../../_meta/fields.common.yml",
+		"./../_meta/fields.yml",
+	}
+	tests.TestEventAttrsDocumentedInFields(t, fieldsPaths, log.NewProcessor)
+
+	notInEvent := set.New(
+		"context.db.instance",
+		"context.db.statement",
+		"context.db.user",
+		"context.db.type",
+		"context.db",
+		"listening",
+		"onboarding.first_seen",
+		"context.truncated",
+		"context.tags_flattened",
+		"context.custom_flattened",
+		"observer",
+		"observer.hostname",
+		"observer.version",
+		"observer.id",
+		"observer.type",
+		"observer.listening",
+		"observer.request_id",
+		"tenant",
+		"tenant.id",
+		"observer.ingest_timestamp",
+		"observer.clock_skew",
+		"observer.clock_skew.us",
+	)
+	tests.TestDocumentedFieldsInEvent(t, fieldsPaths, log.NewProcessor, notInEvent)
+}
@@ -0,0 +1,53 @@
+package package_tests
+
+import (
+	"testing"
+
+	"github.com/fatih/set"
+
+	"github.com/elastic/apm-server/processor/log"
+	"github.com/elastic/apm-server/tests"
+)
+
+//Check whether attributes are added to the example payload but not to the schema
+func TestPayloadAttributesInSchema(t *testing.T) {
+	//only add attributes that should not be documented by the schema
+	undocumented := set.New(
+		"logs.context.custom.my_key",
+		"logs.context.custom.some_other_value",
+		"logs.context.custom.and_objects",
+		"logs.context.custom.and_objects.foo",
+		"logs.context.request.headers.some-other-header",
+		"logs.context.request.headers.array",
+		"logs.context.message.headers.content-type",
+		"logs.context.request.env.SERVER_SOFTWARE",
+		"logs.context.request.env.GATEWAY_INTERFACE",
+		"logs.context.request.cookies.c1",
+		"logs.context.request.cookies.c2",
+		"logs.context.tags.organization_uuid",
+	)
+	tests.TestPayloadAttributesInSchema(t, "log", undocumented, log.Schema())
+}
+
+func TestJsonSchemaKeywordLimitation(t *testing.T) {
+	fieldsPaths := []string{
+		"./../../../_meta/fields.common.yml",
+		"./../_meta/fields.yml",
+	}
+	exceptions := set.New(
+		"processor.event",
+		"processor.name",
+		"log.transaction_id",
+		"listening",
+		"context.truncated",
+		"context.tags_flattened",
+		"observer.hostname",
+		"observer.version",
+		"observer.id",
+		"observer.type",
+		"observer.listening",
+		"observer.request_id",
+		"tenant.id",
+	)
+	tests.TestJsonSchemaKeywordLimitation(t, fieldsPaths, log.Schema(), exceptions)
+}
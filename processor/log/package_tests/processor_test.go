@@ -0,0 +1,32 @@
+package package_tests
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	pr "github.com/elastic/apm-server/processor"
+	"github.com/elastic/apm-server/processor/log"
+	"github.com/elastic/apm-server/tests"
+)
+
+// ensure all valid documents pass through the whole validation and transformation process
+func TestProcessorOK(t *testing.T) {
+	requestInfo := []tests.RequestInfo{
+		{Name: "TestProcessLogFull", Path: "tests/data/valid/log/payload.json"},
+	}
+	tests.TestProcessRequests(t, log.NewProcessor(pr.DefaultConfig()), requestInfo)
+}
+
+// ensure invalid documents fail the json schema validation already
+func TestProcessorFailedValidation(t *testing.T) {
+	for _, path := range []string{
+		"tests/data/invalid/log_payload/no_app.json",
+		"tests/data/invalid/log_payload/no_logs.json",
+	} {
+		data, err := tests.LoadData(path)
+		assert.Nil(t, err)
+		err = log.NewProcessor(pr.DefaultConfig()).Validate(data)
+		assert.NotNil(t, err, path)
+	}
+}
@@ -0,0 +1,42 @@
+package log
+
+import (
+	pr "github.com/elastic/apm-server/processor"
+	m "github.com/elastic/apm-server/processor/model"
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+var (
+	logCounter = monitoring.NewInt(logMetrics, "counter")
+)
+
+type payload struct {
+	App    m.App     `json:"app"`
+	System *m.System `json:"system"`
+	Events []Event   `json:"logs"`
+}
+
+func (pa *payload) transform(config pr.Config) []beat.Event {
+	var events []beat.Event
+
+	logp.Debug("log", "Transform log events: events=%d, app=%s, agent=%s:%s", len(pa.Events), pa.App.Name, pa.App.Agent.Name, pa.App.Agent.Version)
+
+	logCounter.Add(int64(len(pa.Events)))
+	meta := pa.metadata()
+	fields := make([]common.MapStr, len(pa.Events))
+	for i := range pa.Events {
+		fields[i] = pr.Enrich(config.Enrichers, &pa.Events[i], meta)
+	}
+	for i := range pa.Events {
+		e := &pa.Events[i]
+		events = append(events, pr.AddFields(pr.CreateDoc(e.Mappings(pa)), fields[i]))
+	}
+	return events
+}
+
+func (pa *payload) metadata() pr.Metadata {
+	return pr.Metadata{App: &pa.App, System: pa.System}
+}
@@ -0,0 +1,57 @@
+package log
+
+import (
+	"encoding/json"
+
+	"github.com/santhosh-tekuri/jsonschema"
+
+	pr "github.com/elastic/apm-server/processor"
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+var (
+	logMetrics      = monitoring.Default.NewRegistry("apm-server.processor.log")
+	validationCount = monitoring.NewInt(logMetrics, "validation.count")
+	validationError = monitoring.NewInt(logMetrics, "validation.errors")
+	transformations = monitoring.NewInt(logMetrics, "transformations")
+)
+
+const (
+	processorName = "log"
+)
+
+var schema = pr.CreateSchema(logSchema, processorName)
+
+func NewProcessor(config pr.Config) pr.Processor {
+	return &processor{schema: schema, config: config}
+}
+
+type processor struct {
+	schema *jsonschema.Schema
+	config pr.Config
+}
+
+func (p *processor) Validate(buf []byte) error {
+	validationCount.Inc()
+	err := pr.Validate(buf, p.schema)
+	if err != nil {
+		validationError.Inc()
+	}
+	return err
+}
+
+func (p *processor) Transform(buf []byte) ([]beat.Event, error) {
+	transformations.Inc()
+	var pa payload
+	err := json.Unmarshal(buf, &pa)
+	if err != nil {
+		return nil, err
+	}
+
+	return pa.transform(p.config), nil
+}
+
+func (p *processor) Name() string {
+	return processorName
+}
@@ -0,0 +1,17 @@
+package log
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	pr "github.com/elastic/apm-server/processor"
+)
+
+func TestImplementProcessorInterface(t *testing.T) {
+	p := NewProcessor(pr.DefaultConfig())
+	assert.NotNil(t, p)
+	_, ok := p.(pr.Processor)
+	assert.True(t, ok)
+	assert.IsType(t, &processor{}, p)
+}
@@ -0,0 +1,474 @@
+package log
+
+func Schema() string {
+	return logSchema
+}
+
+var logSchema = `{
+    "$schema": "http://json-schema.org/draft-04/schema#",
+    "$id": "docs/spec/logs/wrapper.json",
+    "title": "Logs Wrapper",
+    "description": "List of log records wrapped in an object containing some other attributes normalized away from the log records themselves",
+    "type": "object",
+    "properties": {
+        "app": {
+                "$schema": "http://json-schema.org/draft-04/schema#",
+    "$id": "doc/spec/app.json",
+    "title": "App",
+    "type": "object",
+    "properties": {
+        "agent": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "maxLength": 1024
+                },
+                "version": {
+                    "type": "string",
+                    "maxLength": 1024
+                }
+            },
+            "required": ["name", "version"]
+        },
+        "argv": {
+            "type": ["array", "null"],
+            "minItems": 0
+        },
+        "framework": {
+            "type": ["object", "null"],
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "maxLength": 1024
+                },
+                "version": {
+                    "type": "string",
+                    "maxLength": 1024
+                }
+            },
+            "required": ["name", "version"]
+        },
+        "language": {
+            "type": ["object", "null"],
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "maxLength": 1024
+                },
+                "version": {
+                    "type": ["string", "null"],
+                    "maxLength": 1024
+                }
+            },
+            "required": ["name"]
+        },
+        "name": {
+            "description": "Immutable name of the app emitting this event",
+            "type": "string",
+            "pattern": "^[a-zA-Z0-9 _-]+$",
+            "maxLength": 1024
+        },
+        "node": {
+            "type": ["object", "null"],
+            "properties": {
+                "configured_name": {
+                    "description": "Name of this instance of the app, set in the agent configuration. Used to distinguish multiple instances of the same app.",
+                    "type": ["string", "null"],
+                    "maxLength": 1024
+                }
+            }
+        },
+        "pid": {
+            "type": ["number", "null"]
+        },
+        "process_title": {
+            "type": ["string", "null"],
+            "maxLength": 1024
+        },
+        "runtime": {
+            "type": ["object", "null"],
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "maxLength": 1024
+                },
+                "version": {
+                    "type": "string",
+                    "maxLength": 1024
+                }
+            },
+            "required": ["name", "version"]
+        },
+        "version": {
+            "description": "Version of the app emitting this event",
+            "type": ["string", "null"],
+            "maxLength": 1024
+        }
+    },
+    "required": ["agent", "name"]
+        },
+        "logs": {
+            "type": "array",
+            "items": {
+                    "$schema": "http://json-schema.org/draft-04/schema#",
+    "$id": "docs/spec/logs/log.json",
+    "type": "object",
+    "description": "An application log record captured by an agent",
+    "properties": {
+        "context": {
+                "$schema": "http://json-schema.org/draft-04/schema#",
+    "$id": "doc/spec/context.json",
+    "title": "Context",
+    "description": "Any arbitrary contextual information regarding the event, captured by the agent, optionally provided by the user",
+    "type": ["object", "null"],
+    "properties": {
+        "custom": {
+            "description": "An arbitrary mapping of additional metadata to store with the event.",
+            "type": ["object", "null"],
+            "regexProperties": true,
+            "patternProperties": {
+                "^[^.*\"]*$": {}
+            },
+            "additionalProperties": false
+        },
+        "message": {
+            "description": "Details of the message the event was triggered by, if received from a messaging system like Kafka, RabbitMQ or SQS.",
+            "type": ["object", "null"],
+            "properties": {
+                "queue": {
+                    "type": ["object", "null"],
+                    "properties": {
+                        "name": {
+                            "description": "Name of the queue or topic the message was received from.",
+                            "type": ["string", "null"],
+                            "maxLength": 1024
+                        }
+                    }
+                },
+                "routing_key": {
+                    "description": "Routing key of the message, as used by RabbitMQ.",
+                    "type": ["string", "null"],
+                    "maxLength": 1024
+                },
+                "age": {
+                    "type": ["object", "null"],
+                    "properties": {
+                        "ms": {
+                            "description": "Time elapsed between sending the message and receiving it, in ms.",
+                            "type": ["integer", "null"]
+                        }
+                    }
+                },
+                "body": {
+                    "description": "The message body.",
+                    "type": ["string", "null"]
+                },
+                "headers": {
+                    "description": "The message headers.",
+                    "type": ["object", "null"]
+                }
+            }
+        },
+        "network": {
+            "description": "Network connection of the device the event was recorded on, sent by RUM and mobile agents.",
+            "type": ["object", "null"],
+            "properties": {
+                "connection": {
+                    "type": ["object", "null"],
+                    "properties": {
+                        "type": {
+                            "description": "Type of the connection.",
+                            "type": ["string", "null"],
+                            "enum": ["wifi", "cell", "wired", "unavailable", "unknown", null],
+                            "maxLength": 1024
+                        },
+                        "subtype": {
+                            "description": "Subtype of the connection, e.g. the radio technology like 'LTE' or '5G' for cell connections.",
+                            "type": ["string", "null"],
+                            "maxLength": 1024
+                        },
+                        "carrier": {
+                            "description": "Mobile network operator of cell connections.",
+                            "type": ["object", "null"],
+                            "properties": {
+                                "name": {
+                                    "description": "Name of the carrier.",
+                                    "type": ["string", "null"],
+                                    "maxLength": 1024
+                                },
+                                "mcc": {
+                                    "description": "Mobile country code.",
+                                    "type": ["string", "null"],
+                                    "pattern": "^[0-9]{3}$",
+                                    "maxLength": 1024
+                                },
+                                "mnc": {
+                                    "description": "Mobile network code.",
+                                    "type": ["string", "null"],
+                                    "pattern": "^[0-9]{2,3}$",
+                                    "maxLength": 1024
+                                },
+                                "icc": {
+                                    "description": "ISO 3166-1 alpha-2 country code of the carrier.",
+                                    "type": ["string", "null"],
+                                    "pattern": "^[a-zA-Z]{2}$",
+                                    "maxLength": 1024
+                                }
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "response": {
+            "type": ["object", "null"],
+            "properties": {
+                "finished": {
+                    "type": ["boolean", "null"]
+                },
+                "headers": {
+                    "type": ["object", "null"],
+                    "properties": {
+                        "content-type": {
+                            "type": ["string", "null"]
+                        }
+                    }
+                },
+                "headers_sent": {
+                    "type": ["boolean", "null"]
+                },
+                "status_code": {
+                    "type": ["number", "null"]
+                },
+                "transfer_size": {
+                    "description": "Size of the response in bytes, including headers.",
+                    "type": ["integer", "null"]
+                }
+            }
+        },
+        "request": {
+                "$schema": "http://json-schema.org/draft-04/schema#",
+    "$id": "docs/spec/http.json",
+    "title": "Request",
+    "description": "If a log record was generated as a result of a http request, the http interface can be used to collect this information.",
+    "type": ["object", "null"],
+    "properties": {
+        "body": {
+            "description": "Data should only contain the request body (not the query string). It can either be a dictionary (for standard HTTP requests) or a raw request body.",
+            "type": ["object", "string", "null"]
+        },
+        "env": {
+            "description": "The env variable is a compounded of environment information passed from the webserver.",
+            "type": ["object", "null"],
+            "properties": {}
+        },
+        "headers": {
+            "description": "Should include any headers sent by the requester. Cookies will be taken by headers if supplied.",
+            "type": ["object", "null"],
+            "properties": {
+                "content-type": {
+                    "type": ["string", "null"]
+                },
+                "cookie": {
+                    "description": "Cookies sent with the request. It is expected to have values delimited by semicolons.",
+                    "type": ["string", "null"]
+                },
+                "user-agent": {
+                    "type": ["string", "null"]
+                }
+            }
+        },
+        "http_version": {
+            "description": "HTTP version.",
+            "type": ["string", "null"],
+            "maxLength": 1024
+        },
+        "method": {
+            "description": "HTTP method.",
+            "type": "string",
+            "maxLength": 1024
+        },
+        "socket": {
+            "type": ["object", "null"],
+            "properties": {
+                "encrypted": {
+                    "description": "Indicates whether request was sent as SSL/HTTPS request.",
+                    "type": ["boolean", "null"]
+                },
+                "remote_address": {
+                    "type": ["string", "null"]
+                }
+            }
+        },
+        "url": {
+            "description": "A complete Url, with scheme, host and path.",
+            "type": "object",
+            "properties": {
+                "raw": {
+                    "type": ["string", "null"],
+                    "maxLength": 1024
+                },
+                "protocol": {
+                    "type": ["string", "null"],
+                    "maxLength": 1024
+                },
+                "hostname": {
+                    "type": ["string", "null"],
+                    "maxLength": 1024
+                },
+                "port": {
+                    "type": ["string", "null"],
+                    "maxLength": 1024
+                },
+                "pathname": {
+                    "type": ["string", "null"],
+                    "maxLength": 1024
+                },
+                "search": {
+                    "description": "The search describes the query string of the request. It is expected to have values delimited by ampersands.",
+                    "type": ["string", "null"],
+                    "maxLength": 1024
+                },
+                "hash": {
+                    "type": ["string", "null"],
+                    "maxLength": 1024
+                }
+            }
+        },
+        "cookies": {
+            "description": "A parsed key-value object of cookies",
+            "type": ["object", "null"]
+        }
+    },
+    "required": ["url", "method"]
+        },
+        "tags": {
+            "description": "A flat mapping of user-defined tags with string, boolean or number values. Dots, asterisks and double quotes in keys are replaced by underscores.",
+            "type": ["object", "null"],
+            "additionalProperties": {
+                "type": ["string", "boolean", "number"],
+                "maxLength": 1024
+            }
+        },
+        "user": {
+                "$schema": "http://json-schema.org/draft-04/schema#",
+    "$id": "docs/spec/user.json",
+    "title": "User",
+    "description": "Describes the authenticated User for a request.",
+    "type": ["object", "null"],
+    "properties": {
+        "id": {
+            "type": ["string", "number", "null"],
+            "maxLength": 1024
+        },    
+        "email": {
+            "type": ["string", "null"],
+            "maxLength": 1024
+        },
+        "username": {
+            "type": ["string", "null"],
+            "maxLength": 1024
+        }
+    }
+        }
+    }
+        },
+        "level": {
+            "description": "The record severity.",
+            "type": ["string", "null"],
+            "maxLength": 1024
+        },
+        "logger_name": {
+            "description": "The name of the logger which created the record.",
+            "type": ["string", "null"],
+            "maxLength": 1024
+        },
+        "message": {
+            "description": "The log message.",
+            "type": "string"
+        },
+        "origin": {
+            "description": "Location in the code where the record was created.",
+            "type": ["object", "null"],
+            "properties": {
+                "file": {
+                    "description": "The file name, including the path relative to the app root.",
+                    "type": ["string", "null"],
+                    "maxLength": 1024
+                },
+                "function": {
+                    "description": "The function which created the record.",
+                    "type": ["string", "null"],
+                    "maxLength": 1024
+                },
+                "line": {
+                    "description": "The line number in the file.",
+                    "type": ["integer", "null"]
+                }
+            }
+        },
+        "timestamp": {
+            "type": "string",
+            "format": "date-time",
+            "pattern": "Z$",
+            "description": "Recorded time of the log record, UTC based and formatted as YYYY-MM-DDTHH:mm:ss.sssZ"
+        },
+        "trace_id": {
+            "description": "ID of the trace which was active when the record was created, referring to the trace id within the transaction.",
+            "type": ["integer", "null"]
+        },
+        "transaction_id": {
+            "description": "UUID of the transaction which was active when the record was created.",
+            "type": ["string", "null"],
+            "pattern": "^[a-fA-F0-9]{8}-[a-fA-F0-9]{4}-[a-fA-F0-9]{4}-[a-fA-F0-9]{4}-[a-fA-F0-9]{12}$"
+        }
+    },
+    "required": ["message", "timestamp"]
+            },
+            "minItems": 1
+        },
+        "system": {
+                "$schema": "http://json-schema.org/draft-04/schema#",
+    "$id": "doc/spec/system.json",
+    "title": "System",
+    "type": ["object", "null"],
+    "properties": {
+        "architecture": {
+            "description": "Architecture of the system the agent is running on.",
+            "type": ["string", "null"],
+            "maxLength": 1024
+        },
+        "hostname": {
+            "description": "Hostname of the system the agent is running on. Deprecated, use detected_hostname and configured_hostname instead.",
+            "type": ["string", "null"],
+            "maxLength": 1024
+        },
+        "detected_hostname": {
+            "description": "Full hostname of the system the agent is running on, as detected by the agent.",
+            "type": ["string", "null"],
+            "maxLength": 1024
+        },
+        "configured_hostname": {
+            "description": "Hostname of the system the agent is running on, as configured by the user.",
+            "type": ["string", "null"],
+            "maxLength": 1024
+        },
+        "detected_ip": {
+            "description": "IP addresses of the system the agent is running on, as detected by the agent. Invalid addresses are dropped.",
+            "type": ["array", "null"],
+            "items": {
+                "type": "string"
+            }
+        },
+        "platform": {
+            "description": "Name of the system platform the agent is running on.",
+            "type": ["string", "null"],
+            "maxLength": 1024
+        }
+    }
+        }
+    },
+    "required": ["app", "logs"]
+}
+`
@@ -15,6 +15,12 @@ type App struct {
 	Runtime      Runtime   `json:"runtime"`
 	Framework    Framework `json:"framework"`
 	Agent        Agent     `json:"agent"`
+	Node         Node      `json:"node"`
+}
+
+// Node identifies a single instance of an app.
+type Node struct {
+	ConfiguredName *string `json:"configured_name"`
 }
 
 type Language struct {
@@ -55,6 +61,10 @@ func (a *App) Transform() common.MapStr {
 	enhancer.Add(app, "process_title", a.ProcessTitle)
 	enhancer.Add(app, "argv", a.Argv)
 
+	node := common.MapStr{}
+	enhancer.Add(node, "configured_name", a.Node.ConfiguredName)
+	enhancer.Add(app, "node", node)
+
 	lang := common.MapStr{}
 	enhancer.Add(lang, "name", a.Language.Name)
 	enhancer.Add(lang, "version", a.Language.Version)
@@ -27,6 +27,7 @@ func TestAppTransform(t *testing.T) {
 	fwVersion := "1.2.3"
 	agentName := "elastic-node"
 	agentVersion := "1.0.0"
+	nodeName := "app-instance-1"
 	tests := []struct {
 		App    App
 		Output common.MapStr
@@ -67,6 +68,7 @@ func TestAppTransform(t *testing.T) {
 					Name:    agentName,
 					Version: agentVersion,
 				},
+				Node: Node{ConfiguredName: &nodeName},
 			},
 			Output: common.MapStr{
 				"name":          "myapp",
@@ -93,6 +95,7 @@ func TestAppTransform(t *testing.T) {
 					"name":    "elastic-node",
 					"version": "1.0.0",
 				},
+				"node": common.MapStr{"configured_name": "app-instance-1"},
 			},
 		},
 	}
@@ -0,0 +1,81 @@
+package model
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+var (
+	tagKeyReplacer = strings.NewReplacer(".", "_", "*", "_", `"`, "_")
+
+	contextMetrics = monitoring.Default.NewRegistry("apm-server.processor.context")
+	tagCollisions  = monitoring.NewInt(contextMetrics, "tags.collisions")
+)
+
+// TransformContext prepares the context of an event for indexing.
+// Keys of tags must not contain dots, asterisks or double quotes,
+// they are replaced with underscores. If that makes keys collide, e.g. `a.b`
+// and `a_b`, the key sent as is wins over the replaced ones, and of those the
+// first in sorted order; the other tags are dropped and counted as collisions.
+// The http request and response, the
+// message, the network and the db details are reduced to their known fields
+// and the request URL is parsed.
+func TransformContext(ctx common.MapStr) common.MapStr {
+	if ctx == nil {
+		return nil
+	}
+	transformHTTP(ctx)
+	transformMessage(ctx)
+	transformNetwork(ctx)
+	transformDB(ctx)
+
+	tags, ok := ctx["tags"].(map[string]interface{})
+	if !ok {
+		return ctx
+	}
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	sanitized := common.MapStr{}
+	var replaced []string
+	for _, k := range keys {
+		if name := tagKeyReplacer.Replace(k); name != k {
+			replaced = append(replaced, k)
+			continue
+		}
+		sanitized[k] = tags[k]
+	}
+	for _, k := range replaced {
+		name := tagKeyReplacer.Replace(k)
+		if _, ok := sanitized[name]; ok {
+			logp.Debug("context", "Dropping tag %s, it collides with tag %s", k, name)
+			tagCollisions.Inc()
+			continue
+		}
+		sanitized[name] = tags[k]
+	}
+	ctx["tags"] = sanitized
+	return ctx
+}
+
+// decodeContextValue decodes the value of a context key into its typed model.
+// It returns false if the value does not match the model.
+func decodeContextValue(raw interface{}, out interface{}, key string) bool {
+	buf, err := json.Marshal(raw)
+	if err == nil {
+		err = json.Unmarshal(buf, out)
+	}
+	if err != nil {
+		logp.Debug("context", "Keeping context.%s as sent, decoding failed: %v", key, err)
+		return false
+	}
+	return true
+}
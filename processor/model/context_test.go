@@ -0,0 +1,62 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestTransformContext(t *testing.T) {
+	tests := []struct {
+		Context common.MapStr
+		Output  common.MapStr
+	}{
+		{
+			Context: nil,
+			Output:  nil,
+		},
+		{
+			Context: common.MapStr{"user": map[string]interface{}{"id": "1"}},
+			Output:  common.MapStr{"user": map[string]interface{}{"id": "1"}},
+		},
+		{
+			Context: common.MapStr{
+				"tags": map[string]interface{}{
+					"organization.uuid": "9f0e9d64",
+					"a*b":               true,
+					`c"d`:               12.5,
+					"plain":             "value",
+				},
+			},
+			Output: common.MapStr{
+				"tags": common.MapStr{
+					"organization_uuid": "9f0e9d64",
+					"a_b":               true,
+					"c_d":               12.5,
+					"plain":             "value",
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		output := TransformContext(test.Context)
+		assert.Equal(t, test.Output, output)
+	}
+}
+
+func TestTransformContextTagCollisions(t *testing.T) {
+	before := tagCollisions.Get()
+	ctx := TransformContext(common.MapStr{
+		"tags": map[string]interface{}{
+			"a.b": 1,
+			"a_b": 2,
+			"c*d": 3,
+			"c.d": 4,
+		},
+	})
+	assert.Equal(t, common.MapStr{"a_b": 2, "c_d": 3}, ctx["tags"])
+	assert.Equal(t, before+2, tagCollisions.Get())
+}
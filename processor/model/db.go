@@ -0,0 +1,42 @@
+package model
+
+import (
+	"github.com/elastic/apm-server/utility"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// DB holds the details of a database call.
+type DB struct {
+	Instance     *string `json:"instance"`
+	Statement    *string `json:"statement"`
+	Type         *string `json:"type"`
+	User         *string `json:"user"`
+	RowsAffected *int    `json:"rows_affected"`
+}
+
+func (db *DB) Transform() common.MapStr {
+	enhancer := utility.NewMapStrEnhancer()
+	m := common.MapStr{}
+	enhancer.Add(m, "instance", db.Instance)
+	enhancer.Add(m, "statement", db.Statement)
+	enhancer.Add(m, "type", db.Type)
+	enhancer.Add(m, "user", db.User)
+	enhancer.Add(m, "rows_affected", db.RowsAffected)
+	return m
+}
+
+func transformDB(ctx common.MapStr) {
+	raw, ok := ctx["db"]
+	if !ok || raw == nil {
+		return
+	}
+	var db DB
+	if !decodeContextValue(raw, &db, "db") {
+		return
+	}
+	if transformed := db.Transform(); len(transformed) > 0 {
+		ctx["db"] = transformed
+	} else {
+		delete(ctx, "db")
+	}
+}
@@ -0,0 +1,55 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestTransformDB(t *testing.T) {
+	tests := []struct {
+		Context common.MapStr
+		Output  common.MapStr
+		Msg     string
+	}{
+		{
+			Context: common.MapStr{
+				"db": map[string]interface{}{
+					"instance":      "customers",
+					"statement":     "SELECT * FROM users",
+					"type":          "sql",
+					"user":          "readonly",
+					"rows_affected": 3,
+					"unknown":       "dropped",
+				},
+			},
+			Output: common.MapStr{
+				"db": common.MapStr{
+					"instance":      "customers",
+					"statement":     "SELECT * FROM users",
+					"type":          "sql",
+					"user":          "readonly",
+					"rows_affected": 3,
+				},
+			},
+			Msg: "Full db",
+		},
+		{
+			Context: common.MapStr{"db": map[string]interface{}{"instance": nil}},
+			Output:  common.MapStr{},
+			Msg:     "Empty db",
+		},
+		{
+			Context: common.MapStr{"db": map[string]interface{}{"rows_affected": "many"}},
+			Output:  common.MapStr{"db": map[string]interface{}{"rows_affected": "many"}},
+			Msg:     "Undecodable db kept as sent",
+		},
+	}
+
+	for _, test := range tests {
+		transformDB(test.Context)
+		assert.Equal(t, test.Output, test.Context, test.Msg)
+	}
+}
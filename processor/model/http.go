@@ -0,0 +1,136 @@
+package model
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/elastic/apm-server/utility"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+type Request struct {
+	Method      string        `json:"method"`
+	HttpVersion *string       `json:"http_version"`
+	Url         Url           `json:"url"`
+	Headers     common.MapStr `json:"headers"`
+	Cookies     common.MapStr `json:"cookies"`
+	Socket      Socket        `json:"socket"`
+	Env         common.MapStr `json:"env"`
+	Body        interface{}   `json:"body"`
+}
+
+type Url struct {
+	Raw      *string `json:"raw"`
+	Protocol *string `json:"protocol"`
+	Hostname *string `json:"hostname"`
+	Port     *string `json:"port"`
+	Pathname *string `json:"pathname"`
+	Search   *string `json:"search"`
+	Hash     *string `json:"hash"`
+}
+
+type Socket struct {
+	Encrypted     *bool   `json:"encrypted"`
+	RemoteAddress *string `json:"remote_address"`
+}
+
+type Response struct {
+	StatusCode   *int          `json:"status_code"`
+	Headers      common.MapStr `json:"headers"`
+	HeadersSent  *bool         `json:"headers_sent"`
+	Finished     *bool         `json:"finished"`
+	TransferSize *int          `json:"transfer_size"`
+}
+
+func (r *Request) Transform() common.MapStr {
+	enhancer := utility.NewMapStrEnhancer()
+	req := common.MapStr{"method": r.Method}
+	enhancer.Add(req, "http_version", r.HttpVersion)
+	enhancer.Add(req, "url", r.Url.Transform())
+	enhancer.Add(req, "headers", r.Headers)
+	enhancer.Add(req, "cookies", r.Cookies)
+	enhancer.Add(req, "socket", r.Socket.Transform())
+	enhancer.Add(req, "env", r.Env)
+	enhancer.Add(req, "body", r.Body)
+	return req
+}
+
+// Transform fills in the components of the URL the agent did not send by
+// parsing the raw URL, so all URLs can be searched by their parsed fields.
+func (u *Url) Transform() common.MapStr {
+	if u.Raw != nil {
+		if parsed, err := url.Parse(*u.Raw); err == nil {
+			setIfMissing(&u.Protocol, parsed.Scheme, ":")
+			setIfMissing(&u.Hostname, parsed.Hostname(), "")
+			setIfMissing(&u.Port, parsed.Port(), "")
+			setIfMissing(&u.Pathname, parsed.Path, "")
+			setIfMissing(&u.Search, parsed.RawQuery, "?")
+			setIfMissing(&u.Hash, parsed.Fragment, "#")
+		}
+	}
+
+	enhancer := utility.NewMapStrEnhancer()
+	parts := common.MapStr{}
+	enhancer.Add(parts, "raw", u.Raw)
+	enhancer.Add(parts, "protocol", u.Protocol)
+	enhancer.Add(parts, "hostname", u.Hostname)
+	enhancer.Add(parts, "port", u.Port)
+	enhancer.Add(parts, "pathname", u.Pathname)
+	enhancer.Add(parts, "search", u.Search)
+	enhancer.Add(parts, "hash", u.Hash)
+	return parts
+}
+
+func setIfMissing(field **string, val, affix string) {
+	if *field != nil || val == "" {
+		return
+	}
+	// agents send the protocol with a trailing colon, search and hash with
+	// a leading question mark and hash sign, as in the browser location API
+	if strings.HasSuffix(affix, ":") {
+		val = val + affix
+	} else {
+		val = affix + val
+	}
+	*field = &val
+}
+
+func (s *Socket) Transform() common.MapStr {
+	enhancer := utility.NewMapStrEnhancer()
+	socket := common.MapStr{}
+	enhancer.Add(socket, "encrypted", s.Encrypted)
+	enhancer.Add(socket, "remote_address", s.RemoteAddress)
+	return socket
+}
+
+func (r *Response) Transform() common.MapStr {
+	enhancer := utility.NewMapStrEnhancer()
+	res := common.MapStr{}
+	enhancer.Add(res, "status_code", r.StatusCode)
+	enhancer.Add(res, "headers", r.Headers)
+	enhancer.Add(res, "headers_sent", r.HeadersSent)
+	enhancer.Add(res, "finished", r.Finished)
+	enhancer.Add(res, "transfer_size", r.TransferSize)
+	return res
+}
+
+// transformHTTP replaces the request and response of the context with their
+// typed representation. If a value cannot be decoded, it is kept as sent.
+func transformHTTP(ctx common.MapStr) {
+	if raw, ok := ctx["request"]; ok && raw != nil {
+		var req Request
+		if decodeContextValue(raw, &req, "request") {
+			ctx["request"] = req.Transform()
+		}
+	}
+	if raw, ok := ctx["response"]; ok && raw != nil {
+		var res Response
+		if decodeContextValue(raw, &res, "response") {
+			if transformed := res.Transform(); len(transformed) > 0 {
+				ctx["response"] = transformed
+			} else {
+				delete(ctx, "response")
+			}
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestTransformHTTP(t *testing.T) {
+	tests := []struct {
+		Context common.MapStr
+		Output  common.MapStr
+		Msg     string
+	}{
+		{
+			Context: common.MapStr{
+				"request": map[string]interface{}{
+					"method": "GET",
+					"url":    map[string]interface{}{"raw": "https://example.com:8443/p/a?q=1#top"},
+				},
+			},
+			Output: common.MapStr{
+				"request": common.MapStr{
+					"method": "GET",
+					"url": common.MapStr{
+						"raw":      "https://example.com:8443/p/a?q=1#top",
+						"protocol": "https:",
+						"hostname": "example.com",
+						"port":     "8443",
+						"pathname": "/p/a",
+						"search":   "?q=1",
+						"hash":     "#top",
+					},
+				},
+			},
+			Msg: "URL parsed from raw",
+		},
+		{
+			Context: common.MapStr{
+				"request": map[string]interface{}{
+					"method": "GET",
+					"url": map[string]interface{}{
+						"raw":      "/p/a?q=1",
+						"pathname": "/sent",
+					},
+				},
+			},
+			Output: common.MapStr{
+				"request": common.MapStr{
+					"method": "GET",
+					"url": common.MapStr{
+						"raw":      "/p/a?q=1",
+						"pathname": "/sent",
+						"search":   "?q=1",
+					},
+				},
+			},
+			Msg: "URL components sent by the agent are kept",
+		},
+		{
+			Context: common.MapStr{
+				"request": map[string]interface{}{
+					"method":  "POST",
+					"url":     map[string]interface{}{},
+					"socket":  map[string]interface{}{"encrypted": true, "remote_address": nil},
+					"headers": map[string]interface{}{"content-type": "text/html"},
+					"body":    map[string]interface{}{"k": "v"},
+					"unknown": "dropped",
+				},
+				"response": map[string]interface{}{
+					"status_code":   200,
+					"headers_sent":  nil,
+					"transfer_size": 1024,
+				},
+			},
+			Output: common.MapStr{
+				"request": common.MapStr{
+					"method":  "POST",
+					"socket":  common.MapStr{"encrypted": true},
+					"headers": common.MapStr{"content-type": "text/html"},
+					"body":    map[string]interface{}{"k": "v"},
+				},
+				"response": common.MapStr{
+					"status_code":   200,
+					"transfer_size": 1024,
+				},
+			},
+			Msg: "Only known, non-null fields",
+		},
+		{
+			Context: common.MapStr{
+				"response": map[string]interface{}{"status_code": nil},
+			},
+			Output: common.MapStr{},
+			Msg:    "Empty response",
+		},
+		{
+			Context: common.MapStr{
+				"response": map[string]interface{}{"status_code": 200.5},
+			},
+			Output: common.MapStr{
+				"response": map[string]interface{}{"status_code": 200.5},
+			},
+			Msg: "Undecodable response kept as sent",
+		},
+	}
+
+	for _, test := range tests {
+		transformHTTP(test.Context)
+		assert.Equal(t, test.Output, test.Context, test.Msg)
+	}
+}
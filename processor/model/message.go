@@ -0,0 +1,55 @@
+package model
+
+import (
+	"github.com/elastic/apm-server/utility"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// Message holds information about a message received from a messaging
+// system like Kafka, RabbitMQ or SQS, if the event was triggered by it.
+type Message struct {
+	Queue      MessageQueue  `json:"queue"`
+	RoutingKey *string       `json:"routing_key"`
+	Age        MessageAge    `json:"age"`
+	Body       *string       `json:"body"`
+	Headers    common.MapStr `json:"headers"`
+}
+
+type MessageQueue struct {
+	Name *string `json:"name"`
+}
+
+type MessageAge struct {
+	Ms *int `json:"ms"`
+}
+
+func (msg *Message) Transform() common.MapStr {
+	enhancer := utility.NewMapStrEnhancer()
+	m := common.MapStr{}
+	queue := common.MapStr{}
+	enhancer.Add(queue, "name", msg.Queue.Name)
+	enhancer.Add(m, "queue", queue)
+	enhancer.Add(m, "routing_key", msg.RoutingKey)
+	age := common.MapStr{}
+	enhancer.Add(age, "ms", msg.Age.Ms)
+	enhancer.Add(m, "age", age)
+	enhancer.Add(m, "body", msg.Body)
+	enhancer.Add(m, "headers", msg.Headers)
+	return m
+}
+
+func transformMessage(ctx common.MapStr) {
+	raw, ok := ctx["message"]
+	if !ok || raw == nil {
+		return
+	}
+	var msg Message
+	if !decodeContextValue(raw, &msg, "message") {
+		return
+	}
+	if transformed := msg.Transform(); len(transformed) > 0 {
+		ctx["message"] = transformed
+	} else {
+		delete(ctx, "message")
+	}
+}
@@ -0,0 +1,61 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestTransformMessage(t *testing.T) {
+	tests := []struct {
+		Context common.MapStr
+		Output  common.MapStr
+		Msg     string
+	}{
+		{
+			Context: common.MapStr{
+				"message": map[string]interface{}{
+					"queue":       map[string]interface{}{"name": "orders"},
+					"routing_key": "order.created",
+					"age":         map[string]interface{}{"ms": 1500},
+					"body":        "{}",
+					"headers":     map[string]interface{}{"priority": "high"},
+					"unknown":     "dropped",
+				},
+			},
+			Output: common.MapStr{
+				"message": common.MapStr{
+					"queue":       common.MapStr{"name": "orders"},
+					"routing_key": "order.created",
+					"age":         common.MapStr{"ms": 1500},
+					"body":        "{}",
+					"headers":     common.MapStr{"priority": "high"},
+				},
+			},
+			Msg: "Full message",
+		},
+		{
+			Context: common.MapStr{
+				"message": map[string]interface{}{"queue": nil, "age": map[string]interface{}{"ms": nil}},
+			},
+			Output: common.MapStr{},
+			Msg:    "Empty message",
+		},
+		{
+			Context: common.MapStr{
+				"message": map[string]interface{}{"age": map[string]interface{}{"ms": "slow"}},
+			},
+			Output: common.MapStr{
+				"message": map[string]interface{}{"age": map[string]interface{}{"ms": "slow"}},
+			},
+			Msg: "Undecodable message kept as sent",
+		},
+	}
+
+	for _, test := range tests {
+		transformMessage(test.Context)
+		assert.Equal(t, test.Output, test.Context, test.Msg)
+	}
+}
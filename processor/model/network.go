@@ -0,0 +1,59 @@
+package model
+
+import (
+	"github.com/elastic/apm-server/utility"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// Network holds information about the network connection of the device the
+// event was recorded on, sent by RUM and mobile agents.
+type Network struct {
+	Connection NetworkConnection `json:"connection"`
+}
+
+type NetworkConnection struct {
+	Type    *string        `json:"type"`
+	Subtype *string        `json:"subtype"`
+	Carrier NetworkCarrier `json:"carrier"`
+}
+
+// NetworkCarrier identifies the mobile network operator by its mobile
+// country code (mcc), mobile network code (mnc) and ISO country code (icc).
+type NetworkCarrier struct {
+	Name *string `json:"name"`
+	MCC  *string `json:"mcc"`
+	MNC  *string `json:"mnc"`
+	ICC  *string `json:"icc"`
+}
+
+func (n *Network) Transform() common.MapStr {
+	enhancer := utility.NewMapStrEnhancer()
+	carrier := common.MapStr{}
+	enhancer.Add(carrier, "name", n.Connection.Carrier.Name)
+	enhancer.Add(carrier, "mcc", n.Connection.Carrier.MCC)
+	enhancer.Add(carrier, "mnc", n.Connection.Carrier.MNC)
+	enhancer.Add(carrier, "icc", n.Connection.Carrier.ICC)
+	connection := common.MapStr{}
+	enhancer.Add(connection, "type", n.Connection.Type)
+	enhancer.Add(connection, "subtype", n.Connection.Subtype)
+	enhancer.Add(connection, "carrier", carrier)
+	network := common.MapStr{}
+	enhancer.Add(network, "connection", connection)
+	return network
+}
+
+func transformNetwork(ctx common.MapStr) {
+	raw, ok := ctx["network"]
+	if !ok || raw == nil {
+		return
+	}
+	var network Network
+	if !decodeContextValue(raw, &network, "network") {
+		return
+	}
+	if transformed := network.Transform(); len(transformed) > 0 {
+		ctx["network"] = transformed
+	} else {
+		delete(ctx, "network")
+	}
+}
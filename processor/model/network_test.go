@@ -0,0 +1,72 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+func TestTransformNetwork(t *testing.T) {
+	tests := []struct {
+		Context common.MapStr
+		Output  common.MapStr
+		Msg     string
+	}{
+		{
+			Context: common.MapStr{
+				"network": map[string]interface{}{
+					"connection": map[string]interface{}{
+						"type":    "cell",
+						"subtype": "LTE",
+						"carrier": map[string]interface{}{
+							"name": "Vodafone", "mcc": "262", "mnc": "02", "icc": "DE", "unknown": "dropped",
+						},
+					},
+					"unknown": "dropped",
+				},
+			},
+			Output: common.MapStr{
+				"network": common.MapStr{
+					"connection": common.MapStr{
+						"type":    "cell",
+						"subtype": "LTE",
+						"carrier": common.MapStr{"name": "Vodafone", "mcc": "262", "mnc": "02", "icc": "DE"},
+					},
+				},
+			},
+			Msg: "Full network",
+		},
+		{
+			Context: common.MapStr{
+				"network": map[string]interface{}{"connection": map[string]interface{}{"type": "wifi", "carrier": nil}},
+			},
+			Output: common.MapStr{
+				"network": common.MapStr{"connection": common.MapStr{"type": "wifi"}},
+			},
+			Msg: "Connection without carrier",
+		},
+		{
+			Context: common.MapStr{
+				"network": map[string]interface{}{"connection": nil},
+			},
+			Output: common.MapStr{},
+			Msg:    "Empty network",
+		},
+		{
+			Context: common.MapStr{
+				"network": map[string]interface{}{"connection": map[string]interface{}{"type": 4}},
+			},
+			Output: common.MapStr{
+				"network": map[string]interface{}{"connection": map[string]interface{}{"type": 4}},
+			},
+			Msg: "Undecodable network kept as sent",
+		},
+	}
+
+	for _, test := range tests {
+		transformNetwork(test.Context)
+		assert.Equal(t, test.Output, test.Context, test.Msg)
+	}
+}
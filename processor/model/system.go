@@ -1,14 +1,19 @@
 package model
 
 import (
+	"net"
+
 	"github.com/elastic/apm-server/utility"
 	"github.com/elastic/beats/libbeat/common"
 )
 
 type System struct {
-	Hostname     *string `json:"hostname"`
-	Architecture *string `json:"architecture"`
-	Platform     *string `json:"platform"`
+	Hostname           *string  `json:"hostname"`
+	DetectedHostname   *string  `json:"detected_hostname"`
+	ConfiguredHostname *string  `json:"configured_hostname"`
+	DetectedIP         []string `json:"detected_ip"`
+	Architecture       *string  `json:"architecture"`
+	Platform           *string  `json:"platform"`
 }
 
 func (s *System) Transform() common.MapStr {
@@ -17,9 +22,36 @@ func (s *System) Transform() common.MapStr {
 	}
 	enhancer := utility.NewMapStrEnhancer()
 	system := common.MapStr{}
-	enhancer.Add(system, "hostname", s.Hostname)
+	enhancer.Add(system, "hostname", s.detectedHostname())
+	enhancer.Add(system, "configured_hostname", s.ConfiguredHostname)
+	enhancer.Add(system, "ip", s.detectedIP())
 	enhancer.Add(system, "architecture", s.Architecture)
 	enhancer.Add(system, "platform", s.Platform)
 
 	return system
 }
+
+// detectedHostname returns the full hostname as detected by the agent. Older
+// agents only send hostname, which is taken as detected, unless it is just
+// the hostname configured by the user, which is kept separately.
+func (s *System) detectedHostname() *string {
+	if s.DetectedHostname != nil {
+		return s.DetectedHostname
+	}
+	if s.Hostname != nil && s.ConfiguredHostname != nil && *s.Hostname == *s.ConfiguredHostname {
+		return nil
+	}
+	return s.Hostname
+}
+
+// detectedIP returns the valid IP addresses of the system, in their
+// canonical form.
+func (s *System) detectedIP() []string {
+	var ips []string
+	for _, addr := range s.DetectedIP {
+		if ip := net.ParseIP(addr); ip != nil {
+			ips = append(ips, ip.String())
+		}
+	}
+	return ips
+}
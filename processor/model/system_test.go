@@ -51,3 +51,43 @@ func TestSystemTransform(t *testing.T) {
 		assert.Equal(t, test.Output, output)
 	}
 }
+
+func TestSystemTransformHostnames(t *testing.T) {
+	full, configured := "prod1.example.com", "prod1"
+
+	tests := []struct {
+		System System
+		Output common.MapStr
+		Msg    string
+	}{
+		{
+			System: System{Hostname: &full},
+			Output: common.MapStr{"hostname": full},
+			Msg:    "Legacy hostname is taken as detected",
+		},
+		{
+			System: System{Hostname: &configured, DetectedHostname: &full, ConfiguredHostname: &configured},
+			Output: common.MapStr{"hostname": full, "configured_hostname": configured},
+			Msg:    "Detected hostname takes precedence",
+		},
+		{
+			System: System{Hostname: &configured, ConfiguredHostname: &configured},
+			Output: common.MapStr{"configured_hostname": configured},
+			Msg:    "Legacy hostname equal to the configured one is not taken as detected",
+		},
+		{
+			System: System{DetectedIP: []string{"10.0.0.1", "::FFFF:10.0.0.2", "localhost", "FE80::1"}},
+			Output: common.MapStr{"ip": []string{"10.0.0.1", "10.0.0.2", "fe80::1"}},
+			Msg:    "Invalid addresses are dropped",
+		},
+		{
+			System: System{DetectedIP: []string{"invalid"}},
+			Output: common.MapStr{},
+			Msg:    "No valid addresses",
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.Output, test.System.Transform(), test.Msg)
+	}
+}
@@ -0,0 +1,232 @@
+package otlp
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The types below cover the subset of the OTLP/JSON log export format
+// that is needed to convert log records into errors and logs.
+type exportLogsRequest struct {
+	ResourceLogs []resourceLogs `json:"resourceLogs"`
+}
+
+type resourceLogs struct {
+	Resource struct {
+		Attributes attributes `json:"attributes"`
+	} `json:"resource"`
+	ScopeLogs []struct {
+		LogRecords []logRecord `json:"logRecords"`
+	} `json:"scopeLogs"`
+}
+
+type logRecord struct {
+	TimeUnixNano         unixNano   `json:"timeUnixNano"`
+	ObservedTimeUnixNano unixNano   `json:"observedTimeUnixNano"`
+	SeverityText         string     `json:"severityText"`
+	Body                 anyValue   `json:"body"`
+	Attributes           attributes `json:"attributes"`
+	TraceID              string     `json:"traceId"`
+	SpanID               string     `json:"spanId"`
+}
+
+type attributes []struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+func (a attributes) get(key string) interface{} {
+	for _, attr := range a {
+		if attr.Key == key {
+			return attr.Value.value()
+		}
+	}
+	return nil
+}
+
+func (a attributes) getString(key string) string {
+	if s, ok := a.get(key).(string); ok {
+		return s
+	}
+	return ""
+}
+
+type anyValue struct {
+	StringValue *string      `json:"stringValue"`
+	BoolValue   *bool        `json:"boolValue"`
+	IntValue    *json.Number `json:"intValue"`
+	DoubleValue *float64     `json:"doubleValue"`
+}
+
+// value returns the scalar value, nil for unset values and for arrays and
+// maps, which are not supported.
+func (v anyValue) value() interface{} {
+	switch {
+	case v.StringValue != nil:
+		return *v.StringValue
+	case v.BoolValue != nil:
+		return *v.BoolValue
+	case v.IntValue != nil:
+		if i, err := v.IntValue.Int64(); err == nil {
+			return i
+		}
+	case v.DoubleValue != nil:
+		return *v.DoubleValue
+	}
+	return nil
+}
+
+// unixNano accepts nanoseconds since epoch encoded as string or number, as
+// 64 bit integers are sent as strings in OTLP/JSON.
+type unixNano uint64
+
+func (n *unixNano) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "" || s == "null" {
+		return nil
+	}
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	*n = unixNano(v)
+	return nil
+}
+
+// payloads returns an error and a log payload per resource. Log records
+// which describe an exception go into the error payload, all other records
+// into the log payload. Payloads without records are left out.
+func (r exportLogsRequest) payloads() (errorPayloads, logPayloads []map[string]interface{}) {
+	for _, rl := range r.ResourceLogs {
+		var errors, logs []map[string]interface{}
+		for _, sl := range rl.ScopeLogs {
+			for _, record := range sl.LogRecords {
+				if record.isException() {
+					errors = append(errors, record.error())
+				} else {
+					logs = append(logs, record.log())
+				}
+			}
+		}
+		if len(errors) > 0 {
+			errorPayloads = append(errorPayloads, map[string]interface{}{
+				"app":    app(rl.Resource.Attributes),
+				"errors": errors,
+			})
+		}
+		if len(logs) > 0 {
+			logPayloads = append(logPayloads, map[string]interface{}{
+				"app":  app(rl.Resource.Attributes),
+				"logs": logs,
+			})
+		}
+	}
+	return errorPayloads, logPayloads
+}
+
+func app(attrs attributes) map[string]interface{} {
+	agent := map[string]interface{}{"name": "otlp", "version": "unknown"}
+	if name := attrs.getString("telemetry.sdk.name"); name != "" {
+		agent["name"] = name
+	}
+	if version := attrs.getString("telemetry.sdk.version"); version != "" {
+		agent["version"] = version
+	}
+	app := map[string]interface{}{
+		"name":  attrs.getString("service.name"),
+		"agent": agent,
+	}
+	if version := attrs.getString("service.version"); version != "" {
+		app["version"] = version
+	}
+	if language := attrs.getString("telemetry.sdk.language"); language != "" {
+		app["language"] = map[string]interface{}{"name": language}
+	}
+	return app
+}
+
+func (r logRecord) isException() bool {
+	return r.Attributes.get("exception.type") != nil || r.Attributes.get("exception.message") != nil
+}
+
+func (r logRecord) error() map[string]interface{} {
+	message := r.Attributes.getString("exception.message")
+	if message == "" {
+		if body, ok := r.Body.value().(string); ok {
+			message = body
+		}
+	}
+	exception := map[string]interface{}{"message": message}
+	if typ := r.Attributes.getString("exception.type"); typ != "" {
+		exception["type"] = typ
+	}
+	if stacktrace := r.Attributes.getString("exception.stacktrace"); stacktrace != "" {
+		exception["attributes"] = map[string]interface{}{"stacktrace": stacktrace}
+	}
+
+	e := map[string]interface{}{
+		"timestamp": r.formattedTimestamp(),
+		"exception": exception,
+	}
+	if tags := r.tags(); len(tags) > 0 {
+		e["context"] = map[string]interface{}{"tags": tags}
+	}
+	return e
+}
+
+func (r logRecord) log() map[string]interface{} {
+	var message string
+	if body := r.Body.value(); body != nil {
+		message = fmt.Sprint(body)
+	}
+	l := map[string]interface{}{
+		"timestamp": r.formattedTimestamp(),
+		"message":   message,
+	}
+	if r.SeverityText != "" {
+		l["level"] = r.SeverityText
+	}
+	if tags := r.tags(); len(tags) > 0 {
+		l["context"] = map[string]interface{}{"tags": tags}
+	}
+	return l
+}
+
+// tags returns the attributes of the record, except the exception
+// attributes, along with the ids of the trace and span it belongs to.
+func (r logRecord) tags() map[string]interface{} {
+	tags := map[string]interface{}{}
+	for _, attr := range r.Attributes {
+		if strings.HasPrefix(attr.Key, "exception.") {
+			continue
+		}
+		if v := attr.Value.value(); v != nil {
+			tags[attr.Key] = v
+		}
+	}
+	if r.TraceID != "" {
+		tags["trace_id"] = r.TraceID
+	}
+	if r.SpanID != "" {
+		tags["span_id"] = r.SpanID
+	}
+	return tags
+}
+
+func (r logRecord) formattedTimestamp() string {
+	return r.timestamp().UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+func (r logRecord) timestamp() time.Time {
+	ns := r.TimeUnixNano
+	if ns == 0 {
+		ns = r.ObservedTimeUnixNano
+	}
+	if ns == 0 {
+		return time.Now()
+	}
+	return time.Unix(0, int64(ns))
+}
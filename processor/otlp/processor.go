@@ -0,0 +1,124 @@
+package otlp
+
+import (
+	"encoding/json"
+
+	pr "github.com/elastic/apm-server/processor"
+	err "github.com/elastic/apm-server/processor/error"
+	"github.com/elastic/apm-server/processor/log"
+	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/monitoring"
+)
+
+var (
+	otlpMetrics     = monitoring.Default.NewRegistry("apm-server.processor.otlp")
+	validationCount = monitoring.NewInt(otlpMetrics, "validation.count")
+	validationError = monitoring.NewInt(otlpMetrics, "validation.errors")
+	transformations = monitoring.NewInt(otlpMetrics, "transformations")
+)
+
+const (
+	processorName = "otlp"
+)
+
+// NewLogsProcessor returns a processor for OTLP log exports in the JSON
+// encoding. Log records carrying exception attributes are converted into
+// error payloads and handled by the error processor, all other records into
+// log payloads handled by the log processor.
+func NewLogsProcessor(config pr.Config) pr.Processor {
+	return &processor{errors: err.NewProcessor(config), logs: log.NewProcessor(config)}
+}
+
+type processor struct {
+	errors pr.Processor
+	logs   pr.Processor
+}
+
+func (p *processor) Validate(buf []byte) error {
+	validationCount.Inc()
+	errorPayloads, logPayloads, err := decodePayloads(buf)
+	if err == nil {
+		err = validatePayloads(p.errors, errorPayloads)
+	}
+	if err == nil {
+		err = validatePayloads(p.logs, logPayloads)
+	}
+	if err != nil {
+		validationError.Inc()
+	}
+	return err
+}
+
+func (p *processor) Transform(buf []byte) ([]beat.Event, error) {
+	transformations.Inc()
+	errorPayloads, logPayloads, err := decodePayloads(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := transformPayloads(p.errors, errorPayloads)
+	if err != nil {
+		return nil, err
+	}
+	logs, err := transformPayloads(p.logs, logPayloads)
+	if err != nil {
+		return nil, err
+	}
+	return append(events, logs...), nil
+}
+
+func (p *processor) Name() string {
+	return processorName
+}
+
+func validatePayloads(p pr.Processor, payloads [][]byte) error {
+	for _, payload := range payloads {
+		if err := p.Validate(payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func transformPayloads(p pr.Processor, payloads [][]byte) ([]beat.Event, error) {
+	var events []beat.Event
+	for _, payload := range payloads {
+		list, err := p.Transform(payload)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, list...)
+	}
+	return events, nil
+}
+
+// decodePayloads converts an OTLP log export into error and log payloads.
+func decodePayloads(buf []byte) ([][]byte, [][]byte, error) {
+	var req exportLogsRequest
+	if err := json.Unmarshal(buf, &req); err != nil {
+		return nil, nil, err
+	}
+
+	errorPayloads, logPayloads := req.payloads()
+	errors, err := marshalPayloads(errorPayloads)
+	if err != nil {
+		return nil, nil, err
+	}
+	logs, err := marshalPayloads(logPayloads)
+	if err != nil {
+		return nil, nil, err
+	}
+	return errors, logs, nil
+}
+
+func marshalPayloads(payloads []map[string]interface{}) ([][]byte, error) {
+	var out [][]byte
+	for _, payload := range payloads {
+		buf, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, buf)
+	}
+	return out, nil
+}
@@ -0,0 +1,122 @@
+package otlp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	pr "github.com/elastic/apm-server/processor"
+	"github.com/elastic/beats/libbeat/common"
+)
+
+const logsPayload = `{
+  "resourceLogs": [{
+    "resource": {"attributes": [
+      {"key": "service.name", "value": {"stringValue": "checkout"}},
+      {"key": "telemetry.sdk.name", "value": {"stringValue": "opentelemetry"}},
+      {"key": "telemetry.sdk.version", "value": {"stringValue": "1.2.0"}},
+      {"key": "telemetry.sdk.language", "value": {"stringValue": "java"}}
+    ]},
+    "scopeLogs": [{"logRecords": [{
+      "timeUnixNano": "1496170407154000000",
+      "severityText": "ERROR",
+      "body": {"stringValue": "payment failed"},
+      "traceId": "5b8efff798038103d269b633813fc60c",
+      "attributes": [
+        {"key": "exception.type", "value": {"stringValue": "java.io.IOException"}},
+        {"key": "exception.message", "value": {"stringValue": "connection reset"}},
+        {"key": "retries", "value": {"intValue": "3"}}
+      ]
+    }, {
+      "timeUnixNano": "1496170407154000000",
+      "severityText": "INFO",
+      "body": {"stringValue": "order placed"},
+      "attributes": [{"key": "cart", "value": {"intValue": 2}}]
+    }]}]
+  }]
+}`
+
+func TestImplementProcessorInterface(t *testing.T) {
+	p := NewLogsProcessor(pr.DefaultConfig())
+	assert.NotNil(t, p)
+	_, ok := p.(pr.Processor)
+	assert.True(t, ok)
+	assert.IsType(t, &processor{}, p)
+}
+
+func TestTransformExceptionRecords(t *testing.T) {
+	p := NewLogsProcessor(pr.DefaultConfig())
+	buf := []byte(logsPayload)
+	assert.NoError(t, p.Validate(buf))
+
+	events, err := p.Transform(buf)
+	assert.NoError(t, err)
+	if !assert.NotEmpty(t, events) {
+		return
+	}
+
+	fields := events[0].Fields
+	for key, expected := range map[string]interface{}{
+		"processor.event":         "error",
+		"context.app.name":        "checkout",
+		"context.app.agent.name":  "opentelemetry",
+		"error.exception.type":    "java.io.IOException",
+		"error.exception.message": "connection reset",
+		"context.tags.trace_id":   "5b8efff798038103d269b633813fc60c",
+	} {
+		v, err := fields.GetValue(key)
+		assert.NoError(t, err, key)
+		assert.Equal(t, expected, v, key)
+	}
+	assert.Equal(t, int64(1496170407154), events[0].Timestamp.UnixNano()/1e6)
+}
+
+func TestTransformMixedRecords(t *testing.T) {
+	p := NewLogsProcessor(pr.DefaultConfig())
+	buf := []byte(logsPayload)
+	assert.NoError(t, p.Validate(buf))
+
+	events, err := p.Transform(buf)
+	assert.NoError(t, err)
+	if !assert.Len(t, events, 2) {
+		return
+	}
+	assert.Equal(t, "error", events[0].Fields["processor"].(common.MapStr)["event"])
+
+	fields := events[1].Fields
+	for key, expected := range map[string]interface{}{
+		"processor.event":   "log",
+		"context.app.name":  "checkout",
+		"log.message":       "order placed",
+		"log.level":         "INFO",
+		"context.tags.cart": float64(2),
+	} {
+		v, err := fields.GetValue(key)
+		assert.NoError(t, err, key)
+		assert.Equal(t, expected, v, key)
+	}
+	assert.Equal(t, int64(1496170407154), events[1].Timestamp.UnixNano()/1e6)
+}
+
+func TestTransformWithoutExceptions(t *testing.T) {
+	p := NewLogsProcessor(pr.DefaultConfig())
+	buf := []byte(`{"resourceLogs": [{"resource": {"attributes": [{"key": "service.name", "value": {"stringValue": "checkout"}}]},
+		"scopeLogs": [{"logRecords": [{"body": {"stringValue": "hello"}}, {"body": {"intValue": 42}}]}]}]}`)
+	assert.NoError(t, p.Validate(buf))
+	events, err := p.Transform(buf)
+	assert.NoError(t, err)
+	if assert.Len(t, events, 2) {
+		for i, message := range []string{"hello", "42"} {
+			v, _ := events[i].Fields.GetValue("log.message")
+			assert.Equal(t, message, v)
+		}
+	}
+}
+
+func TestValidateInvalidService(t *testing.T) {
+	p := NewLogsProcessor(pr.DefaultConfig())
+	buf := []byte(`{"resourceLogs": [{"scopeLogs": [{"logRecords": [{
+		"attributes": [{"key": "exception.message", "value": {"stringValue": "boom"}}]
+	}]}]}]}`)
+	assert.Error(t, p.Validate(buf))
+}
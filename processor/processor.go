@@ -8,7 +8,22 @@ import (
 	"github.com/elastic/beats/libbeat/common"
 )
 
-type NewProcessor func() Processor
+type NewProcessor func(Config) Processor
+
+// Config holds the settings of the server passed to the processors.
+type Config struct {
+	// Enrichers are called for every decoded event, see Enricher.
+	Enrichers []Enricher
+	// MaxCauseDepth limits how many levels of causes of an exception are
+	// kept, deeper causes are dropped.
+	MaxCauseDepth int
+}
+
+// DefaultConfig returns the config the server uses unless configured
+// otherwise.
+func DefaultConfig() Config {
+	return Config{MaxCauseDepth: 5}
+}
 
 const (
 	Backend = iota
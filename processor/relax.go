@@ -0,0 +1,218 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema"
+)
+
+// Maximum number of fields changed in a single document, documents needing
+// more changes are rejected.
+const maxRelaxedFields = 50
+
+// RelaxStats counts the fields changed by Relax.
+type RelaxStats struct {
+	Coerced int
+	Dropped int
+}
+
+// Relax tries to turn a document failing schema validation into a valid one.
+// Properties the schema doesn't allow are dropped, numbers sent as strings
+// are converted to numbers and vice versa. The document is changed one field
+// at a time, until validate succeeds. The original validation error is
+// returned if any other violation is found.
+func Relax(buf []byte, validate func([]byte) error) ([]byte, RelaxStats, error) {
+	var stats RelaxStats
+	origErr := validate(buf)
+	if origErr == nil {
+		return buf, stats, nil
+	}
+
+	var doc interface{}
+	decoder := json.NewDecoder(bytes.NewReader(buf))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return buf, stats, origErr
+	}
+
+	err := origErr
+	for i := 0; i < maxRelaxedFields; i++ {
+		schemaErr, ok := err.(*SchemaError)
+		if !ok || !relaxField(doc, schemaErr.ValidationError, &stats) {
+			return buf, RelaxStats{}, origErr
+		}
+		relaxed, marshalErr := json.Marshal(doc)
+		if marshalErr != nil {
+			return buf, RelaxStats{}, origErr
+		}
+		if err = validate(relaxed); err == nil {
+			return relaxed, stats, nil
+		}
+	}
+	return buf, RelaxStats{}, origErr
+}
+
+// relaxField fixes the first violation found in the causes of err.
+func relaxField(doc interface{}, err *jsonschema.ValidationError, stats *RelaxStats) bool {
+	if len(err.Causes) > 0 {
+		for _, cause := range err.Causes {
+			if relaxField(doc, cause, stats) {
+				return true
+			}
+		}
+		return false
+	}
+
+	parent, key, value, ok := lookupPointer(doc, err.InstancePtr)
+	if !ok {
+		return false
+	}
+	switch {
+	case strings.HasSuffix(err.SchemaPtr, "additionalProperties"):
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		names := additionalPropertyNames(err.Message)
+		for _, name := range names {
+			delete(obj, name)
+		}
+		stats.Dropped += len(names)
+		return len(names) > 0
+	case strings.HasSuffix(err.SchemaPtr, "type") && parent != nil:
+		coerced, ok := coerce(value, expectedTypes(err.Message))
+		if !ok {
+			return false
+		}
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			p[key] = coerced
+		case []interface{}:
+			idx, _ := strconv.Atoi(key)
+			p[idx] = coerced
+		}
+		stats.Coerced++
+		return true
+	}
+	return false
+}
+
+// coerce converts strings holding numbers into numbers and numbers into
+// strings, if the schema expects them.
+func coerce(value interface{}, types []string) (interface{}, bool) {
+	expected := map[string]bool{}
+	for _, t := range types {
+		expected[t] = true
+	}
+	switch v := value.(type) {
+	case string:
+		s := strings.TrimSpace(v)
+		if _, err := strconv.ParseInt(s, 10, 64); err == nil && (expected["integer"] || expected["number"]) {
+			return json.Number(s), true
+		}
+		if _, err := strconv.ParseFloat(s, 64); err == nil && expected["number"] {
+			return json.Number(s), true
+		}
+	case json.Number:
+		if expected["string"] {
+			return v.String(), true
+		}
+	}
+	return nil, false
+}
+
+// lookupPointer resolves a JSON pointer as given in validation errors, e.g.
+// "#/transactions/0/id", returning the value and its parent container.
+func lookupPointer(doc interface{}, ptr string) (parent interface{}, key string, value interface{}, ok bool) {
+	tokens, ok := pointerTokens(ptr)
+	if !ok {
+		return nil, "", nil, false
+	}
+	value = doc
+	for _, token := range tokens {
+		parent, key = value, token
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if value, ok = v[token]; !ok {
+				return nil, "", nil, false
+			}
+		case []interface{}:
+			idx, err := strconv.Atoi(token)
+			if err != nil || idx < 0 || idx >= len(v) {
+				return nil, "", nil, false
+			}
+			value = v[idx]
+		default:
+			return nil, "", nil, false
+		}
+	}
+	return parent, key, value, true
+}
+
+// pointerTokens splits a JSON pointer as given in validation errors into its
+// unescaped tokens.
+func pointerTokens(ptr string) ([]string, bool) {
+	ptr = strings.TrimPrefix(strings.TrimPrefix(ptr, "#"), "/")
+	if ptr == "" {
+		return nil, true
+	}
+	tokens := strings.Split(ptr, "/")
+	for i, token := range tokens {
+		token, err := url.PathUnescape(token)
+		if err != nil {
+			return nil, false
+		}
+		tokens[i] = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+	}
+	return tokens, true
+}
+
+// expectedTypes parses messages like "expected integer or null, but got string".
+func expectedTypes(msg string) []string {
+	if !strings.HasPrefix(msg, "expected ") {
+		return nil
+	}
+	msg = strings.TrimPrefix(msg, "expected ")
+	if idx := strings.Index(msg, ", but got"); idx >= 0 {
+		msg = msg[:idx]
+	}
+	return strings.Split(msg, " or ")
+}
+
+// additionalPropertyNames parses messages like
+// `additionalProperties "foo", "bar" not allowed`.
+func additionalPropertyNames(msg string) []string {
+	var names []string
+	msg = strings.TrimPrefix(msg, "additionalProperties ")
+	for strings.HasPrefix(msg, `"`) {
+		quoted, ok := quotedPrefix(msg)
+		if !ok {
+			break
+		}
+		name, err := strconv.Unquote(quoted)
+		if err != nil {
+			break
+		}
+		names = append(names, name)
+		msg = strings.TrimPrefix(msg[len(quoted):], ", ")
+	}
+	return names
+}
+
+// quotedPrefix returns the double quoted string msg starts with, up to the
+// first quote not escaped by a backslash.
+func quotedPrefix(msg string) (string, bool) {
+	for i := 1; i < len(msg); i++ {
+		switch msg[i] {
+		case '\\':
+			i++
+		case '"':
+			return msg[:i+1], true
+		}
+	}
+	return "", false
+}
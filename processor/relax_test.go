@@ -0,0 +1,77 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var strictSchema = `{
+  "id": "strict",
+  "type": "object",
+  "properties": {
+    "name": {"type": ["string", "null"]},
+    "age": {"type": "integer"},
+    "scores": {"type": "array", "items": {"type": "number"}},
+    "labels": {
+      "type": "object",
+      "patternProperties": {"^[a-z]+$": {}},
+      "additionalProperties": false
+    }
+  },
+  "required": ["name"]
+}`
+
+func TestRelax(t *testing.T) {
+	schema := CreateSchema(strictSchema, "strict")
+	validate := func(buf []byte) error { return Validate(buf, schema) }
+
+	for _, test := range []struct {
+		doc, relaxed string
+		stats        RelaxStats
+	}{
+		{
+			doc:     `{"name": "john", "age": 12}`,
+			relaxed: `{"name": "john", "age": 12}`,
+		},
+		{
+			doc:     `{"name": 42, "age": "12", "scores": [1, "2.5"]}`,
+			relaxed: `{"age":12,"name":"42","scores":[1,2.5]}`,
+			stats:   RelaxStats{Coerced: 3},
+		},
+		{
+			doc:     `{"name": "john", "labels": {"ok": 1, "Not.OK": 2, "a/b": 3}}`,
+			relaxed: `{"labels":{"ok":1},"name":"john"}`,
+			stats:   RelaxStats{Dropped: 2},
+		},
+		{
+			doc:     `{"name": "john", "labels": {"ok": 1, "a\"b": 2, "c\\\"d, \"e": 3}}`,
+			relaxed: `{"labels":{"ok":1},"name":"john"}`,
+			stats:   RelaxStats{Dropped: 2},
+		},
+	} {
+		relaxed, stats, err := Relax([]byte(test.doc), validate)
+		assert.NoError(t, err, test.doc)
+		assert.Equal(t, test.relaxed, string(relaxed))
+		assert.Equal(t, test.stats, stats)
+	}
+
+	for _, invalid := range []string{
+		`{"age": 12}`,
+		`{"name": "john", "age": "twelve"}`,
+		`{"name": "john", "age": "1.5"}`,
+		`{"name": true}`,
+		`not json`,
+	} {
+		relaxed, stats, err := Relax([]byte(invalid), validate)
+		assert.Error(t, err, invalid)
+		assert.Equal(t, invalid, string(relaxed))
+		assert.Equal(t, RelaxStats{}, stats)
+	}
+}
+
+func TestAdditionalPropertyNames(t *testing.T) {
+	assert.Equal(t, []string{"foo", "bar"}, additionalPropertyNames(`additionalProperties "foo", "bar" not allowed`))
+	assert.Equal(t, []string{`a"b`, `c\", "d`}, additionalPropertyNames(`additionalProperties "a\"b", "c\\\", \"d" not allowed`))
+	assert.Empty(t, additionalPropertyNames(`additionalProperties "unterminated not allowed`))
+}
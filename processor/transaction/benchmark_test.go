@@ -3,11 +3,12 @@ package transaction
 import (
 	"testing"
 
+	pr "github.com/elastic/apm-server/processor"
 	"github.com/elastic/apm-server/tests"
 )
 
 func BenchmarkWithFileLoading(b *testing.B) {
-	processor := NewProcessor()
+	processor := NewProcessor(pr.DefaultConfig())
 	for i := 0; i < b.N; i++ {
 		data, _ := tests.LoadValidData("transaction")
 		err := processor.Validate(data)
@@ -19,7 +20,7 @@ func BenchmarkWithFileLoading(b *testing.B) {
 }
 
 func BenchmarkTransactionFileLoadingOnce(b *testing.B) {
-	processor := NewProcessor()
+	processor := NewProcessor(pr.DefaultConfig())
 	data, _ := tests.LoadValidData("transaction")
 	for i := 0; i < b.N; i++ {
 		err := processor.Validate(data)
@@ -29,3 +30,7 @@ func BenchmarkTransactionFileLoadingOnce(b *testing.B) {
 		processor.Transform(data)
 	}
 }
+
+func BenchmarkProcessorAllocs(b *testing.B) {
+	tests.BenchmarkProcessor(b, NewProcessor, "transaction", tests.AllocLimits{Validate: 2000, Transform: 2500})
+}
@@ -1,6 +1,8 @@
 package transaction
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	m "github.com/elastic/apm-server/processor/model"
@@ -14,9 +16,89 @@ type Event struct {
 	Type      string        `json:"type"`
 	Result    *string       `json:"result"`
 	Duration  float64       `json:"duration"`
-	Timestamp time.Time     `json:"timestamp"`
+	Timestamp utility.Time  `json:"timestamp"`
 	Context   common.MapStr `json:"context"`
 	Traces    []Trace       `json:"traces"`
+	Sampled   *bool         `json:"sampled"`
+	SpanCount SpanCount     `json:"span_count"`
+	Marks     common.MapStr `json:"marks"`
+	Faas      *Faas         `json:"faas"`
+}
+
+type SpanCount struct {
+	Dropped SpanCountDropped `json:"dropped"`
+}
+
+type SpanCountDropped struct {
+	Total *int `json:"total"`
+}
+
+func (sc *SpanCount) Transform() common.MapStr {
+	if sc.Dropped.Total == nil {
+		return nil
+	}
+	total := *sc.Dropped.Total
+	if total < 0 {
+		total = 0
+	}
+	return common.MapStr{"dropped": common.MapStr{"total": total}}
+}
+
+// markKeyReplacer replaces the characters that can't be used in field names.
+var markKeyReplacer = strings.NewReplacer(".", "_", "*", "_", `"`, "_")
+
+// transformMarks returns the marks of a transaction, timings of significant
+// events grouped by e.g. navigationTiming. Only the two levels of groups and
+// marks are kept, with numeric offsets in milliseconds, as marks are sent by
+// frontend agents and must not put arbitrary structures into the document.
+func transformMarks(marks common.MapStr) common.MapStr {
+	out := common.MapStr{}
+	for group, val := range marks {
+		groupMarks, ok := val.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		transformed := common.MapStr{}
+		for name, mark := range groupMarks {
+			if ms, ok := mark.(float64); ok {
+				transformed[markKeyReplacer.Replace(name)] = ms
+			}
+		}
+		if len(transformed) > 0 {
+			out[markKeyReplacer.Replace(group)] = transformed
+		}
+	}
+	return out
+}
+
+// Faas holds information about the function invocation a transaction was
+// recorded for, sent by agents running in serverless environments.
+type Faas struct {
+	Coldstart *bool       `json:"coldstart"`
+	Execution *string     `json:"execution"`
+	Trigger   FaasTrigger `json:"trigger"`
+	Name      *string     `json:"name"`
+	Version   *string     `json:"version"`
+}
+
+type FaasTrigger struct {
+	Type *string `json:"type"`
+}
+
+func (f *Faas) Transform() common.MapStr {
+	if f == nil {
+		return nil
+	}
+	enh := utility.NewMapStrEnhancer()
+	faas := common.MapStr{}
+	enh.Add(faas, "coldstart", f.Coldstart)
+	enh.Add(faas, "execution", f.Execution)
+	trigger := common.MapStr{}
+	enh.Add(trigger, "type", f.Trigger.Type)
+	enh.Add(faas, "trigger", trigger)
+	enh.Add(faas, "name", f.Name)
+	enh.Add(faas, "version", f.Version)
+	return faas
 }
 
 func (t *Event) DocType() string {
@@ -30,17 +112,57 @@ func (t *Event) Transform() common.MapStr {
 	enh.Add(tx, "duration", utility.MillisAsMicros(t.Duration))
 	enh.Add(tx, "type", t.Type)
 	enh.Add(tx, "result", t.Result)
+	if statusCode, ok := t.statusCode(); ok {
+		if t.Result == nil {
+			tx["result"] = fmt.Sprintf("HTTP %dxx", statusCode/100)
+		}
+		tx["outcome"] = outcome(statusCode)
+	}
+	enh.Add(tx, "sampled", t.Sampled)
+	enh.Add(tx, "span_count", t.SpanCount.Transform())
+	enh.Add(tx, "marks", transformMarks(t.Marks))
+	enh.Add(tx, "faas", t.Faas.Transform())
 	return tx
 }
 
+// statusCode returns the http response status code from the context, which
+// is used to derive the result and outcome of transactions, as not all agents
+// send a result.
+func (t *Event) statusCode() (int, bool) {
+	val, err := t.Context.GetValue("response.status_code")
+	if err != nil {
+		return 0, false
+	}
+	switch code := val.(type) {
+	case float64:
+		return int(code), true
+	case int:
+		return code, true
+	}
+	return 0, false
+}
+
+func outcome(statusCode int) string {
+	if statusCode >= 500 {
+		return "failure"
+	}
+	return "success"
+}
+
+// isSampled returns false only if the agent explicitly marked the transaction
+// as not sampled, as older agents do not send the flag at all.
+func (t *Event) isSampled() bool {
+	return t.Sampled == nil || *t.Sampled
+}
+
 func (t *Event) Mappings(pa *payload) (time.Time, []m.DocMapping) {
-	return t.Timestamp,
+	return t.Timestamp.Time,
 		[]m.DocMapping{
 			{Key: "processor", Apply: func() common.MapStr {
 				return common.MapStr{"name": processorName, "event": t.DocType()}
 			}},
 			{Key: t.DocType(), Apply: t.Transform},
-			{Key: "context", Apply: func() common.MapStr { return t.Context }},
+			{Key: "context", Apply: func() common.MapStr { return m.TransformContext(t.Context) }},
 			{Key: "context.app", Apply: pa.App.Transform},
 			{Key: "context.system", Apply: pa.System.Transform},
 		}
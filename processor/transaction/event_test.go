@@ -8,6 +8,7 @@ import (
 
 	"time"
 
+	"github.com/elastic/apm-server/utility"
 	"github.com/elastic/beats/libbeat/common"
 )
 
@@ -16,6 +17,9 @@ func TestEventTransform(t *testing.T) {
 
 	id := "123"
 	result := "tx result"
+	sampled := true
+	dropped := 5
+	trigger := "http"
 
 	tests := []struct {
 		Event  Event
@@ -38,19 +42,76 @@ func TestEventTransform(t *testing.T) {
 				Name:      "mytransaction",
 				Type:      "tx",
 				Result:    &result,
-				Timestamp: time.Now(),
+				Timestamp: utility.Time{Time: time.Now()},
 				Duration:  65.98,
 				Context:   common.MapStr{"foo": "bar"},
 				Traces:    []Trace{},
+				Sampled:   &sampled,
+				SpanCount: SpanCount{Dropped: SpanCountDropped{Total: &dropped}},
+			},
+			Output: common.MapStr{
+				"id":         id,
+				"name":       "mytransaction",
+				"type":       "tx",
+				"result":     "tx result",
+				"duration":   common.MapStr{"us": 65980},
+				"sampled":    true,
+				"span_count": common.MapStr{"dropped": common.MapStr{"total": 5}},
+			},
+			Msg: "Full Event",
+		},
+		{
+			Event: Event{
+				Id:      id,
+				Context: common.MapStr{"response": map[string]interface{}{"status_code": 503.0}},
+			},
+			Output: common.MapStr{
+				"id":       id,
+				"name":     "",
+				"type":     "",
+				"duration": common.MapStr{"us": 0},
+				"result":   "HTTP 5xx",
+				"outcome":  "failure",
+			},
+			Msg: "Result derived from status code",
+		},
+		{
+			Event: Event{
+				Id:      id,
+				Result:  &result,
+				Context: common.MapStr{"response": common.MapStr{"status_code": 404}},
 			},
 			Output: common.MapStr{
 				"id":       id,
-				"name":     "mytransaction",
-				"type":     "tx",
+				"name":     "",
+				"type":     "",
+				"duration": common.MapStr{"us": 0},
 				"result":   "tx result",
-				"duration": common.MapStr{"us": 65980},
+				"outcome":  "success",
 			},
-			Msg: "Full Event",
+			Msg: "Result sent by agent",
+		},
+		{
+			Event: Event{
+				Id: id,
+				Faas: &Faas{
+					Coldstart: &sampled,
+					Execution: &id,
+					Trigger:   FaasTrigger{Type: &trigger},
+				},
+			},
+			Output: common.MapStr{
+				"id":       id,
+				"name":     "",
+				"type":     "",
+				"duration": common.MapStr{"us": 0},
+				"faas": common.MapStr{
+					"coldstart": true,
+					"execution": id,
+					"trigger":   common.MapStr{"type": "http"},
+				},
+			},
+			Msg: "Serverless Event",
 		},
 	}
 
@@ -59,3 +120,35 @@ func TestEventTransform(t *testing.T) {
 		assert.Equal(t, test.Output, output, fmt.Sprintf("Failed at idx %v; %s", idx, test.Msg))
 	}
 }
+
+func TestSpanCountTransform(t *testing.T) {
+	negative := -3
+	assert.Nil(t, (&SpanCount{}).Transform())
+	assert.Equal(t, common.MapStr{"dropped": common.MapStr{"total": 0}},
+		(&SpanCount{Dropped: SpanCountDropped{Total: &negative}}).Transform())
+}
+
+func TestTransformMarks(t *testing.T) {
+	marks := common.MapStr{
+		"navigationTiming": map[string]interface{}{
+			"domComplete":     1356.5,
+			"first.paint":     12.0,
+			"nested":          map[string]interface{}{"deep": 1.0},
+			"label":           "fast",
+			`"quoted"*marker`: 3.0,
+		},
+		"my.group":  map[string]interface{}{"rendered": 1400.0},
+		"flat":      12.0,
+		"no_number": map[string]interface{}{"a": "b"},
+	}
+	assert.Equal(t, common.MapStr{
+		"navigationTiming": common.MapStr{
+			"domComplete":     1356.5,
+			"first_paint":     12.0,
+			"_quoted__marker": 3.0,
+		},
+		"my_group": common.MapStr{"rendered": 1400.0},
+	}, transformMarks(marks))
+
+	assert.Equal(t, common.MapStr{}, transformMarks(nil))
+}
@@ -16,5 +16,24 @@ func TestEsDocumentation(t *testing.T) {
 	}
 	processorFn := transaction.NewProcessor
 	tests.TestEventAttrsDocumentedInFields(t, fieldsPaths, processorFn)
-	tests.TestDocumentedFieldsInEvent(t, fieldsPaths, processorFn, set.New("listening", "view traces"))
+	tests.TestDocumentedFieldsInEvent(t, fieldsPaths, processorFn, set.New(
+		"listening",
+		"onboarding.first_seen",
+		"context.truncated",
+		"context.tags_flattened",
+		"context.custom_flattened",
+		"observer",
+		"observer.hostname",
+		"observer.version",
+		"observer.id",
+		"observer.type",
+		"observer.listening",
+		"observer.request_id",
+		"tenant",
+		"tenant.id",
+		"observer.ingest_timestamp",
+		"observer.clock_skew",
+		"observer.clock_skew.us",
+		"view traces",
+	))
 }
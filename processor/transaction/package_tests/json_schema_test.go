@@ -17,6 +17,7 @@ func TestPayloadAttributesInSchema(t *testing.T) {
 		"transactions.traces.stacktrace.vars.key",
 		"transactions.context.request.headers.some-other-header",
 		"transactions.context.request.headers.array",
+		"transactions.context.message.headers.content-type",
 		"transactions.context.request.env.SERVER_SOFTWARE",
 		"transactions.context.request.env.GATEWAY_INTERFACE",
 		"transactions.context.request.body",
@@ -29,6 +30,11 @@ func TestPayloadAttributesInSchema(t *testing.T) {
 		"transactions.context.custom.and_objects.foo",
 		"transactions.context.tags",
 		"transactions.context.tags.organization_uuid",
+		"transactions.marks.navigationTiming",
+		"transactions.marks.navigationTiming.domComplete",
+		"transactions.marks.navigationTiming.domInteractive",
+		"transactions.marks.app.custom",
+		"transactions.marks.app.custom.rendered",
 	)
 	tests.TestPayloadAttributesInSchema(t, "transaction", undocumented, transaction.Schema())
 }
@@ -38,6 +44,23 @@ func TestJsonSchemaKeywordLimitation(t *testing.T) {
 		"./../../../_meta/fields.common.yml",
 		"./../_meta/fields.yml",
 	}
-	exceptions := set.New("processor.event", "processor.name", "context.app.name", "transaction.id", "trace.transaction_id", "listening")
+	exceptions := set.New(
+		"processor.event",
+		"processor.name",
+		"context.app.name",
+		"transaction.id",
+		"trace.transaction_id",
+		"transaction.outcome",
+		"listening",
+		"context.truncated",
+		"context.tags_flattened",
+		"observer.hostname",
+		"observer.version",
+		"observer.id",
+		"observer.type",
+		"observer.listening",
+		"observer.request_id",
+		"tenant.id",
+	)
 	tests.TestJsonSchemaKeywordLimitation(t, fieldsPaths, transaction.Schema(), exceptions)
 }
@@ -5,6 +5,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	pr "github.com/elastic/apm-server/processor"
 	"github.com/elastic/apm-server/processor/transaction"
 	"github.com/elastic/apm-server/tests"
 )
@@ -19,15 +20,17 @@ func TestTransactionProcessorOK(t *testing.T) {
 		{Name: "TestProcessTransactionMinimalTrace", Path: "tests/data/valid/transaction/minimal_trace.json"},
 		{Name: "TestProcessTransactionMinimalApp", Path: "tests/data/valid/transaction/minimal_app.json"},
 		{Name: "TestProcessTransactionEmpty", Path: "tests/data/valid/transaction/transaction_empty_values.json"},
+		{Name: "TestProcessTransactionTimestampMicros", Path: "tests/data/valid/transaction/timestamp_micros.json"},
+		{Name: "TestProcessTransactionUnsampled", Path: "tests/data/valid/transaction/unsampled.json"},
 	}
-	tests.TestProcessRequests(t, transaction.NewProcessor(), requestInfo)
+	tests.TestProcessRequests(t, transaction.NewProcessor(pr.DefaultConfig()), requestInfo)
 }
 
 // ensure invalid documents fail the json schema validation already
 func TestTransactionProcessorValidationFailed(t *testing.T) {
 	data, err := tests.LoadInvalidData("transaction")
 	assert.Nil(t, err)
-	p := transaction.NewProcessor()
+	p := transaction.NewProcessor(pr.DefaultConfig())
 	err = p.Validate(data)
 	assert.NotNil(t, err)
 }
@@ -4,6 +4,7 @@ import (
 	pr "github.com/elastic/apm-server/processor"
 	m "github.com/elastic/apm-server/processor/model"
 	"github.com/elastic/beats/libbeat/beat"
+	"github.com/elastic/beats/libbeat/common"
 	"github.com/elastic/beats/libbeat/logp"
 	"github.com/elastic/beats/libbeat/monitoring"
 )
@@ -11,6 +12,7 @@ import (
 var (
 	transactionCounter = monitoring.NewInt(transactionMetrics, "counter")
 	traceCounter       = monitoring.NewInt(transactionMetrics, "traces")
+	unsampledTraces    = monitoring.NewInt(transactionMetrics, "unsampled_traces")
 )
 
 type payload struct {
@@ -19,21 +21,43 @@ type payload struct {
 	Events []Event   `json:"transactions"`
 }
 
-func (pa *payload) transform() []beat.Event {
+func (pa *payload) transform(config pr.Config) []beat.Event {
 	var events []beat.Event
 
 	logp.Debug("transaction", "Transform transaction events: events=%d, app=%s, agent=%s:%s", len(pa.Events), pa.App.Name, pa.App.Agent.Name, pa.App.Agent.Version)
 
 	transactionCounter.Add(int64(len(pa.Events)))
-	for _, tx := range pa.Events {
-
-		events = append(events, pr.CreateDoc(tx.Mappings(pa)))
+	meta := pa.metadata()
+	txFields := make([]common.MapStr, len(pa.Events))
+	trFields := make([][]common.MapStr, len(pa.Events))
+	for i := range pa.Events {
+		tx := &pa.Events[i]
+		txFields[i] = pr.Enrich(config.Enrichers, tx, meta)
+		trFields[i] = make([]common.MapStr, len(tx.Traces))
+		for j := range tx.Traces {
+			trFields[i][j] = pr.Enrich(config.Enrichers, &tx.Traces[j], meta)
+		}
+	}
+	for i := range pa.Events {
+		tx := &pa.Events[i]
+		events = append(events, pr.AddFields(pr.CreateDoc(tx.Mappings(pa)), txFields[i]))
 
 		traceCounter.Add(int64(len(tx.Traces)))
-		for _, tr := range tx.Traces {
-			events = append(events, pr.CreateDoc(tr.Mappings(pa, tx)))
+		if !tx.isSampled() {
+			// the transaction is kept for its duration and result, the
+			// details of unsampled transactions are not indexed
+			unsampledTraces.Add(int64(len(tx.Traces)))
+			continue
+		}
+		for j := range tx.Traces {
+			tr := &tx.Traces[j]
+			events = append(events, pr.AddFields(pr.CreateDoc(tr.Mappings(pa, *tx)), trFields[i][j]))
 		}
 	}
 
 	return events
 }
+
+func (pa *payload) metadata() pr.Metadata {
+	return pr.Metadata{App: &pa.App, System: pa.System}
+}
@@ -8,7 +8,9 @@ import (
 
 	"time"
 
+	pr "github.com/elastic/apm-server/processor"
 	m "github.com/elastic/apm-server/processor/model"
+	"github.com/elastic/apm-server/utility"
 	"github.com/elastic/beats/libbeat/common"
 )
 
@@ -25,7 +27,7 @@ func TestPayloadTransform(t *testing.T) {
 		Platform:     &platform,
 	}
 
-	txValid := Event{Timestamp: timestamp}
+	txValid := Event{Timestamp: utility.Time{Time: timestamp}}
 	txValidEs := common.MapStr{
 		"context": common.MapStr{
 			"app": common.MapStr{
@@ -68,7 +70,7 @@ func TestPayloadTransform(t *testing.T) {
 			},
 		},
 	}
-	txWithContext := Event{Timestamp: timestamp, Context: common.MapStr{"foo": "bar", "user": common.MapStr{"id": "55"}}}
+	txWithContext := Event{Timestamp: utility.Time{Time: timestamp}, Context: common.MapStr{"foo": "bar", "user": common.MapStr{"id": "55"}}}
 	txWithContextEs := common.MapStr{
 		"processor": common.MapStr{
 			"event": "transaction",
@@ -94,7 +96,28 @@ func TestPayloadTransform(t *testing.T) {
 		},
 	}
 	traces := []Trace{{}}
-	txValidWithTrace := Event{Timestamp: timestamp, Traces: traces}
+	txValidWithTrace := Event{Timestamp: utility.Time{Time: timestamp}, Traces: traces}
+	unsampled := false
+	txUnsampled := Event{Timestamp: utility.Time{Time: timestamp}, Traces: traces, Sampled: &unsampled}
+	txUnsampledEs := common.MapStr{
+		"context": common.MapStr{
+			"app": common.MapStr{
+				"name":  "myapp",
+				"agent": common.MapStr{"name": "", "version": ""},
+			},
+		},
+		"processor": common.MapStr{
+			"event": "transaction",
+			"name":  "transaction",
+		},
+		"transaction": common.MapStr{
+			"duration": common.MapStr{"us": 0},
+			"id":       "",
+			"name":     "",
+			"type":     "",
+			"sampled":  false,
+		},
+	}
 	traceEs := common.MapStr{
 		"context": common.MapStr{
 			"app": common.MapStr{
@@ -151,10 +174,19 @@ func TestPayloadTransform(t *testing.T) {
 			Output: []common.MapStr{txWithContextEs},
 			Msg:    "Payload with App, System and Event with context",
 		},
+		{
+			Payload: payload{
+				App:    app,
+				Events: []Event{txUnsampled},
+			},
+			Output: []common.MapStr{txUnsampledEs},
+			Msg:    "Payload with unsampled Event",
+		},
 	}
 
 	for idx, test := range tests {
-		outputEvents := test.Payload.transform()
+		outputEvents := test.Payload.transform(pr.DefaultConfig())
+		assert.Len(t, outputEvents, len(test.Output), fmt.Sprintf("Failed at idx %v; %s", idx, test.Msg))
 		for j, outputEvent := range outputEvents {
 			assert.Equal(t, test.Output[j], outputEvent.Fields, fmt.Sprintf("Failed at idx %v; %s", idx, test.Msg))
 			assert.Equal(t, timestamp, outputEvent.Timestamp)
@@ -23,12 +23,13 @@ const (
 
 var schema = pr.CreateSchema(transactionSchema, processorName)
 
-func NewProcessor() pr.Processor {
-	return &processor{schema: schema}
+func NewProcessor(config pr.Config) pr.Processor {
+	return &processor{schema: schema, config: config}
 }
 
 type processor struct {
 	schema *jsonschema.Schema
+	config pr.Config
 }
 
 func (p *processor) Validate(buf []byte) error {
@@ -48,7 +49,7 @@ func (p *processor) Transform(buf []byte) ([]beat.Event, error) {
 		return nil, err
 	}
 
-	return pa.transform(), nil
+	return pa.transform(p.config), nil
 }
 
 func (p *processor) Name() string {
@@ -69,6 +69,16 @@ var transactionSchema = `{
             "pattern": "^[a-zA-Z0-9 _-]+$",
             "maxLength": 1024
         },
+        "node": {
+            "type": ["object", "null"],
+            "properties": {
+                "configured_name": {
+                    "description": "Name of this instance of the app, set in the agent configuration. Used to distinguish multiple instances of the same app.",
+                    "type": ["string", "null"],
+                    "maxLength": 1024
+                }
+            }
+        },
         "pid": {
             "type": ["number", "null"]
         },
@@ -110,10 +120,27 @@ var transactionSchema = `{
             "maxLength": 1024
         },
         "hostname": {
-            "description": "Hostname of the system the agent is running on.",
+            "description": "Hostname of the system the agent is running on. Deprecated, use detected_hostname and configured_hostname instead.",
+            "type": ["string", "null"],
+            "maxLength": 1024
+        },
+        "detected_hostname": {
+            "description": "Full hostname of the system the agent is running on, as detected by the agent.",
+            "type": ["string", "null"],
+            "maxLength": 1024
+        },
+        "configured_hostname": {
+            "description": "Hostname of the system the agent is running on, as configured by the user.",
             "type": ["string", "null"],
             "maxLength": 1024
         },
+        "detected_ip": {
+            "description": "IP addresses of the system the agent is running on, as detected by the agent. Invalid addresses are dropped.",
+            "type": ["array", "null"],
+            "items": {
+                "type": "string"
+            }
+        },
         "platform": {
             "description": "Name of the system platform the agent is running on.",
             "type": ["string", "null"],
@@ -145,6 +172,95 @@ var transactionSchema = `{
             },
             "additionalProperties": false
         },
+        "message": {
+            "description": "Details of the message the event was triggered by, if received from a messaging system like Kafka, RabbitMQ or SQS.",
+            "type": ["object", "null"],
+            "properties": {
+                "queue": {
+                    "type": ["object", "null"],
+                    "properties": {
+                        "name": {
+                            "description": "Name of the queue or topic the message was received from.",
+                            "type": ["string", "null"],
+                            "maxLength": 1024
+                        }
+                    }
+                },
+                "routing_key": {
+                    "description": "Routing key of the message, as used by RabbitMQ.",
+                    "type": ["string", "null"],
+                    "maxLength": 1024
+                },
+                "age": {
+                    "type": ["object", "null"],
+                    "properties": {
+                        "ms": {
+                            "description": "Time elapsed between sending the message and receiving it, in ms.",
+                            "type": ["integer", "null"]
+                        }
+                    }
+                },
+                "body": {
+                    "description": "The message body.",
+                    "type": ["string", "null"]
+                },
+                "headers": {
+                    "description": "The message headers.",
+                    "type": ["object", "null"]
+                }
+            }
+        },
+        "network": {
+            "description": "Network connection of the device the event was recorded on, sent by RUM and mobile agents.",
+            "type": ["object", "null"],
+            "properties": {
+                "connection": {
+                    "type": ["object", "null"],
+                    "properties": {
+                        "type": {
+                            "description": "Type of the connection.",
+                            "type": ["string", "null"],
+                            "enum": ["wifi", "cell", "wired", "unavailable", "unknown", null],
+                            "maxLength": 1024
+                        },
+                        "subtype": {
+                            "description": "Subtype of the connection, e.g. the radio technology like 'LTE' or '5G' for cell connections.",
+                            "type": ["string", "null"],
+                            "maxLength": 1024
+                        },
+                        "carrier": {
+                            "description": "Mobile network operator of cell connections.",
+                            "type": ["object", "null"],
+                            "properties": {
+                                "name": {
+                                    "description": "Name of the carrier.",
+                                    "type": ["string", "null"],
+                                    "maxLength": 1024
+                                },
+                                "mcc": {
+                                    "description": "Mobile country code.",
+                                    "type": ["string", "null"],
+                                    "pattern": "^[0-9]{3}$",
+                                    "maxLength": 1024
+                                },
+                                "mnc": {
+                                    "description": "Mobile network code.",
+                                    "type": ["string", "null"],
+                                    "pattern": "^[0-9]{2,3}$",
+                                    "maxLength": 1024
+                                },
+                                "icc": {
+                                    "description": "ISO 3166-1 alpha-2 country code of the carrier.",
+                                    "type": ["string", "null"],
+                                    "pattern": "^[a-zA-Z]{2}$",
+                                    "maxLength": 1024
+                                }
+                            }
+                        }
+                    }
+                }
+            }
+        },
         "response": {
             "type": ["object", "null"],
             "properties": {
@@ -164,6 +280,10 @@ var transactionSchema = `{
                 },
                 "status_code": {
                     "type": ["number", "null"]
+                },
+                "transfer_size": {
+                    "description": "Size of the response in bytes, including headers.",
+                    "type": ["integer", "null"]
                 }
             }
         },
@@ -264,15 +384,12 @@ var transactionSchema = `{
     "required": ["url", "method"]
         },
         "tags": {
+            "description": "A flat mapping of user-defined tags with string, boolean or number values. Dots, asterisks and double quotes in keys are replaced by underscores.",
             "type": ["object", "null"],
-            "regexProperties": true,
-            "patternProperties": {
-                "^[^.*\"]*$": {
-                    "type": "string",
-                    "maxLength": 1024
-                }
-            },
-            "additionalProperties": false
+            "additionalProperties": {
+                "type": ["string", "boolean", "number"],
+                "maxLength": 1024
+            }
         },
         "user": {
                 "$schema": "http://json-schema.org/draft-04/schema#",
@@ -301,6 +418,41 @@ var transactionSchema = `{
             "type": "number",
             "description": "How long the transaction took to complete, in ms with 3 decimal points"
         },
+        "faas": {
+            "type": ["object", "null"],
+            "description": "Function as a Service information, for transactions recorded in serverless environments",
+            "properties": {
+                "coldstart": {
+                    "type": ["boolean", "null"],
+                    "description": "Indicates whether the function invocation was a cold start, i.e. the first one of a new function instance."
+                },
+                "execution": {
+                    "type": ["string", "null"],
+                    "description": "The request id of the function invocation.",
+                    "maxLength": 1024
+                },
+                "trigger": {
+                    "type": ["object", "null"],
+                    "properties": {
+                        "type": {
+                            "type": ["string", "null"],
+                            "description": "The trigger type of the invocation, e.g. 'http', 'pubsub', 'datasource', 'timer' or 'other'.",
+                            "maxLength": 1024
+                        }
+                    }
+                },
+                "name": {
+                    "type": ["string", "null"],
+                    "description": "The name of the function.",
+                    "maxLength": 1024
+                },
+                "version": {
+                    "type": ["string", "null"],
+                    "description": "The version of the function.",
+                    "maxLength": 1024
+                }
+            }
+        },
         "id": {
             "type": "string",
             "description": "UUID for the transaction, referred by its traces",
@@ -312,15 +464,43 @@ var transactionSchema = `{
             "maxLength": 1024
         },
         "result": {
-          	"type": "string",
-          	"description": "The result of the transaction. HTTP status code for HTTP-related transactions.",
+          	"type": ["string", "null"],
+          	"description": "The result of the transaction. HTTP status code for HTTP-related transactions. If missing, it is derived from context.response.status_code, e.g. 'HTTP 5xx'.",
             "maxLength": 1024
         },
+        "sampled": {
+            "type": ["boolean", "null"],
+            "description": "Transactions that are 'sampled' will include all available information. Transactions that are not sampled will not have 'traces' or 'context'. Defaults to true."
+        },
+        "span_count": {
+            "type": ["object", "null"],
+            "properties": {
+                "dropped": {
+                    "type": ["object", "null"],
+                    "properties": {
+                        "total": {
+                            "type": ["integer", "null"],
+                            "description": "Number of traces that have been dropped by the agent recording the transaction."
+                        }
+                    }
+                }
+            }
+        },
+        "marks": {
+            "type": ["object", "null"],
+            "description": "Timings of significant events during the transaction, in milliseconds relative to its start, grouped by e.g. 'navigationTiming'. Dots, asterisks and quotes in group and mark names are replaced by underscores.",
+            "additionalProperties": {
+                "type": ["object", "null"],
+                "additionalProperties": {
+                    "type": ["number", "null"]
+                }
+            }
+        },
         "timestamp": {
-            "type": "string",
+            "type": ["string", "integer"],
             "pattern": "Z$",
             "format": "date-time",
-            "description": "Recorded time of the transaction, UTC based and formatted as YYYY-MM-DDTHH:mm:ss.sssZ"
+            "description": "Recorded time of the transaction, UTC based and formatted as YYYY-MM-DDTHH:mm:ss.sssZ, or as number of microseconds since epoch"
         },
         "traces": {
             "type": ["array", "null"],
@@ -356,6 +536,10 @@ var transactionSchema = `{
                         "user": {
                            "type": ["string", "null"],
                            "description": "Username for accessing database"
+                        },
+                        "rows_affected": {
+                           "type": ["integer", "null"],
+                           "description": "Number of rows affected by the statement"
                         }
                     }
                 }
@@ -441,7 +625,17 @@ var transactionSchema = `{
         },
         "type": {
             "type": "string",
-            "description": "Keyword of specific relevance in the app's domain (eg: 'db.postgresql.query', 'template.erb', etc)",
+            "description": "Keyword of specific relevance in the app's domain (eg: 'db', 'template', etc). Legacy dotted types like 'db.postgresql.query' are split into type, subtype and action",
+            "maxLength": 1024
+        },
+        "subtype": {
+            "type": ["string", "null"],
+            "description": "A further sub-division of the type (eg: 'postgresql', 'elasticsearch')",
+            "maxLength": 1024
+        },
+        "action": {
+            "type": ["string", "null"],
+            "description": "The specific kind of event within the subtype represented by the trace (eg: 'query', 'connect')",
             "maxLength": 1024
         }
     },
@@ -460,7 +654,7 @@ var transactionSchema = `{
             "maxLength": 1024
         }
     },
-    "required": ["id", "name", "duration", "type", "timestamp", "result"]
+    "required": ["id", "name", "duration", "type", "timestamp"]
             },
             "minItems": 1
         }
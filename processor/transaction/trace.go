@@ -1,6 +1,7 @@
 package transaction
 
 import (
+	"strings"
 	"time"
 
 	m "github.com/elastic/apm-server/processor/model"
@@ -12,6 +13,8 @@ type Trace struct {
 	Id               *int               `json:"id"`
 	Name             string             `json:"name"`
 	Type             string             `json:"type"`
+	Subtype          *string            `json:"subtype"`
+	Action           *string            `json:"action"`
 	Start            float64            `json:"start"`
 	Duration         float64            `json:"duration"`
 	StacktraceFrames m.StacktraceFrames `json:"stacktrace"`
@@ -31,7 +34,10 @@ func (t *Trace) Transform(transactionId string) common.MapStr {
 	enhancer.Add(tr, "id", t.Id)
 	enhancer.Add(tr, "transaction_id", transactionId)
 	enhancer.Add(tr, "name", t.Name)
-	enhancer.Add(tr, "type", t.Type)
+	typ, subtype, action := t.types()
+	enhancer.Add(tr, "type", typ)
+	enhancer.Add(tr, "subtype", subtype)
+	enhancer.Add(tr, "action", action)
 	enhancer.Add(tr, "start", utility.MillisAsMicros(t.Start))
 	enhancer.Add(tr, "duration", utility.MillisAsMicros(t.Duration))
 	enhancer.Add(tr, "parent", t.Parent)
@@ -42,14 +48,30 @@ func (t *Trace) Transform(transactionId string) common.MapStr {
 	return tr
 }
 
+// types returns the type, subtype and action of the trace. Agents used to
+// send them as one dotted type, e.g. db.postgresql.query, which is split up
+// so that both forms result in the same fields. Explicitly set subtypes and
+// actions take precedence over the ones from a dotted type.
+func (t *Trace) types() (string, *string, *string) {
+	subtype, action := t.Subtype, t.Action
+	parts := strings.SplitN(t.Type, ".", 3)
+	if len(parts) > 1 && subtype == nil {
+		subtype = &parts[1]
+	}
+	if len(parts) > 2 && action == nil {
+		action = &parts[2]
+	}
+	return parts[0], subtype, action
+}
+
 func (t *Trace) Mappings(pa *payload, tx Event) (time.Time, []m.DocMapping) {
-	return tx.Timestamp,
+	return tx.Timestamp.Time,
 		[]m.DocMapping{
 			{Key: "processor", Apply: func() common.MapStr {
 				return common.MapStr{"name": processorName, "event": t.DocType()}
 			}},
 			{Key: t.DocType(), Apply: func() common.MapStr { return t.Transform(tx.Id) }},
-			{Key: "context", Apply: func() common.MapStr { return t.Context }},
+			{Key: "context", Apply: func() common.MapStr { return m.TransformContext(t.Context) }},
 			{Key: "context.app", Apply: pa.App.MinimalTransform},
 		}
 }
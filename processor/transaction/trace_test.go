@@ -96,3 +96,50 @@ func TestTraceTransform(t *testing.T) {
 		assert.Equal(t, test.Output, output, fmt.Sprintf("Failed at idx %v; %s", idx, test.Msg))
 	}
 }
+
+func TestTraceTransformTypes(t *testing.T) {
+	postgresql, query, connect := "postgresql", "query", "connect"
+	tests := []struct {
+		Trace  Trace
+		Output common.MapStr
+		Msg    string
+	}{
+		{
+			Trace:  Trace{Type: "db.postgresql.query"},
+			Output: common.MapStr{"type": "db", "subtype": "postgresql", "action": "query"},
+			Msg:    "Dotted type",
+		},
+		{
+			Trace:  Trace{Type: "db", Subtype: &postgresql, Action: &query},
+			Output: common.MapStr{"type": "db", "subtype": "postgresql", "action": "query"},
+			Msg:    "Explicit subtype and action",
+		},
+		{
+			Trace:  Trace{Type: "template.erb"},
+			Output: common.MapStr{"type": "template", "subtype": "erb"},
+			Msg:    "Dotted type without action",
+		},
+		{
+			Trace:  Trace{Type: "ext.http.get.json"},
+			Output: common.MapStr{"type": "ext", "subtype": "http", "action": "get.json"},
+			Msg:    "Action containing dots",
+		},
+		{
+			Trace:  Trace{Type: "db.postgresql.query", Action: &connect},
+			Output: common.MapStr{"type": "db", "subtype": "postgresql", "action": "connect"},
+			Msg:    "Explicit action takes precedence",
+		},
+		{
+			Trace:  Trace{Type: "request"},
+			Output: common.MapStr{"type": "request"},
+			Msg:    "Plain type",
+		},
+	}
+
+	for idx, test := range tests {
+		output := test.Trace.Transform("123")
+		for _, key := range []string{"type", "subtype", "action"} {
+			assert.Equal(t, test.Output[key], output[key], fmt.Sprintf("Failed at idx %v; %s: %s", idx, test.Msg, key))
+		}
+	}
+}
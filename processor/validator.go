@@ -3,6 +3,7 @@ package processor
 import (
 	"bytes"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/santhosh-tekuri/jsonschema"
@@ -20,10 +21,57 @@ func CreateSchema(schemaData string, url string) *jsonschema.Schema {
 	return schema
 }
 
+// SchemaError is returned for documents that are valid JSON, but don't match
+// the schema.
+type SchemaError struct {
+	*jsonschema.ValidationError
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("Problem validating JSON document against schema: %v", e.ValidationError)
+}
+
 func Validate(buf []byte, schema *jsonschema.Schema) error {
 	reader := bytes.NewReader(buf)
 	if err := schema.Validate(reader); err != nil {
+		if ve, ok := err.(*jsonschema.ValidationError); ok {
+			return &SchemaError{ve}
+		}
 		return fmt.Errorf("Problem validating JSON document against schema: %v", err)
 	}
 	return nil
 }
+
+// UnknownFields returns the paths of the properties a schema error reports as
+// not allowed, e.g. "transactions.context.custom.foo". Array indices are left
+// out.
+func UnknownFields(err error) []string {
+	schemaErr, ok := err.(*SchemaError)
+	if !ok {
+		return nil
+	}
+	return unknownFields(schemaErr.ValidationError, nil)
+}
+
+func unknownFields(err *jsonschema.ValidationError, fields []string) []string {
+	for _, cause := range err.Causes {
+		fields = unknownFields(cause, fields)
+	}
+	if len(err.Causes) > 0 || !strings.HasSuffix(err.SchemaPtr, "additionalProperties") {
+		return fields
+	}
+	tokens, ok := pointerTokens(err.InstancePtr)
+	if !ok {
+		return fields
+	}
+	var path []string
+	for _, token := range tokens {
+		if _, err := strconv.Atoi(token); err != nil {
+			path = append(path, token)
+		}
+	}
+	for _, name := range additionalPropertyNames(err.Message) {
+		fields = append(fields, strings.Join(append(path, name), "."))
+	}
+	return fields
+}
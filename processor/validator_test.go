@@ -72,3 +72,21 @@ type Person struct {
 func (p Person) Transform() []beat.Event {
 	return nil
 }
+
+func TestUnknownFields(t *testing.T) {
+	schema := CreateSchema(`{
+  "id": "closed",
+  "type": "object",
+  "properties": {
+    "items": {
+      "type": "array",
+      "items": {"type": "object", "properties": {"name": {}}, "additionalProperties": false}
+    }
+  }
+}`, "closed")
+	err := Validate([]byte(`{"items": [{"name": "a"}, {"name": "b", "a/b": 1}]}`), schema)
+	assert.Equal(t, []string{"items.a/b"}, UnknownFields(err))
+
+	assert.Nil(t, UnknownFields(Validate([]byte(`{"items": []}`), schema)))
+	assert.Nil(t, UnknownFields(Validate([]byte(`{`), schema)))
+}
@@ -14,6 +14,7 @@ func main() {
 	schemaPaths := [][]string{
 		[]string{"errors/payload.json", "processor/error/schema.go", "errorSchema", "error"},
 		[]string{"transactions/payload.json", "processor/transaction/schema.go", "transactionSchema", "transaction"},
+		[]string{"logs/payload.json", "processor/log/schema.go", "logSchema", "log"},
 	}
 	for _, schemaInfo := range schemaPaths {
 		file := filepath.Join(filepath.Dir(basePath), schemaInfo[0])
@@ -70,6 +70,12 @@ func buildPath(dataType string, validData bool) (string, error) {
 		} else {
 			file = "transaction_payload/no_app.json"
 		}
+	case "log":
+		if validData {
+			file = "log/payload.json"
+		} else {
+			file = "log_payload/no_app.json"
+		}
 	default:
 		return "", errors.New("data type not specified.")
 	}
@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/elastic/apm-server/processor"
+)
+
+// AllocLimits are the maximum number of allocations per operation allowed
+// when benchmarking a processor.
+type AllocLimits struct {
+	Validate  uint64
+	Transform uint64
+}
+
+// BenchmarkProcessor benchmarks validating and transforming the valid test
+// payload of the given type. Benchmarks fail if an operation allocates more
+// often than allowed on average, which catches regressions like schemas or
+// regular expressions being compiled per request instead of once.
+func BenchmarkProcessor(b *testing.B, newProcessor processor.NewProcessor, dataType string, limits AllocLimits) {
+	data, err := LoadValidData(dataType)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("Validate", func(b *testing.B) {
+		p := newProcessor(processor.DefaultConfig())
+		benchmarkAllocs(b, limits.Validate, func() {
+			if err := p.Validate(data); err != nil {
+				b.Fatal(err)
+			}
+		})
+	})
+	b.Run("Transform", func(b *testing.B) {
+		p := newProcessor(processor.DefaultConfig())
+		benchmarkAllocs(b, limits.Transform, func() {
+			if _, err := p.Transform(data); err != nil {
+				b.Fatal(err)
+			}
+		})
+	})
+}
+
+func benchmarkAllocs(b *testing.B, limit uint64, fn func()) {
+	b.ReportAllocs()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fn()
+	}
+	b.StopTimer()
+	runtime.ReadMemStats(&after)
+	if allocs := (after.Mallocs - before.Mallocs) / uint64(b.N); allocs > limit {
+		b.Fatalf("%d allocations per operation, expected at most %d", allocs, limit)
+	}
+}
@@ -31,11 +31,15 @@ func TestEventAttrsDocumentedInFields(t *testing.T, fieldPaths []string, fn proc
 		"context.request.env",
 		"context.request.body",
 		"context.response.headers",
+		"context.message.headers",
+		"context.message.body",
 		"context.app.argv",
 		"error.exception.attributes",
 		"error.exception.stacktrace",
+		"error.exception.cause",
 		"error.log.stacktrace",
 		"trace.stacktrace",
+		"transaction.marks",
 		"context.db",
 		"context.db.statement",
 		"context.db.type",
@@ -64,7 +68,7 @@ func TestDocumentedFieldsInEvent(t *testing.T, fieldPaths []string, fn processor
 }
 
 func fetchEventNames(fn processor.NewProcessor, blacklisted *set.Set) (*set.Set, error) {
-	p := fn()
+	p := fn(processor.DefaultConfig())
 	data, _ := LoadValidData(p.Name())
 	err := p.Validate(data)
 	if err != nil {
@@ -0,0 +1,45 @@
+// Package fuzz provides the entry point for fuzzing the intake processors with
+// go-fuzz:
+//
+//	go-fuzz-build github.com/elastic/apm-server/tests/fuzz
+//	go-fuzz -bin fuzz-fuzz.zip -workdir build/fuzz
+//
+// Copying tests/data/valid into build/fuzz/corpus gives the fuzzer a head
+// start.
+package fuzz
+
+import (
+	"fmt"
+
+	"github.com/elastic/apm-server/processor"
+	perr "github.com/elastic/apm-server/processor/error"
+	"github.com/elastic/apm-server/processor/log"
+	"github.com/elastic/apm-server/processor/otlp"
+	"github.com/elastic/apm-server/processor/transaction"
+)
+
+var processors = map[string]processor.NewProcessor{
+	"error":       perr.NewProcessor,
+	"transaction": transaction.NewProcessor,
+	"log":         log.NewProcessor,
+	"otlp":        otlp.NewLogsProcessor,
+}
+
+// Fuzz validates and transforms data with every intake processor. Processors
+// must neither panic on arbitrary input nor fail to transform payloads they
+// consider valid, as the server responds with 202 Accepted based on the
+// validation result.
+func Fuzz(data []byte) int {
+	interesting := 0
+	for name, newProcessor := range processors {
+		p := newProcessor(processor.DefaultConfig())
+		if err := p.Validate(data); err != nil {
+			continue
+		}
+		if _, err := p.Transform(data); err != nil {
+			panic(fmt.Sprintf("%s processor failed to transform valid payload: %s", name, err))
+		}
+		interesting = 1
+	}
+	return interesting
+}
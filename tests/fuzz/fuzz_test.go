@@ -0,0 +1,42 @@
+package fuzz
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestFuzzCorpus runs the fuzz function on the test payloads, which are also
+// the initial corpus for go-fuzz.
+func TestFuzzCorpus(t *testing.T) {
+	var files int
+	err := filepath.Walk(filepath.Join("..", "data"), func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(path) != ".json" {
+			return err
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		files++
+		assert.NotPanics(t, func() { Fuzz(data) }, path)
+		for i := range data {
+			assert.NotPanics(t, func() { Fuzz(data[:i]) }, "%s truncated to %d bytes", path, i)
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.NotZero(t, files)
+
+	assert.Equal(t, 1, Fuzz(mustRead(t, "../data/valid/error/payload.json")))
+	assert.Equal(t, 0, Fuzz([]byte(`not json`)))
+}
+
+func mustRead(t *testing.T, path string) []byte {
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	return data
+}
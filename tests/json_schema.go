@@ -59,10 +59,13 @@ func TestJsonSchemaKeywordLimitation(t *testing.T, fieldPaths []string, schema s
 	mapping := []Mapping{
 		{"errors.context", "context"},
 		{"transactions.context", "context"},
+		{"logs.context", "context"},
 		{"errors", "error"},
 		{"transactions.traces", "trace"},
 		{"transactions", "transaction"},
+		{"logs", "log"},
 		{"app", "context.app"},
+		{"system.detected_hostname", "context.system.hostname"},
 		{"system", "context.system"},
 	}
 
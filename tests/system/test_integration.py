@@ -64,6 +64,37 @@ class Test(ElasticTest):
                            "query": {"term": {"processor.event": "error"}}})
         assert rs['count'] == 4, "found {} documents".format(rs['count'])
 
+    @unittest.skipUnless(INTEGRATION_TESTS, "integration test")
+    def test_tags_mapped_by_type(self):
+        """
+        This test sends a transaction with tags of all allowed types and
+        verifies each tag is mapped according to its type.
+        """
+        payload = self.get_transaction_payload()
+        payload['transactions'] = payload['transactions'][:1]
+        payload['transactions'][0]['traces'] = []
+        payload['transactions'][0]['context'] = {
+            "tags": {"string": "value", "number": 12, "boolean": True}}
+        r = requests.post(self.transactions_url, json=payload)
+        assert r.status_code == 202, r.text
+
+        self.wait_until(lambda: self.es.indices.exists(self.index_name))
+        self.es.indices.refresh(index=self.index_name)
+        self.wait_until(
+            lambda: (self.es.count(index=self.index_name, body={
+                "query": {"term": {"processor.event": "transaction"}}})['count'] == 1)
+        )
+
+        mappings = self.es.indices.get_field_mapping(
+            index=self.index_name, fields="context.tags.*")[self.index_name]['mappings']
+        types = {}
+        for doc_type in mappings.values():
+            for name, field in doc_type.items():
+                types[name] = list(field['mapping'].values())[0]['type']
+        assert types == {"context.tags.string": "keyword",
+                         "context.tags.number": "long",
+                         "context.tags.boolean": "boolean"}, types
+
     def load_docs_with_template(self, data_path, endpoint, expected_events_count):
 
         payload = json.loads(open(data_path).read())
@@ -105,3 +136,44 @@ class Test(ElasticTest):
             for r in replace:
                 log = log.replace(r, "")
         self.assertNotRegexpMatches(log, "ERR|WARN")
+
+
+class ContextLimitsTest(ElasticTest):
+
+    def config(self):
+        cfg = super(ContextLimitsTest, self).config()
+        cfg.update({"max_custom_size": 30})
+        return cfg
+
+    @unittest.skipUnless(INTEGRATION_TESTS, "integration test")
+    def test_truncated_context_indexed(self):
+        """
+        This test sends an error with a small and one with a truncated custom
+        context and request body and verifies both are indexed against the
+        same mapping.
+        """
+        payload = json.loads(open(os.path.abspath(os.path.join(
+            self.beat_path, 'tests', 'data', 'valid', 'error', 'payload.json'))).read())
+        error = payload['errors'][0]
+        small = dict(error, context={"custom": {"a": 1},
+                                     "request": {"method": "POST", "url": {"raw": "/"}, "body": {"b": 2}}})
+        big = dict(error, context={"custom": {"a": 1, "z": "x" * 100},
+                                   "request": {"method": "POST", "url": {"raw": "/"}, "body": {"b": 2, "z": "x" * 100}}})
+        payload['errors'] = [small, big]
+
+        r = requests.post('http://localhost:8200/v1/errors', json=payload)
+        assert r.status_code == 202, r.text
+
+        self.wait_until(lambda: self.es.indices.exists(self.index_name))
+        self.es.indices.refresh(index=self.index_name)
+        self.wait_until(
+            lambda: (self.es.count(index=self.index_name, body={
+                "query": {"term": {"processor.event": "error"}}})['count'] == 2)
+        )
+
+        rs = self.es.search(index=self.index_name, body={
+            "query": {"term": {"context.truncated": "custom"}}})
+        assert rs['hits']['total'] == 1, "found {} documents".format(rs['hits']['total'])
+        context = rs['hits']['hits'][0]['_source']['context']
+        assert context['custom'] == {"a": 1}
+        assert context['request']['body'] == {"b": 2}
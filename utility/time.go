@@ -0,0 +1,25 @@
+package utility
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Time is a timestamp decoded either from an RFC3339 formatted string or from
+// a number of microseconds since epoch, as sent by newer agents to keep
+// sub-millisecond precision.
+type Time struct {
+	time.Time
+}
+
+func (t *Time) UnmarshalJSON(data []byte) error {
+	if len(data) == 0 || data[0] == '"' || string(data) == "null" {
+		return t.Time.UnmarshalJSON(data)
+	}
+	var us int64
+	if err := json.Unmarshal(data, &us); err != nil {
+		return err
+	}
+	t.Time = time.Unix(0, us*int64(time.Microsecond)).UTC()
+	return nil
+}
@@ -0,0 +1,29 @@
+package utility
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeUnmarshalJSON(t *testing.T) {
+	expected := time.Date(2017, 5, 30, 18, 53, 27, 154123000, time.UTC)
+	for _, data := range []string{
+		`"2017-05-30T18:53:27.154123Z"`,
+		`1496170407154123`,
+	} {
+		var ts Time
+		assert.NoError(t, json.Unmarshal([]byte(data), &ts), data)
+		assert.True(t, expected.Equal(ts.Time), data)
+	}
+
+	var ts Time
+	assert.NoError(t, json.Unmarshal([]byte(`null`), &ts))
+	assert.True(t, ts.IsZero())
+
+	for _, data := range []string{`1496170407154.5`, `"yesterday"`, `true`} {
+		assert.Error(t, json.Unmarshal([]byte(data), &ts), data)
+	}
+}